@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"log"
 	"math"
@@ -13,19 +14,30 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/ktigay/metrics-collector/internal/client"
 	"github.com/ktigay/metrics-collector/internal/client/collector"
+	"github.com/ktigay/metrics-collector/internal/client/queue"
 	"github.com/ktigay/metrics-collector/internal/client/sender"
 	"github.com/ktigay/metrics-collector/internal/client/sender/transport"
 	"github.com/ktigay/metrics-collector/internal/client/service"
+	"github.com/ktigay/metrics-collector/internal/compress"
+	"github.com/ktigay/metrics-collector/internal/encryption"
 	ilog "github.com/ktigay/metrics-collector/internal/log"
 	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/tlsconfig"
 )
 
 // Task задача для запуска в горутинах.
 type Task func(context.Context)
 
+// hashKeySetter транспорт с изменяемым ключом подписи.
+type hashKeySetter interface {
+	SetHashKey(keyID, key string)
+}
+
 func main() {
 	var (
 		cfg    *client.Config
@@ -55,27 +67,121 @@ func main() {
 	gp := collector.NewGopsUtilCollector()
 	gpPoller := collector.NewIntervalPoller(gp, time.Duration(cfg.PollInterval)*time.Second, logger)
 
-	t := transport.NewHTTPClient(cfg.ServerProtocol+"://"+cfg.ServerHost, cfg.HashKey, logger)
-	sn := sender.NewMetricSender(t, cfg.BatchEnabled, cfg.RateLimit, logger)
-	handler := collector.NewMetricsHandler()
-	statSender := service.NewStatSenderService(sn, handler, time.Duration(cfg.ReportInterval)*time.Second, logger)
+	dc := collector.NewDiskCollector(cfg.DiskInclude, cfg.DiskExclude)
+	dcPoller := collector.NewIntervalPoller(dc, time.Duration(cfg.PollInterval)*time.Second, logger)
+
+	nc := collector.NewNetCollector()
+	ncPoller := collector.NewIntervalPoller(nc, time.Duration(cfg.PollInterval)*time.Second, logger)
+
+	patterns, err := collector.ParseProcessPatterns(cfg.Processes)
+	if err != nil {
+		logger.Fatalf("can't parse process patterns: %v", err)
+	}
+	pc := collector.NewProcessCollector(patterns)
+	pcPoller := collector.NewIntervalPoller(pc, time.Duration(cfg.PollInterval)*time.Second, logger)
+
+	cg := collector.NewCgroupCollector(cfg.CgroupRoot)
+	cgPoller := collector.NewIntervalPoller(cg, time.Duration(cfg.PollInterval)*time.Second, logger)
+
+	pollers := map[string]*collector.IntervalPoller{
+		client.CollectorRuntime:  rnPoller,
+		client.CollectorGopsutil: gpPoller,
+		client.CollectorDisk:     dcPoller,
+		client.CollectorNet:      ncPoller,
+		client.CollectorProcess:  pcPoller,
+		client.CollectorCgroup:   cgPoller,
+	}
+	for name, p := range pollers {
+		p.SetEnabled(cfg.IsCollectorEnabled(name))
+	}
+
+	var tlsCfg *tls.Config
+	if cfg.IsTLSEnabled() {
+		if tlsCfg, err = tlsconfig.NewClientConfig(cfg.TLSCA, cfg.TLSCert, cfg.TLSKey); err != nil {
+			logger.Fatalf("can't load tls config: %v", err)
+		}
+	}
+
+	var t sender.Transport
+	switch cfg.Transport {
+	case client.TransportGRPC:
+		var opts []grpc.DialOption
+		if tlsCfg != nil {
+			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
+		}
+		if cfg.Token != "" {
+			opts = append(opts, transport.WithToken(cfg.Token))
+		}
+		if cfg.Tenant != "" {
+			opts = append(opts, transport.WithTenant(cfg.Tenant))
+		}
+		gt, err := transport.NewGRPCClient(cfg.ServerHost, cfg.HashKey, cfg.HashKeyID, logger, opts...)
+		if err != nil {
+			logger.Fatalf("can't initialize grpc transport: %v", err)
+		}
+		defer func() {
+			if err := gt.Close(); err != nil {
+				logger.Errorf("can't close grpc transport: %v", err)
+			}
+		}()
+		t = gt
+	default:
+		opts := []compress.Option{
+			compress.WithBearerToken(cfg.Token),
+			compress.WithTenant(cfg.Tenant),
+		}
+		if cfg.CryptoKey != "" {
+			pub, err := encryption.LoadPublicKey(cfg.CryptoKey)
+			if err != nil {
+				logger.Fatalf("can't load public key: %v", err)
+			}
+			opts = append(opts, compress.WithPublicKey(pub))
+		}
+		t = transport.NewHTTPClient(cfg.ServerProtocol+"://"+cfg.ServerHost, cfg.HashKey, cfg.HashKeyID, tlsCfg, logger, opts...)
+	}
+	if cfg.IsBatchEnabled() && !cfg.BatchEnabled {
+		logger.Warn("batch sending is enabled by the queue")
+	}
+	sn := sender.NewMetricSender(t, cfg.IsBatchEnabled(), cfg.RateLimit, logger)
+	handler := collector.NewMetricsHandler(cfg.Labels)
+	var senderOpts []service.Option
+	if cfg.QueueDir != "" {
+		q, err := queue.NewDiskQueue(cfg.QueueDir, cfg.QueueMaxBytes)
+		if err != nil {
+			logger.Fatalf("can't open queue: %v", err)
+		}
+		senderOpts = append(senderOpts, service.WithQueue(q))
+	}
+	statSender := service.NewStatSenderService(sn, handler, time.Duration(cfg.ReportInterval)*time.Second, logger, senderOpts...)
 
 	// размер канала такой, чтобы не блокировать сборку статистики.
-	chSize := int64(math.Ceil(float64(cfg.ReportInterval)/float64(cfg.PollInterval))) * 2
+	chSize := int64(math.Ceil(float64(cfg.ReportInterval)/float64(cfg.PollInterval))) * int64(len(pollers))
 	pollChan := make(chan []metric.Metrics, chSize)
 	defer close(pollChan)
 
 	tasks := []Task{
 		func(ctx context.Context) {
-			rnPoller.PollStat(ctx, pollChan)
-		},
-		func(ctx context.Context) {
-			gpPoller.PollStat(ctx, pollChan)
+			statSender.SendStat(ctx, pollChan)
 		},
 		func(ctx context.Context) {
-			statSender.SendStat(ctx, pollChan)
+			reloadOnHUP(ctx, logger, func(c *client.Config) {
+				for name, p := range pollers {
+					p.SetInterval(time.Duration(c.PollInterval) * time.Second)
+					p.SetEnabled(c.IsCollectorEnabled(name))
+				}
+				statSender.SetInterval(time.Duration(c.ReportInterval) * time.Second)
+				sn.SetRateLimit(c.RateLimit)
+				if hs, ok := t.(hashKeySetter); ok {
+					hs.SetHashKey(c.HashKeyID, c.HashKey)
+				}
+			})
 		},
 	}
+	for _, p := range pollers {
+		tasks = append(tasks, func(ctx context.Context) {
+			p.PollStat(ctx, pollChan)
+		})
+	}
 	var wg sync.WaitGroup
 	wg.Add(len(tasks))
 
@@ -89,3 +195,31 @@ func main() {
 	wg.Wait()
 	logger.Debug("program exited")
 }
+
+// reloadOnHUP перечитывает конфигурацию по SIGHUP и применяет ее функцией apply.
+// При ошибке конфигурации агент продолжает работать с прежними настройками.
+func reloadOnHUP(ctx context.Context, logger *zap.SugaredLogger, apply func(*client.Config)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			cfg, err := client.InitializeConfig(os.Args[1:])
+			if err != nil {
+				logger.Errorf("can't reload config: %v", err)
+				continue
+			}
+			apply(cfg)
+			logger.Infow("config reloaded",
+				"poll_interval", cfg.PollInterval,
+				"report_interval", cfg.ReportInterval,
+				"rate_limit", cfg.RateLimit,
+				"collectors", cfg.Collectors,
+			)
+		}
+	}
+}
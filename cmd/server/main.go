@@ -3,6 +3,8 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/tls"
 	"database/sql"
 	"errors"
 	"log"
@@ -17,15 +19,27 @@ import (
 	"github.com/gorilla/mux"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	"github.com/ktigay/metrics-collector/internal/encryption"
 	ilog "github.com/ktigay/metrics-collector/internal/log"
+	pb "github.com/ktigay/metrics-collector/internal/proto"
 	"github.com/ktigay/metrics-collector/internal/server"
+	"github.com/ktigay/metrics-collector/internal/server/auth"
 	"github.com/ktigay/metrics-collector/internal/server/db"
+	"github.com/ktigay/metrics-collector/internal/server/graphite"
 	"github.com/ktigay/metrics-collector/internal/server/handler"
+	"github.com/ktigay/metrics-collector/internal/server/interceptor"
+	"github.com/ktigay/metrics-collector/internal/server/keyring"
 	"github.com/ktigay/metrics-collector/internal/server/middleware"
+	"github.com/ktigay/metrics-collector/internal/server/ratelimit"
+	"github.com/ktigay/metrics-collector/internal/server/replay"
 	"github.com/ktigay/metrics-collector/internal/server/repository"
 	"github.com/ktigay/metrics-collector/internal/server/service"
 	"github.com/ktigay/metrics-collector/internal/server/snapshot"
+	"github.com/ktigay/metrics-collector/internal/server/statsd"
+	"github.com/ktigay/metrics-collector/internal/tlsconfig"
 )
 
 func main() {
@@ -73,16 +87,70 @@ func main() {
 
 	mh := handler.NewMetricHandler(collector, logger)
 	ph := handler.NewPingHandler(dbPool, logger)
+	prh := handler.NewPrometheusHandler(collector, logger)
+	rwh := handler.NewRemoteWriteHandler(collector, cfg.MaxDecompressedSize, logger)
+	ih := handler.NewInfluxHandler(collector, logger)
 	router = mux.NewRouter()
 
-	regMiddleware(router, logger, cfg.HashKey)
+	var privateKey *rsa.PrivateKey
+	if cfg.CryptoKey != "" {
+		if privateKey, err = encryption.LoadPrivateKey(cfg.CryptoKey); err != nil {
+			log.Fatalf("can't load private key: %v", err)
+		}
+	}
+
+	keys, err := cfg.Keyring()
+	if err != nil {
+		log.Fatalf("can't load hash keys: %v", err)
+	}
+
+	trustedSubnets, err := cfg.TrustedSubnets()
+	if err != nil {
+		log.Fatalf("can't parse trusted subnet: %v", err)
+	}
+	trustedProxies, err := cfg.TrustedProxyNets()
+	if err != nil {
+		log.Fatalf("can't parse trusted proxies: %v", err)
+	}
+
+	var authStore auth.Store
+	switch {
+	case cfg.AgentTokensFile != "":
+		if authStore, err = auth.NewFileStore(cfg.AgentTokensFile); err != nil {
+			log.Fatalf("can't load agent tokens: %v", err)
+		}
+	case cfg.AgentAuthDB:
+		authStore = auth.NewDBStore(dbPool)
+	}
+
+	var replayGuard *replay.Guard
+	if cfg.ReplayWindow > 0 {
+		replayGuard = replay.NewGuard(cfg.ReplayWindow, cfg.NonceCacheSize)
+	}
+
+	var limiter *ratelimit.Limiter
+	if cfg.ClientRateLimit > 0 {
+		limiter = ratelimit.NewLimiter(cfg.ClientRateLimit, cfg.ClientRateBurst)
+	}
+
+	regMiddleware(router, logger, keys, privateKey, trustedSubnets, trustedProxies, authStore, replayGuard, limiter, cfg.MaxBodySize, cfg.MaxDecompressedSize)
 
 	regMetricRoutes(router, mh)
 	regPingRoutes(router, ph)
+	regPrometheusRoutes(router, prh, rwh)
+	regInfluxRoutes(router, ih)
+
+	var tlsCfg *tls.Config
+	if cfg.IsTLSEnabled() {
+		if tlsCfg, err = tlsconfig.NewServerConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSCA); err != nil {
+			log.Fatalf("can't load tls config: %v", err)
+		}
+	}
 
 	httpServer := &http.Server{
-		Addr:    cfg.ServerHost,
-		Handler: router,
+		Addr:      cfg.ServerHost,
+		Handler:   router,
+		TLSConfig: tlsCfg,
 		BaseContext: func(net.Listener) context.Context {
 			return mainCtx
 		},
@@ -90,7 +158,12 @@ func main() {
 	wg.Add(1)
 	go func() {
 		logger.Debug("http server started")
-		if err = httpServer.ListenAndServe(); err != nil {
+		if tlsCfg != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil {
 			if errors.Is(err, http.ErrServerClosed) {
 				logger.Debug("http server stopped")
 			} else {
@@ -109,6 +182,98 @@ func main() {
 		wg.Done()
 	}()
 
+	if cfg.HistoryEnabled {
+		wg.Add(1)
+		go func() {
+			if err := collector.Compact(mainCtx, exitCtx, cfg.CompactInterval, cfg.RetentionPolicy()); err != nil {
+				logger.Errorf("can't compact metrics history: %v", err)
+			}
+			wg.Done()
+		}()
+	}
+
+	if cfg.IsAuthEnabled() && (cfg.StatsdAddress != "" || cfg.GraphiteAddress != "") {
+		logger.Warn("statsd and graphite listeners accept metrics without agent authentication")
+	}
+
+	if cfg.StatsdAddress != "" {
+		sd := statsd.NewServer(cfg.StatsdAddress, cfg.StatsdFlushInterval, collector, logger, statsd.WithTrustedSubnets(trustedSubnets))
+		wg.Add(1)
+		go func() {
+			logger.Debug("statsd server started")
+			if err := sd.ListenAndServe(mainCtx, exitCtx); err != nil {
+				logger.Errorf("can't start statsd server: %v", err)
+				stop()
+			}
+			wg.Done()
+		}()
+	}
+
+	if cfg.GraphiteAddress != "" {
+		gs := graphite.NewServer(cfg.GraphiteAddress, cfg.GraphiteReadTimeout, cfg.GraphiteMaxLineLength, collector, logger, graphite.WithTrustedSubnets(trustedSubnets))
+		wg.Add(1)
+		go func() {
+			logger.Debug("graphite server started")
+			if err := gs.ListenAndServe(mainCtx, exitCtx); err != nil {
+				logger.Errorf("can't start graphite server: %v", err)
+				stop()
+			}
+			wg.Done()
+		}()
+	}
+
+	if cfg.GRPCAddress != "" {
+		opts := []grpc.ServerOption{
+			grpc.ChainUnaryInterceptor(
+				interceptor.TrustedSubnetUnary(logger, trustedSubnets),
+				interceptor.AuthUnary(logger, authStore),
+				interceptor.TenantUnary(logger),
+				interceptor.RateLimitUnary(logger, limiter),
+				interceptor.CheckSumUnary(logger, keys),
+				interceptor.ReplayUnary(logger, replayGuard),
+			),
+			grpc.ChainStreamInterceptor(
+				interceptor.TrustedSubnetStream(logger, trustedSubnets),
+				interceptor.AuthStream(logger, authStore),
+				interceptor.TenantStream(logger),
+				interceptor.RateLimitStream(logger, limiter),
+				interceptor.CheckSumStream(logger, keys),
+				interceptor.ReplayStream(logger, replayGuard),
+			),
+		}
+		if tlsCfg != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+		}
+		if cfg.MaxDecompressedSize > 0 {
+			// gRPC проверяет размер сообщения после распаковки.
+			opts = append(opts, grpc.MaxRecvMsgSize(int(cfg.MaxDecompressedSize)))
+		}
+		grpcServer := grpc.NewServer(opts...)
+		pb.RegisterMetricsServiceServer(grpcServer, handler.NewMetricsServer(collector, cfg.MaxDecompressedSize, logger))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ln, err := net.Listen("tcp", cfg.GRPCAddress)
+			if err != nil {
+				logger.Errorf("can't start grpc server: %v", err)
+				stop()
+				return
+			}
+			logger.Debug("grpc server started")
+			if err = grpcServer.Serve(ln); err != nil {
+				logger.Errorf("grpc server error: %v", err)
+				stop()
+			}
+		}()
+
+		go func() {
+			<-exitCtx.Done()
+			logger.Debug("grpc server shutting down")
+			grpcServer.GracefulStop()
+		}()
+	}
+
 	go func() {
 		<-exitCtx.Done()
 
@@ -123,12 +288,29 @@ func main() {
 	logger.Debug("program exited")
 }
 
-func regMiddleware(router *mux.Router, logger *zap.SugaredLogger, hashKey string) {
+func regMiddleware(
+	router *mux.Router,
+	logger *zap.SugaredLogger,
+	keys keyring.Keyring,
+	privateKey *rsa.PrivateKey,
+	trustedSubnets []*net.IPNet,
+	trustedProxies []*net.IPNet,
+	authStore auth.Store,
+	replayGuard *replay.Guard,
+	limiter *ratelimit.Limiter,
+	maxBody, maxDecompressed int64,
+) {
 	router.Use(
-		middleware.WithBufferedWriter(hashKey),
+		middleware.WithBufferedWriter(keys[keyring.DefaultID]),
 		middleware.WithContentType,
-		middleware.CompressHandler(logger),
-		middleware.CheckSumRequestHandler(logger, hashKey),
+		middleware.TrustedSubnetHandler(logger, trustedSubnets),
+		middleware.AuthHandler(logger, authStore),
+		middleware.TenantHandler(logger),
+		middleware.RateLimitHandler(logger, limiter, trustedProxies),
+		middleware.DecryptHandler(logger, privateKey, maxBody),
+		middleware.CompressHandler(logger, maxBody, maxDecompressed),
+		middleware.CheckSumRequestHandler(logger, keys),
+		middleware.ReplayHandler(logger, replayGuard),
 		middleware.WithLogging(logger),
 		middleware.FlushBufferedWriter,
 	)
@@ -141,6 +323,7 @@ func regMetricRoutes(router *mux.Router, mh *handler.MetricHandler) {
 	router.HandleFunc("/value/", mh.GetJSONValueHandler).Methods(http.MethodPost)
 	router.HandleFunc("/", mh.GetAllHandler).Methods(http.MethodGet)
 	router.HandleFunc("/updates/", mh.UpdatesJSONHandler).Methods(http.MethodPost)
+	router.HandleFunc("/history/{type}/{name}", mh.HistoryHandler).Methods(http.MethodGet)
 	router.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux)
 }
 
@@ -148,6 +331,15 @@ func regPingRoutes(router *mux.Router, ph *handler.PingHandler) {
 	router.HandleFunc("/ping", ph.Ping).Methods(http.MethodGet)
 }
 
+func regPrometheusRoutes(router *mux.Router, prh *handler.PrometheusHandler, rwh *handler.RemoteWriteHandler) {
+	router.HandleFunc("/metrics", prh.Metrics).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/write", rwh.Write).Methods(http.MethodPost)
+}
+
+func regInfluxRoutes(router *mux.Router, ih *handler.InfluxHandler) {
+	router.HandleFunc("/write", ih.Write).Methods(http.MethodPost)
+}
+
 func initMetricCollector(ctx context.Context, cfg *server.Config, dbPool *sql.DB, logger *zap.SugaredLogger) (*service.MetricCollector, error) {
 	var (
 		err       error
@@ -164,7 +356,15 @@ func initMetricCollector(ctx context.Context, cfg *server.Config, dbPool *sql.DB
 		return nil, err
 	}
 
-	collector = service.NewMetricCollector(ms, logger)
+	var opts []service.Option
+	if cfg.TenantMaxMetrics > 0 {
+		opts = append(opts, service.WithTenantQuota(cfg.TenantMaxMetrics))
+	}
+	if cfg.HistoryEnabled {
+		opts = append(opts, service.WithHistory(initHistoryRepository(cfg, dbPool, logger)))
+	}
+
+	collector = service.NewMetricCollector(ms, logger, opts...)
 
 	if cfg.Restore {
 		if err = collector.Restore(ctx); err != nil {
@@ -184,6 +384,14 @@ func initMetricRepository(sn repository.MetricSnapshot, dbPool *sql.DB, useSQL b
 	return repository.NewMemRepository(sn, logger)
 }
 
+func initHistoryRepository(cfg *server.Config, dbPool *sql.DB, logger *zap.SugaredLogger) service.HistoryRepository {
+	if cfg.IsUseSQLDB() {
+		return repository.NewDBHistoryRepository(dbPool, logger)
+	}
+
+	return repository.NewMemHistoryRepository(cfg.HistorySize)
+}
+
 func initDBConnection(ctx context.Context, driver, dsn string, logger *zap.SugaredLogger) (*sql.DB, func()) {
 	var (
 		dbPool *sql.DB
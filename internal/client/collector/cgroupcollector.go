@@ -0,0 +1,298 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+const (
+	// PressureLabel метка вида задержки PSI: some или full.
+	PressureLabel = "pressure"
+	// WindowLabel метка окна усреднения PSI: avg10, avg60, avg300.
+	WindowLabel = "window"
+
+	// DefaultCgroupRoot точка монтирования cgroup по умолчанию.
+	DefaultCgroupRoot = "/sys/fs/cgroup"
+
+	// cgroupV1Unlimited значения ограничений cgroup v1 не меньше этого считаются отсутствием ограничения.
+	cgroupV1Unlimited = 1 << 62
+)
+
+// cgroupCounter накопительный счетчик cgroup.
+type cgroupCounter struct {
+	id     string
+	labels metric.Labels
+	value  uint64
+}
+
+// CgroupCollector сборщик метрик ресурсов cgroup контейнера: память, CPU-троттлинг, процессы
+// и задержки ввода-вывода (PSI). Читает cgroup v2, при ее отсутствии - cgroup v1.
+// Если cgroup не смонтирована в root, метрики не отдаются.
+type CgroupCollector struct {
+	root string
+	// prev значения счетчиков на предыдущем опросе по ключу метрики.
+	prev map[string]uint64
+}
+
+// GetStat собирает метрики.
+func (c *CgroupCollector) GetStat() ([]metric.Metrics, error) {
+	var (
+		gauges   []metric.Metrics
+		counters []cgroupCounter
+		err      error
+	)
+	switch {
+	case exists(filepath.Join(c.root, "cgroup.controllers")):
+		gauges, counters, err = c.readV2()
+	case exists(filepath.Join(c.root, "memory")):
+		gauges, counters, err = c.readV1()
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := gauges
+	cur := make(map[string]uint64, len(counters))
+	for _, cnt := range counters {
+		key := metric.Key(string(metric.TypeCounter), cnt.id, cnt.labels)
+		cur[key] = cnt.value
+		if prev, ok := c.prev[key]; ok {
+			metrics = appendDeltas(metrics, cnt.labels, delta{cnt.id, cnt.value, prev})
+		}
+	}
+	c.prev = cur
+
+	return metrics, nil
+}
+
+func (c *CgroupCollector) readV2() ([]metric.Metrics, []cgroupCounter, error) {
+	var (
+		gauges   []metric.Metrics
+		counters []cgroupCounter
+	)
+
+	for _, f := range []struct {
+		file string
+		id   metric.GaugeMetric
+	}{
+		{"memory.current", metric.CgroupMemoryUsage},
+		{"memory.max", metric.CgroupMemoryLimit},
+		{"pids.current", metric.CgroupPids},
+		{"pids.max", metric.CgroupPidsLimit},
+	} {
+		v, ok, err := readCgroupValue(filepath.Join(c.root, f.file))
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			gauges = append(gauges, gaugeMetric(f.id, float64(v), nil))
+		}
+	}
+
+	stat, err := readCgroupStat(filepath.Join(c.root, "cpu.stat"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, s := range []struct {
+		key string
+		id  string
+	}{
+		{"usage_usec", metric.CgroupCPUUsage},
+		{"nr_periods", metric.CgroupCPUPeriods},
+		{"nr_throttled", metric.CgroupCPUThrottled},
+		{"throttled_usec", metric.CgroupCPUThrottledTime},
+	} {
+		if v, ok := stat[s.key]; ok {
+			counters = append(counters, cgroupCounter{id: s.id, value: v})
+		}
+	}
+
+	psi, err := readPressure(filepath.Join(c.root, "io.pressure"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range psi {
+		for _, w := range []string{"avg10", "avg60", "avg300"} {
+			if v, ok := p.avg[w]; ok {
+				gauges = append(gauges, gaugeMetric(metric.CgroupIOPressure, v, metric.Labels{PressureLabel: p.kind, WindowLabel: w}))
+			}
+		}
+		counters = append(counters, cgroupCounter{id: metric.CgroupIOStall, labels: metric.Labels{PressureLabel: p.kind}, value: p.total})
+	}
+
+	return gauges, counters, nil
+}
+
+func (c *CgroupCollector) readV1() ([]metric.Metrics, []cgroupCounter, error) {
+	var (
+		gauges   []metric.Metrics
+		counters []cgroupCounter
+	)
+
+	for _, f := range []struct {
+		file string
+		id   metric.GaugeMetric
+	}{
+		{"memory/memory.usage_in_bytes", metric.CgroupMemoryUsage},
+		{"memory/memory.limit_in_bytes", metric.CgroupMemoryLimit},
+		{"pids/pids.current", metric.CgroupPids},
+		{"pids/pids.max", metric.CgroupPidsLimit},
+	} {
+		v, ok, err := readCgroupValue(filepath.Join(c.root, f.file))
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok && v < cgroupV1Unlimited {
+			gauges = append(gauges, gaugeMetric(f.id, float64(v), nil))
+		}
+	}
+
+	// в cgroup v1 время в наносекундах.
+	usage, ok, err := readCgroupValue(filepath.Join(c.root, "cpuacct", "cpuacct.usage"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok {
+		counters = append(counters, cgroupCounter{id: metric.CgroupCPUUsage, value: usage / 1000})
+	}
+	stat, err := readCgroupStat(filepath.Join(c.root, "cpu", "cpu.stat"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, s := range []struct {
+		key string
+		id  string
+		div uint64
+	}{
+		{"nr_periods", metric.CgroupCPUPeriods, 1},
+		{"nr_throttled", metric.CgroupCPUThrottled, 1},
+		{"throttled_time", metric.CgroupCPUThrottledTime, 1000},
+	} {
+		if v, ok := stat[s.key]; ok {
+			counters = append(counters, cgroupCounter{id: s.id, value: v / s.div})
+		}
+	}
+
+	return gauges, counters, nil
+}
+
+// readCgroupValue читает файл с одним числом. Возвращает false, если файла нет или значение max.
+func readCgroupValue(path string) (uint64, bool, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	s := strings.TrimSpace(string(b))
+	if s == "max" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", path, err)
+	}
+	return v, true, nil
+}
+
+// readCgroupStat читает файл вида "ключ значение" построчно. Отсутствующий файл - пустой результат.
+func readCgroupStat(path string) (map[string]uint64, error) {
+	stat := make(map[string]uint64)
+	err := readLines(path, func(line string) error {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		stat[key] = v
+		return nil
+	})
+	return stat, err
+}
+
+// pressure строка файла PSI: some avg10=0.00 avg60=0.00 avg300=0.00 total=0.
+type pressure struct {
+	kind  string
+	avg   map[string]float64
+	total uint64
+}
+
+func readPressure(path string) ([]pressure, error) {
+	var psi []pressure
+	err := readLines(path, func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return nil
+		}
+		p := pressure{kind: fields[0], avg: make(map[string]float64)}
+		for _, f := range fields[1:] {
+			key, value, ok := strings.Cut(f, "=")
+			if !ok {
+				continue
+			}
+			var err error
+			if key == "total" {
+				p.total, err = strconv.ParseUint(value, 10, 64)
+			} else {
+				p.avg[key], err = strconv.ParseFloat(value, 64)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		psi = append(psi, p)
+		return nil
+	})
+	return psi, err
+}
+
+// readLines вызывает fn для каждой непустой строки файла. Отсутствующий файл пропускается.
+func readLines(path string, fn func(line string) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			if err = fn(line); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// NewCgroupCollector конструктор. root - точка монтирования cgroup, обычно [DefaultCgroupRoot].
+func NewCgroupCollector(root string) *CgroupCollector {
+	return &CgroupCollector{
+		root: root,
+	}
+}
@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+func writeFixture(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+}
+
+func TestCgroupCollector_GetStat_V2(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"cgroup.controllers": "cpu io memory pids\n",
+		"memory.current":     "104857600\n",
+		"memory.max":         "536870912\n",
+		"pids.current":       "12\n",
+		"pids.max":           "max\n",
+		"cpu.stat":           "usage_usec 1000000\nuser_usec 600000\nsystem_usec 400000\nnr_periods 100\nnr_throttled 10\nthrottled_usec 50000\n",
+		"io.pressure":        "some avg10=1.50 avg60=0.75 avg300=0.25 total=2000\nfull avg10=0.50 avg60=0.00 avg300=0.00 total=1000\n",
+	})
+
+	psi := func(kind string, avg10, avg60, avg300 float64) []metric.Metrics {
+		return []metric.Metrics{
+			gaugeMetric(metric.CgroupIOPressure, avg10, metric.Labels{PressureLabel: kind, WindowLabel: "avg10"}),
+			gaugeMetric(metric.CgroupIOPressure, avg60, metric.Labels{PressureLabel: kind, WindowLabel: "avg60"}),
+			gaugeMetric(metric.CgroupIOPressure, avg300, metric.Labels{PressureLabel: kind, WindowLabel: "avg300"}),
+		}
+	}
+	gauges := append([]metric.Metrics{
+		gaugeMetric(metric.CgroupMemoryUsage, 104857600, nil),
+		gaugeMetric(metric.CgroupMemoryLimit, 536870912, nil),
+		gaugeMetric(metric.CgroupPids, 12, nil),
+	}, append(psi("some", 1.5, 0.75, 0.25), psi("full", 0.5, 0, 0)...)...)
+
+	c := NewCgroupCollector(root)
+	got, err := c.GetStat()
+	require.NoError(t, err)
+	require.Equal(t, gauges, got)
+
+	writeFixture(t, root, map[string]string{
+		"cpu.stat":    "usage_usec 1500000\nnr_periods 150\nnr_throttled 15\nthrottled_usec 80000\n",
+		"io.pressure": "some avg10=1.50 avg60=0.75 avg300=0.25 total=2500\nfull avg10=0.50 avg60=0.00 avg300=0.00 total=1000\n",
+	})
+	got, err = c.GetStat()
+	require.NoError(t, err)
+	require.Equal(t, append(gauges,
+		counterMetric(metric.CgroupCPUUsage, 500000, nil),
+		counterMetric(metric.CgroupCPUPeriods, 50, nil),
+		counterMetric(metric.CgroupCPUThrottled, 5, nil),
+		counterMetric(metric.CgroupCPUThrottledTime, 30000, nil),
+		counterMetric(metric.CgroupIOStall, 500, metric.Labels{PressureLabel: "some"}),
+		counterMetric(metric.CgroupIOStall, 0, metric.Labels{PressureLabel: "full"}),
+	), got)
+}
+
+func TestCgroupCollector_GetStat_V1(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"memory/memory.usage_in_bytes": "104857600\n",
+		"memory/memory.limit_in_bytes": "9223372036854771712\n",
+		"pids/pids.current":            "3\n",
+		"pids/pids.max":                "100\n",
+		"cpuacct/cpuacct.usage":        "2000000000\n",
+		"cpu/cpu.stat":                 "nr_periods 10\nnr_throttled 1\nthrottled_time 5000000\n",
+	})
+
+	gauges := []metric.Metrics{
+		gaugeMetric(metric.CgroupMemoryUsage, 104857600, nil),
+		gaugeMetric(metric.CgroupPids, 3, nil),
+		gaugeMetric(metric.CgroupPidsLimit, 100, nil),
+	}
+
+	c := NewCgroupCollector(root)
+	got, err := c.GetStat()
+	require.NoError(t, err)
+	require.Equal(t, gauges, got)
+
+	writeFixture(t, root, map[string]string{
+		"cpuacct/cpuacct.usage": "3000000000\n",
+		"cpu/cpu.stat":          "nr_periods 20\nnr_throttled 3\nthrottled_time 7000000\n",
+	})
+	got, err = c.GetStat()
+	require.NoError(t, err)
+	require.Equal(t, append(gauges,
+		counterMetric(metric.CgroupCPUUsage, 1000000, nil),
+		counterMetric(metric.CgroupCPUPeriods, 10, nil),
+		counterMetric(metric.CgroupCPUThrottled, 2, nil),
+		counterMetric(metric.CgroupCPUThrottledTime, 2000, nil),
+	), got)
+}
+
+func TestCgroupCollector_GetStat_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    []metric.Metrics
+		wantErr bool
+	}{
+		{
+			name:  "Positive_test_no_cgroup",
+			files: map[string]string{"other": ""},
+		},
+		{
+			name: "Negative_test_bad_value",
+			files: map[string]string{
+				"cgroup.controllers": "",
+				"memory.current":     "abc\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative_test_bad_pressure",
+			files: map[string]string{
+				"cgroup.controllers": "",
+				"io.pressure":        "some avg10=x total=1\n",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFixture(t, root, tt.files)
+
+			got, err := NewCgroupCollector(root).GetStat()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"path/filepath"
+
+	"github.com/shirou/gopsutil/v4/disk"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+const (
+	// MountpointLabel метка точки монтирования.
+	MountpointLabel = "mountpoint"
+	// DeviceLabel метка блочного устройства.
+	DeviceLabel = "device"
+)
+
+type (
+	partitionsFn     func(all bool) ([]disk.PartitionStat, error)
+	diskUsageFn      func(path string) (*disk.UsageStat, error)
+	diskIOCountersFn func(names ...string) (map[string]disk.IOCountersStat, error)
+)
+
+// DiskCollector сборщик метрик файловых систем и блочных устройств.
+// Заполненность файловых систем отдается gauge-метриками с меткой mountpoint,
+// счетчики ввода-вывода - counter-метриками с меткой device: приращение с предыдущего опроса.
+type DiskCollector struct {
+	partitionsFn partitionsFn
+	usageFn      diskUsageFn
+	ioCountersFn diskIOCountersFn
+	// include шаблоны точек монтирования (filepath.Match), пусто - все.
+	include []string
+	// exclude шаблоны исключаемых точек монтирования.
+	exclude []string
+	// prev счетчики устройств на предыдущем опросе.
+	prev map[string]disk.IOCountersStat
+}
+
+// GetStat собирает метрики.
+func (d *DiskCollector) GetStat() ([]metric.Metrics, error) {
+	partitions, err := d.partitionsFn(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []metric.Metrics
+	seen := make(map[string]struct{}, len(partitions))
+	for _, p := range partitions {
+		if _, ok := seen[p.Mountpoint]; ok || !d.match(p.Mountpoint) {
+			continue
+		}
+		seen[p.Mountpoint] = struct{}{}
+
+		// недоступная точка монтирования не должна мешать остальным.
+		u, err := d.usageFn(p.Mountpoint)
+		if err != nil {
+			continue
+		}
+		labels := metric.Labels{MountpointLabel: p.Mountpoint}
+		metrics = append(metrics,
+			gaugeMetric(metric.DiskTotal, float64(u.Total), labels),
+			gaugeMetric(metric.DiskFree, float64(u.Free), labels),
+			gaugeMetric(metric.DiskUsed, float64(u.Used), labels),
+			gaugeMetric(metric.DiskInodesTotal, float64(u.InodesTotal), labels),
+			gaugeMetric(metric.DiskInodesFree, float64(u.InodesFree), labels),
+			gaugeMetric(metric.DiskInodesUsed, float64(u.InodesUsed), labels),
+		)
+	}
+
+	counters, err := d.ioCountersFn()
+	if err != nil {
+		return nil, err
+	}
+	for name, c := range counters {
+		prev, ok := d.prev[name]
+		if !ok {
+			continue
+		}
+		metrics = appendDeltas(metrics, metric.Labels{DeviceLabel: name},
+			delta{metric.DiskReadBytes, c.ReadBytes, prev.ReadBytes},
+			delta{metric.DiskWriteBytes, c.WriteBytes, prev.WriteBytes},
+			delta{metric.DiskReadOps, c.ReadCount, prev.ReadCount},
+			delta{metric.DiskWriteOps, c.WriteCount, prev.WriteCount},
+		)
+	}
+	d.prev = counters
+
+	return metrics, nil
+}
+
+// match подходит ли точка монтирования под include и exclude.
+func (d *DiskCollector) match(mountpoint string) bool {
+	if len(d.include) > 0 && !matchAny(d.include, mountpoint) {
+		return false
+	}
+	return !matchAny(d.exclude, mountpoint)
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// delta текущее и предыдущее значения накопительного счетчика.
+type delta struct {
+	id        string
+	cur, prev uint64
+}
+
+// appendDeltas добавляет counter-метрики с приращениями счетчиков.
+// Счетчик, который уменьшился (сброс, переподключение устройства), пропускается:
+// текущее значение станет базой для следующего опроса.
+func appendDeltas(metrics []metric.Metrics, labels metric.Labels, deltas ...delta) []metric.Metrics {
+	for _, d := range deltas {
+		if d.cur < d.prev {
+			continue
+		}
+		metrics = append(metrics, counterMetric(d.id, int64(d.cur-d.prev), labels))
+	}
+	return metrics
+}
+
+func gaugeMetric(id metric.GaugeMetric, v float64, labels metric.Labels) metric.Metrics {
+	return metric.Metrics{ID: string(id), Type: string(metric.TypeGauge), Value: &v, Labels: labels}
+}
+
+func counterMetric(id string, d int64, labels metric.Labels) metric.Metrics {
+	return metric.Metrics{ID: id, Type: string(metric.TypeCounter), Delta: &d, Labels: labels}
+}
+
+// NewDiskCollector конструктор. include и exclude - шаблоны точек монтирования в формате filepath.Match.
+func NewDiskCollector(include, exclude []string) *DiskCollector {
+	return &DiskCollector{
+		partitionsFn: disk.Partitions,
+		usageFn:      disk.Usage,
+		ioCountersFn: disk.IOCounters,
+		include:      include,
+		exclude:      exclude,
+	}
+}
@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+func TestDiskCollector_GetStat(t *testing.T) {
+	partitions := []disk.PartitionStat{
+		{Device: "/dev/sda1", Mountpoint: "/"},
+		{Device: "/dev/sda2", Mountpoint: "/home"},
+		{Device: "/dev/sdb1", Mountpoint: "/mnt/backup"},
+		{Device: "/dev/sdc1", Mountpoint: "/mnt/broken"},
+	}
+	usage := func(path string) (*disk.UsageStat, error) {
+		if path == "/mnt/broken" {
+			return nil, errors.New("permission denied")
+		}
+		return &disk.UsageStat{Path: path, Total: 100, Free: 40, Used: 60, InodesTotal: 10, InodesFree: 7, InodesUsed: 3}, nil
+	}
+	usageMetrics := func(mountpoint string) []metric.Metrics {
+		labels := metric.Labels{MountpointLabel: mountpoint}
+		return []metric.Metrics{
+			gaugeMetric(metric.DiskTotal, 100, labels),
+			gaugeMetric(metric.DiskFree, 40, labels),
+			gaugeMetric(metric.DiskUsed, 60, labels),
+			gaugeMetric(metric.DiskInodesTotal, 10, labels),
+			gaugeMetric(metric.DiskInodesFree, 7, labels),
+			gaugeMetric(metric.DiskInodesUsed, 3, labels),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []metric.Metrics
+	}{
+		{
+			name: "Positive_test_all_mountpoints",
+			want: slices.Concat(usageMetrics("/"), usageMetrics("/home"), usageMetrics("/mnt/backup")),
+		},
+		{
+			name:    "Positive_test_include_exclude",
+			include: []string{"/", "/mnt/*"},
+			exclude: []string{"/mnt/backup"},
+			want:    usageMetrics("/"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &DiskCollector{
+				partitionsFn: func(bool) ([]disk.PartitionStat, error) { return partitions, nil },
+				usageFn:      usage,
+				ioCountersFn: func(...string) (map[string]disk.IOCountersStat, error) { return nil, nil },
+				include:      tt.include,
+				exclude:      tt.exclude,
+			}
+
+			got, err := d.GetStat()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDiskCollector_GetStat_IOCounters(t *testing.T) {
+	polls := []map[string]disk.IOCountersStat{
+		{
+			"sda": {ReadBytes: 1000, WriteBytes: 2000, ReadCount: 10, WriteCount: 20},
+		},
+		{
+			"sda": {ReadBytes: 1500, WriteBytes: 2100, ReadCount: 15, WriteCount: 21},
+			"sdb": {ReadBytes: 10},
+		},
+		{
+			// sda переподключен, счетчики сброшены.
+			"sda": {ReadBytes: 100, WriteBytes: 2200, ReadCount: 1, WriteCount: 22},
+			"sdb": {ReadBytes: 30},
+		},
+	}
+	labels := func(device string) metric.Labels {
+		return metric.Labels{DeviceLabel: device}
+	}
+	want := [][]metric.Metrics{
+		nil,
+		{
+			counterMetric(metric.DiskReadBytes, 500, labels("sda")),
+			counterMetric(metric.DiskWriteBytes, 100, labels("sda")),
+			counterMetric(metric.DiskReadOps, 5, labels("sda")),
+			counterMetric(metric.DiskWriteOps, 1, labels("sda")),
+		},
+		{
+			counterMetric(metric.DiskWriteBytes, 100, labels("sda")),
+			counterMetric(metric.DiskWriteOps, 1, labels("sda")),
+			counterMetric(metric.DiskReadBytes, 20, labels("sdb")),
+			counterMetric(metric.DiskWriteBytes, 0, labels("sdb")),
+			counterMetric(metric.DiskReadOps, 0, labels("sdb")),
+			counterMetric(metric.DiskWriteOps, 0, labels("sdb")),
+		},
+	}
+
+	poll := 0
+	d := &DiskCollector{
+		partitionsFn: func(bool) ([]disk.PartitionStat, error) { return nil, nil },
+		ioCountersFn: func(...string) (map[string]disk.IOCountersStat, error) {
+			defer func() { poll++ }()
+			return polls[poll], nil
+		},
+	}
+	for i := range polls {
+		got, err := d.GetStat()
+		require.NoError(t, err)
+		sort.SliceStable(got, func(i, j int) bool {
+			return got[i].Labels[DeviceLabel] < got[j].Labels[DeviceLabel]
+		})
+		require.Equal(t, want[i], got, "poll %d", i)
+	}
+}
+
+func TestDiskCollector_GetStat_Error(t *testing.T) {
+	errFailed := errors.New("failed")
+	d := &DiskCollector{
+		partitionsFn: func(bool) ([]disk.PartitionStat, error) { return nil, errFailed },
+	}
+
+	_, err := d.GetStat()
+	require.ErrorIs(t, err, errFailed)
+}
@@ -1,9 +1,10 @@
 package collector
 
 import (
-	"time"
+	"fmt"
 
 	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/load"
 	"github.com/shirou/gopsutil/v4/mem"
 
 	"github.com/ktigay/metrics-collector/internal/metric"
@@ -11,13 +12,25 @@ import (
 
 type (
 	virtualMemoryFn func() (*mem.VirtualMemoryStat, error)
-	cpuPercentFn    func(interval time.Duration, percpu bool) ([]float64, error)
+	cpuTimesFn      func(percpu bool) ([]cpu.TimesStat, error)
+	loadAvgFn       func() (*load.AvgStat, error)
+	loadMiscFn      func() (*load.MiscStat, error)
 )
 
 // GopsUtilCollector структура для метрик из gopsutil.
+// Загрузка CPU считается по приращению времен CPU между опросами,
+// поэтому первый опрос отдает только память и средние загрузки.
 type GopsUtilCollector struct {
-	memFn        virtualMemoryFn
-	cpuPercentFn cpuPercentFn
+	memFn      virtualMemoryFn
+	cpuTimesFn cpuTimesFn
+	loadAvgFn  loadAvgFn
+	loadMiscFn loadMiscFn
+	// prevTotal, prevPerCPU времена CPU на предыдущем опросе.
+	prevTotal  *cpu.TimesStat
+	prevPerCPU []cpu.TimesStat
+	// prevCtxt кол-во переключений контекста на предыдущем опросе, если hasCtxt.
+	prevCtxt int
+	hasCtxt  bool
 }
 
 // GetStat собирает метрики.
@@ -26,43 +39,86 @@ func (g *GopsUtilCollector) GetStat() ([]metric.Metrics, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	typeGauge := string(metric.TypeGauge)
+	total, err := g.cpuTimesFn(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(total) == 0 {
+		return nil, fmt.Errorf("no cpu times")
+	}
+	perCPU, err := g.cpuTimesFn(true)
+	if err != nil {
+		return nil, err
+	}
 
 	metrics := []metric.Metrics{
-		{
-			ID:   string(metric.TotalMemory),
-			Type: typeGauge,
-			Value: func() *float64 {
-				total := float64(v.Total)
-				return &total
-			}(),
-		},
-		{
-			ID:   string(metric.FreeMemory),
-			Type: typeGauge,
-			Value: func() *float64 {
-				free := float64(v.Free)
-				return &free
-			}(),
-		},
-		{
-			ID:   string(metric.CPUutilization1),
-			Type: typeGauge,
-			Value: func() *float64 {
-				util, _ := g.cpuPercentFn(0, false)
-				return &util[0]
-			}(),
-		},
+		gaugeMetric(metric.TotalMemory, float64(v.Total), nil),
+		gaugeMetric(metric.FreeMemory, float64(v.Free), nil),
+	}
+
+	if g.prevTotal != nil {
+		cur, prev := total[0], *g.prevTotal
+		metrics = append(metrics,
+			gaugeMetric(metric.CPUUser, cpuPercent(cur.User-prev.User, cur, prev), nil),
+			gaugeMetric(metric.CPUSystem, cpuPercent(cur.System-prev.System, cur, prev), nil),
+			gaugeMetric(metric.CPUIowait, cpuPercent(cur.Iowait-prev.Iowait, cur, prev), nil),
+			gaugeMetric(metric.CPUSteal, cpuPercent(cur.Steal-prev.Steal, cur, prev), nil),
+		)
+	}
+	// при изменении числа ядер (hotplug) загрузка ядер пропускается до следующего опроса.
+	if len(perCPU) == len(g.prevPerCPU) {
+		for i, cur := range perCPU {
+			prev := g.prevPerCPU[i]
+			metrics = append(metrics, gaugeMetric(metric.CPUutilization(i+1), cpuPercent(busy(cur)-busy(prev), cur, prev), nil))
+		}
+	}
+	g.prevTotal, g.prevPerCPU = &total[0], perCPU
+
+	// средние загрузки доступны не на всех ОС, их отсутствие не мешает остальным метрикам.
+	if avg, err := g.loadAvgFn(); err == nil {
+		metrics = append(metrics,
+			gaugeMetric(metric.Load1, avg.Load1, nil),
+			gaugeMetric(metric.Load5, avg.Load5, nil),
+			gaugeMetric(metric.Load15, avg.Load15, nil),
+		)
+	}
+	if misc, err := g.loadMiscFn(); err == nil {
+		if g.hasCtxt {
+			metrics = appendDeltas(metrics, nil, delta{metric.ContextSwitches, uint64(misc.Ctxt), uint64(g.prevCtxt)})
+		}
+		g.prevCtxt, g.hasCtxt = misc.Ctxt, true
+	} else {
+		g.hasCtxt = false
 	}
 
 	return metrics, nil
 }
 
+// cpuPercent доля времени d от общего времени CPU между опросами prev и cur, %.
+func cpuPercent(d float64, cur, prev cpu.TimesStat) float64 {
+	all := allTime(cur) - allTime(prev)
+	if all <= 0 || d <= 0 {
+		return 0
+	}
+	return min(100, d/all*100)
+}
+
+// allTime общее время CPU. Время гостевых ВМ на Linux уже учтено в User и Nice.
+func allTime(t cpu.TimesStat) float64 {
+	return t.Total() - t.Guest - t.GuestNice
+}
+
+// busy время CPU без простоя и ожидания ввода-вывода.
+func busy(t cpu.TimesStat) float64 {
+	return allTime(t) - t.Idle - t.Iowait
+}
+
 // NewGopsUtilCollector конструктор.
 func NewGopsUtilCollector() *GopsUtilCollector {
 	return &GopsUtilCollector{
-		memFn:        mem.VirtualMemory,
-		cpuPercentFn: cpu.Percent,
+		memFn:      mem.VirtualMemory,
+		cpuTimesFn: cpu.Times,
+		loadAvgFn:  load.Avg,
+		loadMiscFn: load.Misc,
 	}
 }
@@ -1,86 +1,167 @@
 package collector
 
 import (
-	"sort"
+	"errors"
 	"testing"
-	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/load"
 	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ktigay/metrics-collector/internal/metric"
 )
 
 func TestGopsUtilCollector_GetStat(t *testing.T) {
-	type fields struct {
-		memFn        virtualMemoryFn
-		cpuPercentFn cpuPercentFn
+	type poll struct {
+		total  cpu.TimesStat
+		perCPU []cpu.TimesStat
+		ctxt   int
 	}
+	polls := []poll{
+		{
+			total: cpu.TimesStat{User: 100, System: 50, Idle: 800, Iowait: 30, Steal: 20},
+			perCPU: []cpu.TimesStat{
+				{User: 50, Idle: 450},
+				{User: 50, System: 50, Idle: 350, Iowait: 30, Steal: 20},
+			},
+			ctxt: 1000,
+		},
+		{
+			total: cpu.TimesStat{User: 140, System: 60, Idle: 840, Iowait: 40, Steal: 20},
+			perCPU: []cpu.TimesStat{
+				// ядро 1 загружено на 75%, ядро 2 - на 20%.
+				{User: 80, Idle: 460},
+				{User: 60, System: 50, Idle: 380, Iowait: 40, Steal: 20},
+			},
+			ctxt: 1500,
+		},
+	}
+	memStat := func() (*mem.VirtualMemoryStat, error) {
+		return &mem.VirtualMemoryStat{Total: 100, Free: 200}, nil
+	}
+	loadAvg := func() (*load.AvgStat, error) {
+		return &load.AvgStat{Load1: 1.5, Load5: 1, Load15: 0.5}, nil
+	}
+	memory := []metric.Metrics{
+		gaugeMetric(metric.TotalMemory, 100, nil),
+		gaugeMetric(metric.FreeMemory, 200, nil),
+	}
+	loads := []metric.Metrics{
+		gaugeMetric(metric.Load1, 1.5, nil),
+		gaugeMetric(metric.Load5, 1, nil),
+		gaugeMetric(metric.Load15, 0.5, nil),
+	}
+
+	want := [][]metric.Metrics{
+		append(append([]metric.Metrics{}, memory...), loads...),
+		append(append(append([]metric.Metrics{}, memory...),
+			gaugeMetric(metric.CPUUser, 40, nil),
+			gaugeMetric(metric.CPUSystem, 10, nil),
+			gaugeMetric(metric.CPUIowait, 10, nil),
+			gaugeMetric(metric.CPUSteal, 0, nil),
+			gaugeMetric(metric.CPUutilization1, 75, nil),
+			gaugeMetric(metric.CPUutilization(2), 20, nil),
+		), append(loads,
+			counterMetric(metric.ContextSwitches, 500, nil),
+		)...),
+	}
+
+	i := 0
+	g := &GopsUtilCollector{
+		memFn: memStat,
+		cpuTimesFn: func(percpu bool) ([]cpu.TimesStat, error) {
+			if percpu {
+				return polls[i].perCPU, nil
+			}
+			return []cpu.TimesStat{polls[i].total}, nil
+		},
+		loadAvgFn: loadAvg,
+		loadMiscFn: func() (*load.MiscStat, error) {
+			return &load.MiscStat{Ctxt: polls[i].ctxt}, nil
+		},
+	}
+	for ; i < len(polls); i++ {
+		got, err := g.GetStat()
+		require.NoError(t, err)
+
+		if diff := cmp.Diff(want[i], got); diff != "" {
+			t.Errorf("GetStat() poll %d diff %v", i, diff)
+		}
+	}
+}
+
+func TestGopsUtilCollector_GetStat_Errors(t *testing.T) {
+	errFailed := errors.New("failed")
+	memStat := func() (*mem.VirtualMemoryStat, error) {
+		return &mem.VirtualMemoryStat{Total: 100, Free: 200}, nil
+	}
+
 	tests := []struct {
-		name   string
-		fields fields
-		want   []metric.Metrics
+		name       string
+		memFn      virtualMemoryFn
+		cpuTimesFn cpuTimesFn
+		wantErr    error
+		want       []metric.Metrics
 	}{
 		{
-			name: "Positive_test_GetStat",
-			fields: fields{
-				memFn: func() (*mem.VirtualMemoryStat, error) {
-					stat := &mem.VirtualMemoryStat{
-						Total: 100,
-						Free:  200,
-					}
-					return stat, nil
-				},
-				cpuPercentFn: func(interval time.Duration, percpu bool) ([]float64, error) {
-					return []float64{10, 20}, nil
-				},
+			name: "Negative_test_mem_error",
+			memFn: func() (*mem.VirtualMemoryStat, error) {
+				return nil, errFailed
+			},
+			wantErr: errFailed,
+		},
+		{
+			name:  "Negative_test_cpu_error",
+			memFn: memStat,
+			cpuTimesFn: func(bool) ([]cpu.TimesStat, error) {
+				return nil, errFailed
+			},
+			wantErr: errFailed,
+		},
+		{
+			name:  "Negative_test_empty_cpu_times",
+			memFn: memStat,
+			cpuTimesFn: func(bool) ([]cpu.TimesStat, error) {
+				return nil, nil
+			},
+		},
+		{
+			name:  "Positive_test_load_unavailable",
+			memFn: memStat,
+			cpuTimesFn: func(bool) ([]cpu.TimesStat, error) {
+				return []cpu.TimesStat{{}}, nil
 			},
 			want: []metric.Metrics{
-				{
-					ID:   "TotalMemory",
-					Type: "gauge",
-					Value: func() *float64 {
-						v := 100.0
-						return &v
-					}(),
-				},
-				{
-					ID:   "FreeMemory",
-					Type: "gauge",
-					Value: func() *float64 {
-						v := 200.0
-						return &v
-					}(),
-				},
-				{
-					ID:   "CPUutilization1",
-					Type: "gauge",
-					Value: func() *float64 {
-						v := 10.0
-						return &v
-					}(),
-				},
+				gaugeMetric(metric.TotalMemory, 100, nil),
+				gaugeMetric(metric.FreeMemory, 200, nil),
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			g := &GopsUtilCollector{
-				memFn:        tt.fields.memFn,
-				cpuPercentFn: tt.fields.cpuPercentFn,
+				memFn:      tt.memFn,
+				cpuTimesFn: tt.cpuTimesFn,
+				loadAvgFn: func() (*load.AvgStat, error) {
+					return nil, errFailed
+				},
+				loadMiscFn: func() (*load.MiscStat, error) {
+					return nil, errFailed
+				},
 			}
 
 			got, err := g.GetStat()
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			sort.Slice(got, func(i, j int) bool { return got[i].ID < got[j].ID })
-			sort.Slice(tt.want, func(i, j int) bool { return tt.want[i].ID < tt.want[j].ID })
-
-			if diff := cmp.Diff(got, tt.want); diff != "" {
-				t.Errorf("GetStat() = %v, want %v, diff %v", got, tt.want, diff)
+			if tt.want == nil {
+				require.Error(t, err)
+				if tt.wantErr != nil {
+					require.ErrorIs(t, err, tt.wantErr)
+				}
+				return
 			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
 		})
 	}
 }
@@ -2,6 +2,8 @@ package collector
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -19,18 +21,44 @@ type StatGetter interface {
 // IntervalPoller собирает статистику.
 type IntervalPoller struct {
 	source   StatGetter
+	mu       sync.Mutex
 	interval time.Duration
+	// changed сигнал об изменении интервала.
+	changed  chan struct{}
+	disabled atomic.Bool
 	logger   *zap.SugaredLogger
 }
 
+// SetInterval меняет интервал сбора статистики без перезапуска PollStat.
+func (m *IntervalPoller) SetInterval(d time.Duration) {
+	m.mu.Lock()
+	m.interval = d
+	m.mu.Unlock()
+
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
+
+// SetEnabled включает или отключает сбор статистики.
+func (m *IntervalPoller) SetEnabled(enabled bool) {
+	m.disabled.Store(!enabled)
+}
+
 // PollStat сбор статистики.
 func (m *IntervalPoller) PollStat(ctx context.Context, ch chan<- []metric.Metrics) {
-	ticker := time.NewTicker(m.interval)
+	ticker := time.NewTicker(m.getInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-m.changed:
+			ticker.Reset(m.getInterval())
 		case <-ticker.C:
+			if m.disabled.Load() {
+				continue
+			}
 			m.logger.Debug("pollStat collect")
 
 			metrics, err := m.source.GetStat()
@@ -46,11 +74,18 @@ func (m *IntervalPoller) PollStat(ctx context.Context, ch chan<- []metric.Metric
 	}
 }
 
+func (m *IntervalPoller) getInterval() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.interval
+}
+
 // NewIntervalPoller собирает статистику.
 func NewIntervalPoller(source StatGetter, pollInterval time.Duration, logger *zap.SugaredLogger) *IntervalPoller {
 	return &IntervalPoller{
 		source:   source,
 		interval: pollInterval,
+		changed:  make(chan struct{}, 1),
 		logger:   logger,
 	}
 }
@@ -73,3 +73,33 @@ func TestIntervalPoller_PollStat(t *testing.T) {
 		})
 	}
 }
+
+func TestIntervalPoller_SetInterval(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	sg := mocks.NewMockStatGetter(mockCtrl)
+	sg.EXPECT().GetStat().MinTimes(3)
+
+	m := NewIntervalPoller(sg, time.Hour, zap.NewNop().Sugar())
+	m.SetInterval(20 * time.Millisecond)
+
+	ch := make(chan []metric.Metrics, 100)
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	m.PollStat(ctx, ch)
+}
+
+func TestIntervalPoller_SetEnabled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	sg := mocks.NewMockStatGetter(mockCtrl)
+	sg.EXPECT().GetStat().Times(0)
+
+	m := NewIntervalPoller(sg, 20*time.Millisecond, zap.NewNop().Sugar())
+	m.SetEnabled(false)
+
+	ch := make(chan []metric.Metrics, 100)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	m.PollStat(ctx, ch)
+}
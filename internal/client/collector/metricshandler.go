@@ -13,12 +13,14 @@ import (
 type MetricsHandler struct {
 	counter     atomic.Int64
 	randFloatFn func() float64
+	labels      metric.Labels
 }
 
 // Processing обрабатывает метрики.
 func (s *MetricsHandler) Processing(metrics [][]metric.Metrics) []metric.Metrics {
 	merged := s.merge(metrics)
 	merged = s.hydrate(merged)
+	merged = s.label(merged)
 
 	return merged
 }
@@ -28,7 +30,20 @@ func (s *MetricsHandler) merge(metrics [][]metric.Metrics) []metric.Metrics {
 
 	for _, m := range metrics {
 		for _, v := range m {
-			merged[v.ID] = v
+			// гистограммы и дельты counter-метрик за несколько опросов суммируются, остальные метрики перезаписываются.
+			key := v.Key()
+			if old, ok := merged[key]; ok {
+				switch {
+				case v.Histogram != nil && old.Histogram != nil:
+					h := old.Histogram.Clone()
+					h.Merge(v.Histogram)
+					v.Histogram = h
+				case v.Type == string(metric.TypeCounter):
+					delta := old.GetDelta() + v.GetDelta()
+					v.Delta = &delta
+				}
+			}
+			merged[key] = v
 		}
 	}
 	s.counter.Add(int64(len(metrics)))
@@ -52,9 +67,21 @@ func (s *MetricsHandler) hydrate(m []metric.Metrics) []metric.Metrics {
 	return m
 }
 
+// label добавляет статические метки агента. Собственные метки метрики имеют приоритет.
+func (s *MetricsHandler) label(m []metric.Metrics) []metric.Metrics {
+	if len(s.labels) == 0 {
+		return m
+	}
+	for i := range m {
+		m[i].Labels = m[i].Labels.Merge(s.labels)
+	}
+	return m
+}
+
 // NewMetricsHandler конструктор.
-func NewMetricsHandler() *MetricsHandler {
+func NewMetricsHandler(labels metric.Labels) *MetricsHandler {
 	return &MetricsHandler{
 		randFloatFn: rand.Float64,
+		labels:      labels,
 	}
 }
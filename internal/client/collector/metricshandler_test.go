@@ -14,6 +14,7 @@ func TestMetricsHandler_Processing(t *testing.T) {
 	type fields struct {
 		counter     int64
 		randFloatFn func() float64
+		labels      metric.Labels
 	}
 	type args struct {
 		metrics [][]metric.Metrics
@@ -115,18 +116,153 @@ func TestMetricsHandler_Processing(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Positive_test_Processing_with_labels",
+			fields: fields{
+				counter: 0,
+				randFloatFn: func() float64 {
+					return 1.5
+				},
+				labels: metric.Labels{"host": "web-1"},
+			},
+			args: args{
+				metrics: [][]metric.Metrics{
+					{
+						{
+							ID:     "BytesRecv",
+							Type:   "counter",
+							Labels: metric.Labels{"iface": "eth0", "host": "own"},
+							Delta: func() *int64 {
+								v := int64(10)
+								return &v
+							}(),
+						},
+						{
+							ID:     "BytesRecv",
+							Type:   "counter",
+							Labels: metric.Labels{"iface": "eth1"},
+							Delta: func() *int64 {
+								v := int64(20)
+								return &v
+							}(),
+						},
+					},
+				},
+			},
+			want: []metric.Metrics{
+				{
+					ID:     "BytesRecv",
+					Type:   "counter",
+					Labels: metric.Labels{"iface": "eth0", "host": "own"},
+					Delta: func() *int64 {
+						v := int64(10)
+						return &v
+					}(),
+				},
+				{
+					ID:     "BytesRecv",
+					Type:   "counter",
+					Labels: metric.Labels{"iface": "eth1", "host": "web-1"},
+					Delta: func() *int64 {
+						v := int64(20)
+						return &v
+					}(),
+				},
+				{
+					ID:     "RandomValue",
+					Type:   "gauge",
+					Labels: metric.Labels{"host": "web-1"},
+					Value: func() *float64 {
+						v := 1.5
+						return &v
+					}(),
+				},
+				{
+					ID:     "PollCount",
+					Type:   "counter",
+					Labels: metric.Labels{"host": "web-1"},
+					Delta: func() *int64 {
+						v := int64(1)
+						return &v
+					}(),
+				},
+			},
+		},
+		{
+			name: "Positive_test_Processing_sum_counters",
+			fields: fields{
+				counter: 0,
+				randFloatFn: func() float64 {
+					return 1.5
+				},
+			},
+			args: args{
+				metrics: [][]metric.Metrics{
+					{
+						{
+							ID:     "DiskReadBytes",
+							Type:   "counter",
+							Labels: metric.Labels{"device": "sda"},
+							Delta: func() *int64 {
+								v := int64(10)
+								return &v
+							}(),
+						},
+					},
+					{
+						{
+							ID:     "DiskReadBytes",
+							Type:   "counter",
+							Labels: metric.Labels{"device": "sda"},
+							Delta: func() *int64 {
+								v := int64(5)
+								return &v
+							}(),
+						},
+					},
+				},
+			},
+			want: []metric.Metrics{
+				{
+					ID:     "DiskReadBytes",
+					Type:   "counter",
+					Labels: metric.Labels{"device": "sda"},
+					Delta: func() *int64 {
+						v := int64(15)
+						return &v
+					}(),
+				},
+				{
+					ID:   "RandomValue",
+					Type: "gauge",
+					Value: func() *float64 {
+						v := 1.5
+						return &v
+					}(),
+				},
+				{
+					ID:   "PollCount",
+					Type: "counter",
+					Delta: func() *int64 {
+						v := int64(2)
+						return &v
+					}(),
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &MetricsHandler{
 				counter:     atomic.Int64{},
 				randFloatFn: tt.fields.randFloatFn,
+				labels:      tt.fields.labels,
 			}
 			s.counter.Add(tt.fields.counter)
 
 			got := s.Processing(tt.args.metrics)
-			sort.Slice(got, func(i, j int) bool { return got[i].ID < got[j].ID })
-			sort.Slice(tt.want, func(i, j int) bool { return tt.want[i].ID < tt.want[j].ID })
+			sort.Slice(got, func(i, j int) bool { return got[i].Key() < got[j].Key() })
+			sort.Slice(tt.want, func(i, j int) bool { return tt.want[i].Key() < tt.want[j].Key() })
 
 			if diff := cmp.Diff(got, tt.want); diff != "" {
 				t.Errorf("Processing() = %v, want %v, diff %v", got, tt.want, diff)
@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+const (
+	// InterfaceLabel метка сетевого интерфейса.
+	InterfaceLabel = "iface"
+	// StateLabel метка состояния TCP-соединения.
+	StateLabel = "state"
+)
+
+// tcpStates состояния TCP-соединений, которые отдаются всегда, в том числе с нулевым значением,
+// чтобы на сервере не оставались устаревшие значения.
+var tcpStates = []string{
+	"ESTABLISHED", "SYN_SENT", "SYN_RECV", "FIN_WAIT1", "FIN_WAIT2", "TIME_WAIT",
+	"CLOSE", "CLOSE_WAIT", "LAST_ACK", "LISTEN", "CLOSING",
+}
+
+type (
+	netIOCountersFn  func(pernic bool) ([]net.IOCountersStat, error)
+	netConnectionsFn func(kind string) ([]net.ConnectionStat, error)
+)
+
+// NetCollector сборщик метрик сетевых интерфейсов.
+// Счетчики интерфейсов отдаются counter-метриками с меткой iface: приращение с предыдущего опроса,
+// кол-во TCP-соединений - gauge-метрикой с меткой state.
+type NetCollector struct {
+	ioCountersFn  netIOCountersFn
+	connectionsFn netConnectionsFn
+	// prev счетчики интерфейсов на предыдущем опросе.
+	prev map[string]net.IOCountersStat
+}
+
+// GetStat собирает метрики.
+func (n *NetCollector) GetStat() ([]metric.Metrics, error) {
+	counters, err := n.ioCountersFn(true)
+	if err != nil {
+		return nil, err
+	}
+	conns, err := n.connectionsFn("tcp")
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []metric.Metrics
+	cur := make(map[string]net.IOCountersStat, len(counters))
+	for _, c := range counters {
+		cur[c.Name] = c
+
+		prev, ok := n.prev[c.Name]
+		if !ok {
+			continue
+		}
+		metrics = appendDeltas(metrics, metric.Labels{InterfaceLabel: c.Name},
+			delta{metric.NetBytesRecv, c.BytesRecv, prev.BytesRecv},
+			delta{metric.NetBytesSent, c.BytesSent, prev.BytesSent},
+			delta{metric.NetPacketsRecv, c.PacketsRecv, prev.PacketsRecv},
+			delta{metric.NetPacketsSent, c.PacketsSent, prev.PacketsSent},
+			delta{metric.NetErrIn, c.Errin, prev.Errin},
+			delta{metric.NetErrOut, c.Errout, prev.Errout},
+			delta{metric.NetDropIn, c.Dropin, prev.Dropin},
+			delta{metric.NetDropOut, c.Dropout, prev.Dropout},
+		)
+	}
+	n.prev = cur
+
+	states := make(map[string]int, len(tcpStates))
+	for _, c := range conns {
+		states[c.Status]++
+	}
+	for _, s := range tcpStates {
+		metrics = append(metrics, gaugeMetric(metric.TCPConnections, float64(states[s]), metric.Labels{StateLabel: s}))
+		delete(states, s)
+	}
+	// состояния, которых нет в tcpStates (например, NONE на некоторых ОС).
+	for s, cnt := range states {
+		if s == "" {
+			continue
+		}
+		metrics = append(metrics, gaugeMetric(metric.TCPConnections, float64(cnt), metric.Labels{StateLabel: s}))
+	}
+
+	return metrics, nil
+}
+
+// NewNetCollector конструктор.
+func NewNetCollector() *NetCollector {
+	return &NetCollector{
+		ioCountersFn:  net.IOCounters,
+		connectionsFn: net.ConnectionsWithoutUids,
+	}
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/net"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+func TestNetCollector_GetStat(t *testing.T) {
+	polls := [][]net.IOCountersStat{
+		{
+			{Name: "eth0", BytesRecv: 1000, BytesSent: 500, PacketsRecv: 10, PacketsSent: 5},
+		},
+		{
+			{Name: "eth0", BytesRecv: 1600, BytesSent: 700, PacketsRecv: 16, PacketsSent: 7, Errin: 1, Dropout: 2},
+			{Name: "lo", BytesRecv: 100, BytesSent: 100},
+		},
+	}
+	conns := []net.ConnectionStat{
+		{Status: "ESTABLISHED"},
+		{Status: "ESTABLISHED"},
+		{Status: "LISTEN"},
+		{Status: "NONE"},
+	}
+
+	tcp := func(established, listen float64) []metric.Metrics {
+		var m []metric.Metrics
+		for _, s := range tcpStates {
+			var v float64
+			switch s {
+			case "ESTABLISHED":
+				v = established
+			case "LISTEN":
+				v = listen
+			}
+			m = append(m, gaugeMetric(metric.TCPConnections, v, metric.Labels{StateLabel: s}))
+		}
+		return append(m, gaugeMetric(metric.TCPConnections, 1, metric.Labels{StateLabel: "NONE"}))
+	}
+	eth0 := metric.Labels{InterfaceLabel: "eth0"}
+	want := [][]metric.Metrics{
+		tcp(2, 1),
+		append([]metric.Metrics{
+			counterMetric(metric.NetBytesRecv, 600, eth0),
+			counterMetric(metric.NetBytesSent, 200, eth0),
+			counterMetric(metric.NetPacketsRecv, 6, eth0),
+			counterMetric(metric.NetPacketsSent, 2, eth0),
+			counterMetric(metric.NetErrIn, 1, eth0),
+			counterMetric(metric.NetErrOut, 0, eth0),
+			counterMetric(metric.NetDropIn, 0, eth0),
+			counterMetric(metric.NetDropOut, 2, eth0),
+		}, tcp(2, 1)...),
+	}
+
+	poll := 0
+	n := &NetCollector{
+		ioCountersFn: func(pernic bool) ([]net.IOCountersStat, error) {
+			require.True(t, pernic)
+			defer func() { poll++ }()
+			return polls[poll], nil
+		},
+		connectionsFn: func(kind string) ([]net.ConnectionStat, error) {
+			require.Equal(t, "tcp", kind)
+			return conns, nil
+		},
+	}
+	for i := range polls {
+		got, err := n.GetStat()
+		require.NoError(t, err)
+		require.Equal(t, want[i], got, "poll %d", i)
+	}
+}
+
+func TestNetCollector_GetStat_Error(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name          string
+		ioCountersFn  netIOCountersFn
+		connectionsFn netConnectionsFn
+	}{
+		{
+			name: "Negative_test_io_counters",
+			ioCountersFn: func(bool) ([]net.IOCountersStat, error) {
+				return nil, errFailed
+			},
+		},
+		{
+			name: "Negative_test_connections",
+			ioCountersFn: func(bool) ([]net.IOCountersStat, error) {
+				return nil, nil
+			},
+			connectionsFn: func(string) ([]net.ConnectionStat, error) {
+				return nil, errFailed
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NetCollector{
+				ioCountersFn:  tt.ioCountersFn,
+				connectionsFn: tt.connectionsFn,
+			}
+
+			_, err := n.GetStat()
+			require.ErrorIs(t, err, errFailed)
+		})
+	}
+}
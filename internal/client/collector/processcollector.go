@@ -0,0 +1,248 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/process"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+var processNameRe = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// ProcessPattern группа отслеживаемых процессов.
+type ProcessPattern struct {
+	// Name имя группы в названиях метрик proc_<Name>_*.
+	Name string
+	// Cmdline шаблон командной строки. Если не задан, процессы отбираются по имени, равному Name.
+	Cmdline *regexp.Regexp
+}
+
+// ParseProcessPatterns разбирает группы процессов вида name или name=regexp,
+// где regexp - шаблон командной строки процесса.
+func ParseProcessPatterns(specs []string) ([]ProcessPattern, error) {
+	patterns := make([]ProcessPattern, 0, len(specs))
+	seen := make(map[string]struct{}, len(specs))
+	for _, s := range specs {
+		name, expr, hasExpr := strings.Cut(s, "=")
+		if !processNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid process name %q", name)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("duplicate process name %q", name)
+		}
+		seen[name] = struct{}{}
+
+		p := ProcessPattern{Name: name}
+		if hasExpr {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("process %s: %w", name, err)
+			}
+			p.Cmdline = re
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// proc процесс ОС.
+type proc interface {
+	PID() int32
+	Name() (string, error)
+	Cmdline() (string, error)
+	CreateTime() (int64, error)
+	MemoryInfo() (*process.MemoryInfoStat, error)
+	Times() (*cpu.TimesStat, error)
+	NumFDs() (int32, error)
+	NumThreads() (int32, error)
+}
+
+type processesFn func() ([]proc, error)
+
+// procCPU процессорное время процесса на предыдущем опросе.
+type procCPU struct {
+	createTime int64
+	seconds    float64
+}
+
+// ProcessCollector сборщик метрик групп процессов: суммарные RSS, загрузка CPU,
+// открытые файловые дескрипторы и потоки, кол-во процессов и перезапуски.
+// Перезапуск определяется по изменению времени старта самого старого процесса группы.
+type ProcessCollector struct {
+	patterns    []ProcessPattern
+	processesFn processesFn
+	now         func() time.Time
+	// prevTime время предыдущего опроса.
+	prevTime time.Time
+	prevCPU  map[int32]procCPU
+	// prevStart время старта группы на предыдущем опросе.
+	prevStart map[string]int64
+}
+
+type procGroup struct {
+	count, fds, threads int64
+	rss                 uint64
+	cpuSeconds          float64
+	start               int64
+}
+
+// GetStat собирает метрики.
+func (c *ProcessCollector) GetStat() ([]metric.Metrics, error) {
+	if len(c.patterns) == 0 {
+		return nil, nil
+	}
+
+	procs, err := c.processesFn()
+	if err != nil {
+		return nil, err
+	}
+	now := c.now()
+	elapsed := now.Sub(c.prevTime).Seconds()
+
+	groups := make(map[string]*procGroup, len(c.patterns))
+	cpuTimes := make(map[int32]procCPU)
+	for _, p := range procs {
+		pattern, ok := c.match(p)
+		if !ok {
+			continue
+		}
+		// процесс мог завершиться после получения списка.
+		createTime, err := p.CreateTime()
+		if err != nil {
+			continue
+		}
+
+		g, ok := groups[pattern.Name]
+		if !ok {
+			g = &procGroup{}
+			groups[pattern.Name] = g
+		}
+		g.count++
+		if g.start == 0 || createTime < g.start {
+			g.start = createTime
+		}
+		if m, err := p.MemoryInfo(); err == nil {
+			g.rss += m.RSS
+		}
+		if n, err := p.NumFDs(); err == nil {
+			g.fds += int64(n)
+		}
+		if n, err := p.NumThreads(); err == nil {
+			g.threads += int64(n)
+		}
+		if t, err := p.Times(); err == nil {
+			cur := procCPU{createTime: createTime, seconds: t.User + t.System}
+			cpuTimes[p.PID()] = cur
+			// процесс с тем же PID, но другим временем старта - новый процесс.
+			if prev, ok := c.prevCPU[p.PID()]; ok && prev.createTime == createTime && cur.seconds > prev.seconds {
+				g.cpuSeconds += cur.seconds - prev.seconds
+			}
+		}
+	}
+
+	var metrics []metric.Metrics
+	for _, pattern := range c.patterns {
+		g, ok := groups[pattern.Name]
+		if !ok {
+			g = &procGroup{}
+		}
+		metrics = append(metrics,
+			gaugeMetric(processMetric(pattern.Name, "count"), float64(g.count), nil),
+			gaugeMetric(processMetric(pattern.Name, "rss"), float64(g.rss), nil),
+			gaugeMetric(processMetric(pattern.Name, "fds"), float64(g.fds), nil),
+			gaugeMetric(processMetric(pattern.Name, "threads"), float64(g.threads), nil),
+		)
+		if c.prevCPU != nil && elapsed > 0 {
+			metrics = append(metrics, gaugeMetric(processMetric(pattern.Name, "cpu_percent"), g.cpuSeconds/elapsed*100, nil))
+		}
+
+		// группа без процессов сохраняет прежнее время старта: повторный запуск считается перезапуском.
+		if g.start == 0 {
+			continue
+		}
+		if prev, ok := c.prevStart[pattern.Name]; ok {
+			var restarts int64
+			if prev != g.start {
+				restarts = 1
+			}
+			metrics = append(metrics, counterMetric(string(processMetric(pattern.Name, "restarts")), restarts, nil))
+		}
+		c.prevStart[pattern.Name] = g.start
+	}
+	c.prevTime, c.prevCPU = now, cpuTimes
+
+	return metrics, nil
+}
+
+// match группа, в которую входит процесс.
+func (c *ProcessCollector) match(p proc) (ProcessPattern, bool) {
+	var (
+		name, cmdline string
+		nameErr       error
+		cmdlineErr    error
+		nameRead      bool
+		cmdlineRead   bool
+	)
+	for _, pattern := range c.patterns {
+		if pattern.Cmdline == nil {
+			if !nameRead {
+				name, nameErr = p.Name()
+				nameRead = true
+			}
+			if nameErr == nil && name == pattern.Name {
+				return pattern, true
+			}
+			continue
+		}
+		if !cmdlineRead {
+			cmdline, cmdlineErr = p.Cmdline()
+			cmdlineRead = true
+		}
+		if cmdlineErr == nil && pattern.Cmdline.MatchString(cmdline) {
+			return pattern, true
+		}
+	}
+	return ProcessPattern{}, false
+}
+
+// processMetric название метрики группы процессов proc_<name>_<suffix>.
+func processMetric(name, suffix string) metric.GaugeMetric {
+	return metric.GaugeMetric("proc_" + name + "_" + suffix)
+}
+
+// gopsProc процесс gopsutil.
+type gopsProc struct {
+	*process.Process
+}
+
+// PID идентификатор процесса.
+func (p gopsProc) PID() int32 {
+	return p.Pid
+}
+
+func processes() ([]proc, error) {
+	list, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	procs := make([]proc, 0, len(list))
+	for _, p := range list {
+		procs = append(procs, gopsProc{p})
+	}
+	return procs, nil
+}
+
+// NewProcessCollector конструктор.
+func NewProcessCollector(patterns []ProcessPattern) *ProcessCollector {
+	return &ProcessCollector{
+		patterns:    patterns,
+		processesFn: processes,
+		now:         time.Now,
+		prevStart:   make(map[string]int64),
+	}
+}
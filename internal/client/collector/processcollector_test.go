@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+type fakeProc struct {
+	pid        int32
+	name       string
+	cmdline    string
+	createTime int64
+	rss        uint64
+	cpuSeconds float64
+	fds        int32
+	threads    int32
+	err        error
+}
+
+func (p fakeProc) PID() int32                     { return p.pid }
+func (p fakeProc) Name() (string, error)          { return p.name, p.err }
+func (p fakeProc) Cmdline() (string, error)       { return p.cmdline, p.err }
+func (p fakeProc) CreateTime() (int64, error)     { return p.createTime, p.err }
+func (p fakeProc) NumFDs() (int32, error)         { return p.fds, p.err }
+func (p fakeProc) NumThreads() (int32, error)     { return p.threads, p.err }
+func (p fakeProc) Times() (*cpu.TimesStat, error) { return &cpu.TimesStat{User: p.cpuSeconds}, p.err }
+func (p fakeProc) MemoryInfo() (*process.MemoryInfoStat, error) {
+	return &process.MemoryInfoStat{RSS: p.rss}, p.err
+}
+
+func TestParseProcessPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "Positive_test_name_and_cmdline",
+			specs: []string{"postgres", "nginx=nginx: master"},
+			want:  []string{"postgres", "nginx"},
+		},
+		{
+			name:    "Negative_test_invalid_name",
+			specs:   []string{"my-daemon"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative_test_invalid_regexp",
+			specs:   []string{"nginx=("},
+			wantErr: true,
+		},
+		{
+			name:    "Negative_test_duplicate",
+			specs:   []string{"nginx", "nginx=nginx"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProcessPatterns(tt.specs)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			names := make([]string, 0, len(got))
+			for _, p := range got {
+				names = append(names, p.Name)
+			}
+			require.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestProcessCollector_GetStat(t *testing.T) {
+	patterns, err := ParseProcessPatterns([]string{"postgres", "nginx=^nginx: (master|worker)"})
+	require.NoError(t, err)
+
+	polls := [][]proc{
+		{
+			fakeProc{pid: 1, name: "postgres", createTime: 1000, rss: 100, cpuSeconds: 10, fds: 5, threads: 1},
+			fakeProc{pid: 2, name: "postgres", createTime: 1100, rss: 50, cpuSeconds: 2, fds: 3, threads: 1},
+			fakeProc{pid: 3, name: "nginx", cmdline: "nginx: master process", createTime: 500, rss: 10, cpuSeconds: 1, fds: 2, threads: 1},
+			fakeProc{pid: 4, name: "bash", cmdline: "bash", createTime: 1},
+		},
+		{
+			fakeProc{pid: 1, name: "postgres", createTime: 1000, rss: 120, cpuSeconds: 14, fds: 6, threads: 2},
+			// PID переиспользован новым процессом: его время CPU не сравнивается с прежним.
+			fakeProc{pid: 2, name: "postgres", createTime: 1900, rss: 40, cpuSeconds: 1, fds: 3, threads: 1},
+			// процесс завершился после получения списка.
+			fakeProc{pid: 5, name: "postgres", err: errors.New("no such process")},
+		},
+		{
+			// nginx перезапущен.
+			fakeProc{pid: 1, name: "postgres", createTime: 1000, rss: 120, cpuSeconds: 15, fds: 6, threads: 2},
+			fakeProc{pid: 7, name: "nginx", cmdline: "nginx: master process", createTime: 2500, rss: 10, fds: 2, threads: 1},
+		},
+	}
+	group := func(name string, count, rss, fds, threads float64) []metric.Metrics {
+		return []metric.Metrics{
+			gaugeMetric(processMetric(name, "count"), count, nil),
+			gaugeMetric(processMetric(name, "rss"), rss, nil),
+			gaugeMetric(processMetric(name, "fds"), fds, nil),
+			gaugeMetric(processMetric(name, "threads"), threads, nil),
+		}
+	}
+	cpuPercent := func(name string, v float64) metric.Metrics {
+		return gaugeMetric(processMetric(name, "cpu_percent"), v, nil)
+	}
+	restarts := func(name string, v int64) metric.Metrics {
+		return counterMetric(string(processMetric(name, "restarts")), v, nil)
+	}
+	want := [][]metric.Metrics{
+		append(group("postgres", 2, 150, 8, 2), group("nginx", 1, 10, 2, 1)...),
+		append(append(group("postgres", 2, 160, 9, 3), cpuPercent("postgres", 40), restarts("postgres", 0)),
+			append(group("nginx", 0, 0, 0, 0), cpuPercent("nginx", 0))...),
+		append(append(group("postgres", 1, 120, 6, 2), cpuPercent("postgres", 10), restarts("postgres", 0)),
+			append(group("nginx", 1, 10, 2, 1), cpuPercent("nginx", 0), restarts("nginx", 1))...),
+	}
+
+	now := time.Unix(1700000000, 0)
+	poll := 0
+	c := NewProcessCollector(patterns)
+	c.processesFn = func() ([]proc, error) {
+		return polls[poll], nil
+	}
+	c.now = func() time.Time { return now }
+	for ; poll < len(polls); poll++ {
+		got, err := c.GetStat()
+		require.NoError(t, err)
+		require.Equal(t, want[poll], got, "poll %d", poll)
+		now = now.Add(10 * time.Second)
+	}
+}
+
+func TestProcessCollector_GetStat_Error(t *testing.T) {
+	errFailed := errors.New("failed")
+	c := NewProcessCollector([]ProcessPattern{{Name: "postgres"}})
+	c.processesFn = func() ([]proc, error) {
+		return nil, errFailed
+	}
+
+	_, err := c.GetStat()
+	require.ErrorIs(t, err, errFailed)
+}
@@ -1,11 +1,20 @@
 package client
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/caarlos0/env/v6"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ktigay/metrics-collector/internal/client/collector"
+	"github.com/ktigay/metrics-collector/internal/metric"
 )
 
 const (
@@ -17,41 +26,161 @@ const (
 	defaultBatchEnabled   = false
 	defaultHashKey        = ""
 	defaultRateLimit      = 1
+	defaultTransport      = TransportHTTP
+	defaultCryptoKey      = ""
+	defaultTLSCA          = ""
+	defaultTLSCert        = ""
+	defaultTLSKey         = ""
+	defaultHashKeyID      = ""
+	defaultToken          = ""
+	defaultTenant         = ""
+	defaultQueueDir       = ""
+	defaultQueueMaxBytes  = 64 << 20
+	defaultConfigFile     = ""
+	defaultCgroupRoot     = collector.DefaultCgroupRoot
+)
+
+const (
+	// TransportHTTP отправка метрик по HTTP+JSON.
+	TransportHTTP = "http"
+	// TransportGRPC отправка метрик по gRPC.
+	TransportGRPC = "grpc"
 )
 
+const (
+	// CollectorRuntime сборщик метрик runtime.
+	CollectorRuntime = "runtime"
+	// CollectorGopsutil сборщик системных метрик gopsutil.
+	CollectorGopsutil = "gopsutil"
+	// CollectorDisk сборщик метрик файловых систем и дисков.
+	CollectorDisk = "disk"
+	// CollectorNet сборщик метрик сетевых интерфейсов.
+	CollectorNet = "net"
+	// CollectorProcess сборщик метрик отслеживаемых процессов.
+	CollectorProcess = "process"
+	// CollectorCgroup сборщик метрик ресурсов cgroup контейнера.
+	CollectorCgroup = "cgroup"
+)
+
+// collectors известные сборщики метрик.
+var collectors = []string{CollectorRuntime, CollectorGopsutil, CollectorDisk, CollectorNet, CollectorProcess, CollectorCgroup}
+
 // Config конфигурация клиента.
 type Config struct {
-	ServerProtocol string
-	ServerHost     string `env:"ADDRESS"`
-	ReportInterval int    `env:"REPORT_INTERVAL"`
-	PollInterval   int    `env:"POLL_INTERVAL"`
-	LogLevel       string `env:"LOG_LEVEL"`
-	BatchEnabled   bool   `env:"BATCH_ENABLED"`
-	HashKey        string `env:"KEY"`
-	RateLimit      int    `env:"RATE_LIMIT"`
+	ServerProtocol string `json:"-" yaml:"-"`
+	ServerHost     string `env:"ADDRESS" json:"address" yaml:"address"`
+	ReportInterval int    `env:"REPORT_INTERVAL" json:"report_interval" yaml:"report_interval"`
+	PollInterval   int    `env:"POLL_INTERVAL" json:"poll_interval" yaml:"poll_interval"`
+	LogLevel       string `env:"LOG_LEVEL" json:"log_level" yaml:"log_level"`
+	BatchEnabled   bool   `env:"BATCH_ENABLED" json:"batch_enabled" yaml:"batch_enabled"`
+	HashKey        string `env:"KEY" json:"key" yaml:"key"`
+	RateLimit      int    `env:"RATE_LIMIT" json:"rate_limit" yaml:"rate_limit"`
+	// Labels статические метки, добавляемые ко всем метрикам агента (host=a,env=prod).
+	Labels metric.Labels `env:"LABELS" json:"labels" yaml:"labels"`
+	// Transport транспорт отправки метрик: http или grpc.
+	Transport string `env:"TRANSPORT" json:"transport" yaml:"transport"`
+	// CryptoKey путь к публичному ключу RSA для шифрования тела запросов. Только для транспорта http.
+	CryptoKey string `env:"CRYPTO_KEY" json:"crypto_key" yaml:"crypto_key"`
+	// TLSCA путь к корневому сертификату для проверки сервера.
+	TLSCA string `env:"TLS_CA" json:"tls_ca" yaml:"tls_ca"`
+	// TLSCert путь к клиентскому сертификату для mTLS.
+	TLSCert string `env:"TLS_CERT" json:"tls_cert" yaml:"tls_cert"`
+	// TLSKey путь к ключу клиентского сертификата.
+	TLSKey string `env:"TLS_KEY" json:"tls_key" yaml:"tls_key"`
+	// HashKeyID идентификатор ключа подписи HashKey на сервере.
+	HashKeyID string `env:"KEY_ID" json:"key_id" yaml:"key_id"`
+	// Token токен агента для аутентификации на сервере.
+	Token string `env:"TOKEN" json:"token" yaml:"token"`
+	// Tenant тенант, в который агент пишет метрики.
+	Tenant string `env:"TENANT" json:"tenant" yaml:"tenant"`
+	// QueueDir каталог дисковой очереди неотправленных метрик, пусто - очередь отключена.
+	// Очередь включает отправку батчами, см. [Config.IsBatchEnabled].
+	QueueDir string `env:"QUEUE_DIR" json:"queue_dir" yaml:"queue_dir"`
+	// QueueMaxBytes максимальный размер дисковой очереди в байтах, 0 - без ограничений.
+	QueueMaxBytes int64 `env:"QUEUE_MAX_BYTES" json:"queue_max_bytes" yaml:"queue_max_bytes"`
+	// Collectors включенные сборщики метрик.
+	Collectors []string `env:"COLLECTORS" json:"collectors" yaml:"collectors"`
+	// DiskInclude шаблоны точек монтирования для сборщика disk, пусто - все.
+	DiskInclude []string `env:"DISK_INCLUDE" json:"disk_include" yaml:"disk_include"`
+	// DiskExclude шаблоны исключаемых точек монтирования.
+	DiskExclude []string `env:"DISK_EXCLUDE" json:"disk_exclude" yaml:"disk_exclude"`
+	// Processes отслеживаемые группы процессов: name (по имени процесса) или name=regexp (по командной строке).
+	Processes []string `env:"PROCESSES" json:"processes" yaml:"processes"`
+	// CgroupRoot точка монтирования cgroup для сборщика cgroup.
+	CgroupRoot string `env:"CGROUP_ROOT" json:"cgroup_root" yaml:"cgroup_root"`
+	// ConfigFile путь к файлу конфигурации в JSON или YAML.
+	ConfigFile string `env:"CONFIG" json:"-" yaml:"-"`
+}
+
+// IsTLSEnabled отправлять метрики по TLS.
+func (c *Config) IsTLSEnabled() bool {
+	return c.TLSCA != "" || c.TLSCert != ""
+}
+
+// IsBatchEnabled отправлять метрики батчами. С дисковой очередью батчи включаются всегда:
+// при поштучной отправке частичный сбой поставил бы в очередь и уже принятые сервером
+// приращения счетчиков, и при досылке они задвоились бы.
+func (c *Config) IsBatchEnabled() bool {
+	return c.BatchEnabled || c.QueueDir != ""
+}
+
+// IsCollectorEnabled включен ли сборщик name.
+func (c *Config) IsCollectorEnabled(name string) bool {
+	return slices.Contains(c.Collectors, name)
 }
 
 // InitializeConfig инициализирует конфиг клиента.
+// Приоритет источников: флаги, переменные окружения, файл конфигурации (-c или CONFIG).
 func InitializeConfig(args []string) (*Config, error) {
 	config := Config{
 		ServerProtocol: defaultServerProtocol,
+		ServerHost:     defaultServerHost,
+		ReportInterval: defaultReportInterval,
+		PollInterval:   defaultPollInterval,
+		LogLevel:       defaultLogLevel,
+		BatchEnabled:   defaultBatchEnabled,
+		HashKey:        defaultHashKey,
+		RateLimit:      defaultRateLimit,
+		Transport:      defaultTransport,
+		CryptoKey:      defaultCryptoKey,
+		TLSCA:          defaultTLSCA,
+		TLSCert:        defaultTLSCert,
+		TLSKey:         defaultTLSKey,
+		HashKeyID:      defaultHashKeyID,
+		Token:          defaultToken,
+		Tenant:         defaultTenant,
+		QueueDir:       defaultQueueDir,
+		QueueMaxBytes:  defaultQueueMaxBytes,
+		Collectors:     slices.Clone(collectors),
+		CgroupRoot:     defaultCgroupRoot,
+		ConfigFile:     defaultConfigFile,
 	}
 
-	flags := flag.NewFlagSet("agent flags", flag.ContinueOnError)
-
-	flags.StringVar(&config.ServerHost, "a", defaultServerHost, "address and port to run server")
-	flags.StringVar(&config.LogLevel, "lvl", defaultLogLevel, "log level")
-	flags.IntVar(&config.ReportInterval, "r", defaultReportInterval, "interval between reports")
-	flags.IntVar(&config.PollInterval, "p", defaultPollInterval, "interval between polls")
-	flags.BoolVar(&config.BatchEnabled, "b", defaultBatchEnabled, "enable batchEnabled request")
-	flags.StringVar(&config.HashKey, "k", defaultHashKey, "SHA256 hash key")
-	flags.IntVar(&config.RateLimit, "l", defaultRateLimit, "requests rate limit")
+	// первый проход проверяет флаги и находит файл конфигурации.
+	flagsCfg := config
+	if err := newFlagSet(&flagsCfg).Parse(args); err != nil {
+		return nil, err
+	}
+	configFile := flagsCfg.ConfigFile
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG")
+	}
+	if configFile != "" {
+		if err := loadFile(configFile, &config); err != nil {
+			return nil, err
+		}
+	}
 
-	if err := flags.Parse(args); err != nil {
+	if err := env.ParseWithFuncs(&config, map[reflect.Type]env.ParserFunc{
+		reflect.TypeOf(metric.Labels{}): func(v string) (any, error) {
+			return metric.ParseLabels(v)
+		},
+	}); err != nil {
 		return nil, err
 	}
 
-	if err := env.Parse(&config); err != nil {
+	// второй проход переопределяет значения только явно заданными флагами.
+	if err := newFlagSet(&config).Parse(args); err != nil {
 		return nil, err
 	}
 
@@ -66,6 +195,116 @@ func InitializeConfig(args []string) (*Config, error) {
 	if config.PollInterval < 1 {
 		return nil, fmt.Errorf("poll interval flag is required")
 	}
+	if config.Transport != TransportHTTP && config.Transport != TransportGRPC {
+		return nil, fmt.Errorf("unknown transport: %s", config.Transport)
+	}
+	// gRPC не шифрует тело ключом RSA, защита канала обеспечивается TLS.
+	if config.Transport == TransportGRPC && config.CryptoKey != "" {
+		return nil, fmt.Errorf("crypto key is not supported with grpc transport, use tls instead")
+	}
+	if (config.TLSCert == "") != (config.TLSKey == "") {
+		return nil, fmt.Errorf("tls cert and key must be set together")
+	}
+	if config.QueueMaxBytes < 0 {
+		return nil, fmt.Errorf("queue max bytes must not be negative")
+	}
+	if err := config.Labels.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid labels: %w", err)
+	}
+	for _, c := range config.Collectors {
+		if !slices.Contains(collectors, c) {
+			return nil, fmt.Errorf("unknown collector: %s", c)
+		}
+	}
+	if _, err := collector.ParseProcessPatterns(config.Processes); err != nil {
+		return nil, err
+	}
+	for _, p := range slices.Concat(config.DiskInclude, config.DiskExclude) {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid mountpoint pattern %q: %w", p, err)
+		}
+	}
+	if config.IsTLSEnabled() {
+		config.ServerProtocol = "https"
+	}
 
 	return &config, nil
 }
+
+// newFlagSet флаги агента. Значения по умолчанию берутся из config.
+func newFlagSet(config *Config) *flag.FlagSet {
+	flags := flag.NewFlagSet("agent flags", flag.ContinueOnError)
+
+	flags.StringVar(&config.ConfigFile, "c", config.ConfigFile, "path to JSON or YAML config file")
+	flags.StringVar(&config.ServerHost, "a", config.ServerHost, "address and port to run server")
+	flags.StringVar(&config.LogLevel, "lvl", config.LogLevel, "log level")
+	flags.IntVar(&config.ReportInterval, "r", config.ReportInterval, "interval between reports")
+	flags.IntVar(&config.PollInterval, "p", config.PollInterval, "interval between polls")
+	flags.BoolVar(&config.BatchEnabled, "b", config.BatchEnabled, "enable batchEnabled request")
+	flags.StringVar(&config.HashKey, "k", config.HashKey, "HMAC-SHA256 key")
+	flags.StringVar(&config.HashKeyID, "key-id", config.HashKeyID, "HMAC-SHA256 key id")
+	flags.IntVar(&config.RateLimit, "l", config.RateLimit, "requests rate limit")
+	flags.StringVar(&config.Transport, "transport", config.Transport, "metrics transport: http or grpc")
+	flags.StringVar(&config.CryptoKey, "crypto-key", config.CryptoKey, "path to RSA public key for payload encryption")
+	flags.StringVar(&config.TLSCA, "tls-ca", config.TLSCA, "path to CA certificate to verify the server")
+	flags.StringVar(&config.TLSCert, "tls-cert", config.TLSCert, "path to client certificate for mTLS")
+	flags.StringVar(&config.TLSKey, "tls-key", config.TLSKey, "path to client certificate key")
+	flags.StringVar(&config.Token, "token", config.Token, "agent bearer token")
+	flags.StringVar(&config.Tenant, "tenant", config.Tenant, "tenant to write metrics to")
+	flags.StringVar(&config.QueueDir, "queue-dir", config.QueueDir, "directory of on-disk queue for unsent metrics")
+	flags.Int64Var(&config.QueueMaxBytes, "queue-max-bytes", config.QueueMaxBytes, "max size of on-disk queue in bytes (0 - unlimited)")
+	flags.Func("labels", "static labels name=value,name2=value2", func(s string) (err error) {
+		config.Labels, err = metric.ParseLabels(s)
+		return err
+	})
+	flags.Func("collectors", "enabled collectors, comma separated: "+strings.Join(collectors, ","), func(s string) error {
+		config.Collectors = splitList(s)
+		return nil
+	})
+	flags.StringVar(&config.CgroupRoot, "cgroup-root", config.CgroupRoot, "cgroup mount point for cgroup collector")
+	flags.Func("processes", "watched processes, comma separated: name or name=cmdline regexp", func(s string) error {
+		config.Processes = splitList(s)
+		return nil
+	})
+	flags.Func("disk-include", "mountpoint patterns for disk collector, comma separated", func(s string) error {
+		config.DiskInclude = splitList(s)
+		return nil
+	})
+	flags.Func("disk-exclude", "mountpoint patterns excluded from disk collector, comma separated", func(s string) error {
+		config.DiskExclude = splitList(s)
+		return nil
+	})
+
+	return flags
+}
+
+// splitList разбирает список значений через запятую.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// loadFile читает файл конфигурации: YAML для расширений .yaml и .yml, иначе JSON.
+// Заданные в файле поля переопределяют значения config.
+func loadFile(path string, config *Config) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, config)
+	default:
+		err = json.Unmarshal(b, config)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
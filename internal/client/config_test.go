@@ -2,8 +2,13 @@ package client
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
 )
 
 func Test_parseFlags(t *testing.T) {
@@ -26,6 +31,10 @@ func Test_parseFlags(t *testing.T) {
 				PollInterval:   defaultPollInterval,
 				LogLevel:       defaultLogLevel,
 				RateLimit:      defaultRateLimit,
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     collectors,
 			},
 			wantErr: false,
 		},
@@ -45,6 +54,10 @@ func Test_parseFlags(t *testing.T) {
 				PollInterval:   8,
 				LogLevel:       defaultLogLevel,
 				RateLimit:      defaultRateLimit,
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     collectors,
 			},
 			wantErr: false,
 		},
@@ -65,6 +78,10 @@ func Test_parseFlags(t *testing.T) {
 				PollInterval:   15,
 				LogLevel:       defaultLogLevel,
 				RateLimit:      defaultRateLimit,
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     collectors,
 			},
 			wantErr: false,
 		},
@@ -80,14 +97,133 @@ func Test_parseFlags(t *testing.T) {
 			},
 			want: &Config{
 				ServerProtocol: defaultServerProtocol,
-				ServerHost:     "localhost:8099",
-				ReportInterval: 111,
-				PollInterval:   7,
+				ServerHost:     "localhost:80100",
+				ReportInterval: 120,
+				PollInterval:   15,
+				LogLevel:       defaultLogLevel,
+				RateLimit:      defaultRateLimit,
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     collectors,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Positive_test_Labels_Flag",
+			args: args{
+				envs: map[string]string{
+					"ADDRESS":         "",
+					"REPORT_INTERVAL": "",
+					"POLL_INTERVAL":   "",
+				},
+				flags: []string{"-a=localhost:80100", "-r=120", "-p=15", "-labels=host=web-1,env=prod"},
+			},
+			want: &Config{
+				ServerProtocol: defaultServerProtocol,
+				ServerHost:     "localhost:80100",
+				ReportInterval: 120,
+				PollInterval:   15,
 				LogLevel:       defaultLogLevel,
 				RateLimit:      defaultRateLimit,
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     collectors,
+				Labels:         metric.Labels{"host": "web-1", "env": "prod"},
 			},
 			wantErr: false,
 		},
+		{
+			name: "Positive_test_Transport_Flag",
+			args: args{
+				envs: map[string]string{
+					"ADDRESS":         "",
+					"REPORT_INTERVAL": "",
+					"POLL_INTERVAL":   "",
+				},
+				flags: []string{"-a=localhost:3200", "-transport=grpc"},
+			},
+			want: &Config{
+				ServerProtocol: defaultServerProtocol,
+				ServerHost:     "localhost:3200",
+				ReportInterval: defaultReportInterval,
+				PollInterval:   defaultPollInterval,
+				LogLevel:       defaultLogLevel,
+				RateLimit:      defaultRateLimit,
+				Transport:      TransportGRPC,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     collectors,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Positive_test_TLS_Flags",
+			args: args{
+				envs: map[string]string{
+					"ADDRESS":         "",
+					"REPORT_INTERVAL": "",
+					"POLL_INTERVAL":   "",
+				},
+				flags: []string{"-a=localhost:3200", "-tls-ca=ca.crt", "-tls-cert=client.crt", "-tls-key=client.key"},
+			},
+			want: &Config{
+				ServerProtocol: "https",
+				ServerHost:     "localhost:3200",
+				ReportInterval: defaultReportInterval,
+				PollInterval:   defaultPollInterval,
+				LogLevel:       defaultLogLevel,
+				RateLimit:      defaultRateLimit,
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     collectors,
+				TLSCA:          "ca.crt",
+				TLSCert:        "client.crt",
+				TLSKey:         "client.key",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Negative_test_TLS_Cert_Without_Key",
+			args: args{
+				envs: map[string]string{
+					"ADDRESS":         "",
+					"REPORT_INTERVAL": "",
+					"POLL_INTERVAL":   "",
+				},
+				flags: []string{"-tls-cert=client.crt"},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Negative_test_Transport_Invalid",
+			args: args{
+				envs: map[string]string{
+					"ADDRESS":         "",
+					"REPORT_INTERVAL": "",
+					"POLL_INTERVAL":   "",
+				},
+				flags: []string{"-transport=udp"},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Negative_test_Crypto_Key_With_GRPC",
+			args: args{
+				envs: map[string]string{
+					"ADDRESS":         "",
+					"REPORT_INTERVAL": "",
+					"POLL_INTERVAL":   "",
+				},
+				flags: []string{"-transport=grpc", "-crypto-key=public.pem"},
+			},
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name: "Negative_test_Address_Invalid",
 			args: args{
@@ -197,3 +333,177 @@ func Test_parseFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestInitializeConfig_File(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "agent.json")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{
+		"address": "file:8080",
+		"report_interval": 30,
+		"poll_interval": 5,
+		"rate_limit": 4,
+		"key": "file-key",
+		"labels": {"host": "web-1"},
+		"collectors": ["runtime"]
+	}`), 0o600))
+	yamlFile := filepath.Join(dir, "agent.yaml")
+	require.NoError(t, os.WriteFile(yamlFile, []byte("address: yaml:8080\nreport_interval: 20\ncollectors: [gopsutil]\n"), 0o600))
+	badFile := filepath.Join(dir, "bad.yml")
+	require.NoError(t, os.WriteFile(badFile, []byte("collectors: [unknown]\n"), 0o600))
+
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		flags   []string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name:  "Positive_test_json_file",
+			flags: []string{"-c=" + jsonFile},
+			want: &Config{
+				ServerProtocol: defaultServerProtocol,
+				ServerHost:     "file:8080",
+				ReportInterval: 30,
+				PollInterval:   5,
+				LogLevel:       defaultLogLevel,
+				HashKey:        "file-key",
+				RateLimit:      4,
+				Labels:         metric.Labels{"host": "web-1"},
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     []string{CollectorRuntime},
+				ConfigFile:     jsonFile,
+			},
+		},
+		{
+			name:  "Positive_test_flags_over_env_over_file",
+			envs:  map[string]string{"CONFIG": jsonFile, "REPORT_INTERVAL": "40", "POLL_INTERVAL": "6"},
+			flags: []string{"-p=7"},
+			want: &Config{
+				ServerProtocol: defaultServerProtocol,
+				ServerHost:     "file:8080",
+				ReportInterval: 40,
+				PollInterval:   7,
+				LogLevel:       defaultLogLevel,
+				HashKey:        "file-key",
+				RateLimit:      4,
+				Labels:         metric.Labels{"host": "web-1"},
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     []string{CollectorRuntime},
+				ConfigFile:     jsonFile,
+			},
+		},
+		{
+			name:  "Positive_test_yaml_file_collectors_flag",
+			flags: []string{"-c=" + yamlFile, "-collectors=runtime,gopsutil"},
+			want: &Config{
+				ServerProtocol: defaultServerProtocol,
+				ServerHost:     "yaml:8080",
+				ReportInterval: 20,
+				PollInterval:   defaultPollInterval,
+				LogLevel:       defaultLogLevel,
+				RateLimit:      defaultRateLimit,
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     []string{CollectorRuntime, CollectorGopsutil},
+				ConfigFile:     yamlFile,
+			},
+		},
+		{
+			name:  "Positive_test_disk_patterns",
+			envs:  map[string]string{"DISK_EXCLUDE": "/boot,/snap/*"},
+			flags: []string{"-c=" + yamlFile, "-disk-include=/,/home"},
+			want: &Config{
+				ServerProtocol: defaultServerProtocol,
+				ServerHost:     "yaml:8080",
+				ReportInterval: 20,
+				PollInterval:   defaultPollInterval,
+				LogLevel:       defaultLogLevel,
+				RateLimit:      defaultRateLimit,
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     defaultCgroupRoot,
+				Collectors:     []string{CollectorGopsutil},
+				DiskInclude:    []string{"/", "/home"},
+				DiskExclude:    []string{"/boot", "/snap/*"},
+				ConfigFile:     yamlFile,
+			},
+		},
+		{
+			name:  "Positive_test_processes",
+			envs:  map[string]string{"PROCESSES": "postgres,nginx=^nginx: master"},
+			flags: []string{"-c=" + yamlFile, "-cgroup-root=/host/cgroup"},
+			want: &Config{
+				ServerProtocol: defaultServerProtocol,
+				ServerHost:     "yaml:8080",
+				ReportInterval: 20,
+				PollInterval:   defaultPollInterval,
+				LogLevel:       defaultLogLevel,
+				RateLimit:      defaultRateLimit,
+				Transport:      defaultTransport,
+				QueueMaxBytes:  defaultQueueMaxBytes,
+				CgroupRoot:     "/host/cgroup",
+				Collectors:     []string{CollectorGopsutil},
+				Processes:      []string{"postgres", "nginx=^nginx: master"},
+				ConfigFile:     yamlFile,
+			},
+		},
+		{
+			name:    "Negative_test_bad_process_pattern",
+			flags:   []string{"-processes=nginx=("},
+			wantErr: true,
+		},
+		{
+			name:    "Negative_test_bad_disk_pattern",
+			flags:   []string{"-disk-exclude=/mnt/["},
+			wantErr: true,
+		},
+		{
+			name:    "Negative_test_unknown_collector",
+			flags:   []string{"-c=" + badFile},
+			wantErr: true,
+		},
+		{
+			name:    "Negative_test_missing_file",
+			flags:   []string{"-c=" + filepath.Join(dir, "missing.json")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"CONFIG", "ADDRESS", "REPORT_INTERVAL", "POLL_INTERVAL", "DISK_EXCLUDE", "PROCESSES"} {
+				t.Setenv(k, tt.envs[k])
+			}
+
+			got, err := InitializeConfig(tt.flags)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConfig_IsBatchEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want bool
+	}{
+		{name: "Positive_test_batch", cfg: Config{BatchEnabled: true}, want: true},
+		{name: "Positive_test_queue", cfg: Config{QueueDir: "/tmp/queue"}, want: true},
+		{name: "Negative_test_no_batch_no_queue", cfg: Config{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.cfg.IsBatchEnabled())
+		})
+	}
+}
@@ -0,0 +1,323 @@
+// Package queue Дисковая очередь метрик, не отправленных на сервер.
+//
+// Батчи хранятся в отдельных файлах-сегментах с возрастающим номером и отправляются
+// в порядке записи. Counter-метрики не попадают в сегменты: их дельты суммируются
+// в одном файле, поэтому долгий простой сервера не увеличивает их объем.
+package queue
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+const (
+	segmentExt   = ".json"
+	countersFile = "counters.json"
+	tmpSuffix    = ".tmp"
+)
+
+// ErrTooLarge батч не помещается в очередь даже после вытеснения всех сегментов.
+var ErrTooLarge = errors.New("batch exceeds queue size")
+
+type segment struct {
+	seq  uint64
+	size int64
+}
+
+// DiskQueue дисковая очередь.
+type DiskQueue struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	segments []segment
+	nextSeq  uint64
+	// counters суммарные дельты counter-метрик по ключу метрики.
+	counters     map[string]metric.Metrics
+	countersSize int64
+	// dropped кол-во потерянных сегментов: вытесненных или нечитаемых.
+	dropped int
+}
+
+// NewDiskQueue конструктор. Восстанавливает очередь из каталога dir.
+// maxBytes - максимальный суммарный размер файлов очереди, 0 - без ограничений.
+func NewDiskQueue(dir string, maxBytes int64) (*DiskQueue, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	q := &DiskQueue{
+		dir:      dir,
+		maxBytes: maxBytes,
+		counters: make(map[string]metric.Metrics),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Append добавляет батч в очередь. Если очередь переполнена, вытесняются самые старые сегменты.
+func (q *DiskQueue) Append(metrics []metric.Metrics) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var (
+		rest     []metric.Metrics
+		counters = maps.Clone(q.counters)
+	)
+	for _, m := range metrics {
+		if m.Type != string(metric.TypeCounter) {
+			rest = append(rest, m)
+			continue
+		}
+		key := m.Key()
+		c, ok := counters[key]
+		if !ok {
+			c = metric.Metrics{ID: m.ID, Type: m.Type, Labels: m.Labels.Clone()}
+		}
+		delta := c.GetDelta() + m.GetDelta()
+		c.Delta = &delta
+		counters[key] = c
+	}
+
+	var (
+		countersData []byte
+		segmentData  []byte
+		countersSize = q.countersSize
+		err          error
+	)
+	if len(metrics) > len(rest) {
+		if countersData, err = json.Marshal(sortedCounters(counters)); err != nil {
+			return err
+		}
+		countersSize = int64(len(countersData))
+	}
+	if len(rest) > 0 {
+		if segmentData, err = json.Marshal(rest); err != nil {
+			return err
+		}
+	}
+
+	if q.maxBytes > 0 {
+		if countersSize+int64(len(segmentData)) > q.maxBytes {
+			return ErrTooLarge
+		}
+		// после записи counter-метрики займут countersSize вместо q.countersSize.
+		for len(q.segments) > 0 && q.size()-q.countersSize+countersSize+int64(len(segmentData)) > q.maxBytes {
+			if err = q.removeSegment(); err != nil {
+				return err
+			}
+			q.dropped++
+		}
+	}
+
+	if countersData != nil {
+		if err = writeFile(filepath.Join(q.dir, countersFile), countersData); err != nil {
+			return err
+		}
+		q.counters, q.countersSize = counters, countersSize
+	}
+	if segmentData != nil {
+		if err = writeFile(q.segmentPath(q.nextSeq), segmentData); err != nil {
+			return err
+		}
+		q.segments = append(q.segments, segment{seq: q.nextSeq, size: int64(len(segmentData))})
+		q.nextSeq++
+	}
+	return nil
+}
+
+// Replay отправляет содержимое очереди функцией send: сегменты в порядке записи, затем counter-метрики.
+// Отправленные данные удаляются. При ошибке отправки оставшиеся данные сохраняются и возвращается ошибка.
+// Нечитаемые сегменты удаляются, чтобы не блокировать очередь.
+func (q *DiskQueue) Replay(send func([]metric.Metrics) error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.segments) > 0 {
+		metrics, err := readMetrics(q.segmentPath(q.segments[0].seq))
+		if err != nil {
+			if err = q.removeSegment(); err != nil {
+				return err
+			}
+			q.dropped++
+			continue
+		}
+		if err = send(metrics); err != nil {
+			return err
+		}
+		if err = q.removeSegment(); err != nil {
+			return err
+		}
+	}
+
+	if len(q.counters) == 0 {
+		return nil
+	}
+	if err := send(sortedCounters(q.counters)); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(q.dir, countersFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	q.counters, q.countersSize = make(map[string]metric.Metrics), 0
+	return nil
+}
+
+// Len кол-во батчей в очереди, включая батч counter-метрик.
+func (q *DiskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := len(q.segments)
+	if len(q.counters) > 0 {
+		n++
+	}
+	return n
+}
+
+// Size суммарный размер файлов очереди в байтах.
+func (q *DiskQueue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size()
+}
+
+// Dropped кол-во потерянных сегментов: вытесненных из-за переполнения или нечитаемых.
+func (q *DiskQueue) Dropped() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+func (q *DiskQueue) size() int64 {
+	size := q.countersSize
+	for _, s := range q.segments {
+		size += s.size
+	}
+	return size
+}
+
+func (q *DiskQueue) removeSegment() error {
+	if err := os.Remove(q.segmentPath(q.segments[0].seq)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	q.segments = q.segments[1:]
+	return nil
+}
+
+func (q *DiskQueue) segmentPath(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+// load читает состояние очереди из каталога. Недописанные временные файлы удаляются.
+func (q *DiskQueue) load() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case e.IsDir():
+			continue
+		case strings.HasSuffix(name, tmpSuffix):
+			if err = os.Remove(filepath.Join(q.dir, name)); err != nil {
+				return err
+			}
+		case name == countersFile:
+			if err = q.loadCounters(); err != nil {
+				return err
+			}
+		case strings.HasSuffix(name, segmentExt):
+			seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+			if err != nil {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				return err
+			}
+			q.segments = append(q.segments, segment{seq: seq, size: info.Size()})
+		}
+	}
+
+	slices.SortFunc(q.segments, func(a, b segment) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	if l := len(q.segments); l > 0 {
+		q.nextSeq = q.segments[l-1].seq + 1
+	}
+	return nil
+}
+
+func (q *DiskQueue) loadCounters() error {
+	path := filepath.Join(q.dir, countersFile)
+	metrics, err := readMetrics(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range metrics {
+		q.counters[m.Key()] = m
+	}
+	q.countersSize = info.Size()
+	return nil
+}
+
+func readMetrics(path string) ([]metric.Metrics, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var metrics []metric.Metrics
+	if err = json.Unmarshal(b, &metrics); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return metrics, nil
+}
+
+// writeFile атомарно записывает файл: через временный файл и переименование.
+func writeFile(path string, data []byte) error {
+	tmp := path + tmpSuffix
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func sortedCounters(counters map[string]metric.Metrics) []metric.Metrics {
+	metrics := make([]metric.Metrics, 0, len(counters))
+	for _, k := range slices.Sorted(maps.Keys(counters)) {
+		metrics = append(metrics, counters[k])
+	}
+	return metrics
+}
@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+func gauge(id string, v float64) metric.Metrics {
+	return metric.Metrics{ID: id, Type: string(metric.TypeGauge), Value: &v}
+}
+
+func counter(id string, d int64) metric.Metrics {
+	return metric.Metrics{ID: id, Type: string(metric.TypeCounter), Delta: &d}
+}
+
+func TestDiskQueue_ReplayInOrder(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewDiskQueue(dir, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Append([]metric.Metrics{gauge("Alloc", 1), counter("PollCount", 2)}))
+	require.NoError(t, q.Append([]metric.Metrics{gauge("Alloc", 2), counter("PollCount", 3)}))
+	require.NoError(t, q.Append([]metric.Metrics{counter("PollCount", 5)}))
+	require.Equal(t, 3, q.Len())
+
+	// очередь восстанавливается после перезапуска агента.
+	q, err = NewDiskQueue(dir, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, q.Len())
+
+	var sent [][]metric.Metrics
+	require.NoError(t, q.Replay(func(m []metric.Metrics) error {
+		sent = append(sent, m)
+		return nil
+	}))
+	require.Equal(t, [][]metric.Metrics{
+		{gauge("Alloc", 1)},
+		{gauge("Alloc", 2)},
+		{counter("PollCount", 10)},
+	}, sent)
+	require.Equal(t, 0, q.Len())
+	require.Equal(t, int64(0), q.Size())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestDiskQueue_ReplayFailure(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Append([]metric.Metrics{gauge("Alloc", 1)}))
+	require.NoError(t, q.Append([]metric.Metrics{gauge("Alloc", 2)}))
+
+	errUnavailable := errors.New("unavailable")
+	calls := 0
+	require.ErrorIs(t, q.Replay(func([]metric.Metrics) error {
+		calls++
+		if calls == 2 {
+			return errUnavailable
+		}
+		return nil
+	}), errUnavailable)
+	require.Equal(t, 1, q.Len())
+
+	var sent [][]metric.Metrics
+	require.NoError(t, q.Replay(func(m []metric.Metrics) error {
+		sent = append(sent, m)
+		return nil
+	}))
+	require.Equal(t, [][]metric.Metrics{{gauge("Alloc", 2)}}, sent)
+}
+
+func TestDiskQueue_MaxBytes(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 200)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, q.Append([]metric.Metrics{gauge("Alloc", float64(i)), counter("PollCount", 1)}))
+		require.LessOrEqual(t, q.Size(), int64(200))
+	}
+	require.Positive(t, q.Dropped())
+
+	var sent [][]metric.Metrics
+	require.NoError(t, q.Replay(func(m []metric.Metrics) error {
+		sent = append(sent, m)
+		return nil
+	}))
+	// вытесняются старые gauge-метрики, counter-метрики сохраняются полностью.
+	require.Equal(t, gauge("Alloc", 9), sent[len(sent)-2][0])
+	require.Equal(t, []metric.Metrics{counter("PollCount", 10)}, sent[len(sent)-1])
+
+	big := make([]metric.Metrics, 0, 100)
+	for i := 0; i < 100; i++ {
+		big = append(big, gauge("Alloc", float64(i)))
+	}
+	require.ErrorIs(t, q.Append(big), ErrTooLarge)
+}
+
+func TestDiskQueue_CorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000000.json"), []byte("{"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001.json.tmp"), []byte("["), 0o600))
+
+	q, err := NewDiskQueue(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, q.Append([]metric.Metrics{gauge("Alloc", 1)}))
+
+	var sent [][]metric.Metrics
+	require.NoError(t, q.Replay(func(m []metric.Metrics) error {
+		sent = append(sent, m)
+		return nil
+	}))
+	require.Equal(t, [][]metric.Metrics{{gauge("Alloc", 1)}}, sent)
+	require.Equal(t, 1, q.Dropped())
+}
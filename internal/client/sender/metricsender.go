@@ -2,6 +2,8 @@
 package sender
 
 import (
+	"sync/atomic"
+
 	"go.uber.org/zap"
 
 	"github.com/ktigay/metrics-collector/internal/metric"
@@ -19,7 +21,7 @@ type Transport interface {
 type MetricSender struct {
 	transport    Transport
 	batchEnabled bool
-	rateLimit    int
+	rateLimit    atomic.Int64
 	logger       *zap.SugaredLogger
 }
 
@@ -33,8 +35,13 @@ func (mh *MetricSender) SendMetrics(metrics []metric.Metrics, resultCh chan<- er
 	mh.send(metrics, resultCh)
 }
 
+// SetRateLimit меняет кол-во одновременных запросов. Применяется со следующей отправки.
+func (mh *MetricSender) SetRateLimit(rateLimit int) {
+	mh.rateLimit.Store(int64(rateLimit))
+}
+
 func (mh *MetricSender) send(metrics []metric.Metrics, resultCh chan<- error) {
-	rateLimit := mh.rateLimit
+	rateLimit := int(mh.rateLimit.Load())
 	if rateLimit <= 0 {
 		rateLimit = 1
 	}
@@ -83,10 +90,11 @@ func (mh *MetricSender) worker(thread int, jobs <-chan metric.Metrics, errChan c
 
 // NewMetricSender конструктор.
 func NewMetricSender(transport Transport, batchEnabled bool, rateLimit int, logger *zap.SugaredLogger) *MetricSender {
-	return &MetricSender{
+	mh := &MetricSender{
 		transport:    transport,
 		batchEnabled: batchEnabled,
-		rateLimit:    rateLimit,
 		logger:       logger,
 	}
+	mh.rateLimit.Store(int64(rateLimit))
+	return mh
 }
@@ -0,0 +1,153 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	gproto "google.golang.org/protobuf/proto"
+
+	h "github.com/ktigay/metrics-collector/internal/http"
+	"github.com/ktigay/metrics-collector/internal/metric"
+	pb "github.com/ktigay/metrics-collector/internal/proto"
+)
+
+const (
+	grpcTimeout   = 10 * time.Second
+	grpcChunkSize = 100
+)
+
+// GRPCClient gRPC транспорт отправки метрик.
+type GRPCClient struct {
+	conn    *grpc.ClientConn
+	client  pb.MetricsServiceClient
+	mu      sync.RWMutex
+	hashKey string
+	keyID   string
+	logger  *zap.SugaredLogger
+}
+
+// NewGRPCClient конструктор. addr - адрес gRPC-сервера host:port, keyID - идентификатор ключа hashKey.
+func NewGRPCClient(addr, hashKey, keyID string, logger *zap.SugaredLogger, opts ...grpc.DialOption) (*GRPCClient, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GRPCClient{
+		conn:    conn,
+		client:  pb.NewMetricsServiceClient(conn),
+		hashKey: hashKey,
+		keyID:   keyID,
+		logger:  logger,
+	}, nil
+}
+
+// Send отправка одной метрики. Возвращает текущее значение метрики в json.
+func (g *GRPCClient) Send(body metric.Metrics) ([]byte, error) {
+	req := &pb.UpdateRequest{Metric: pb.FromMetrics(body)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+
+	ctx, err := g.withCheckSum(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Update(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(resp.GetMetric().ToMetrics())
+}
+
+// SendBatch отправка батча потоком пачек по grpcChunkSize метрик.
+func (g *GRPCClient) SendBatch(body []metric.Metrics) ([]byte, error) {
+	var (
+		chunks []*pb.UpdateBatchRequest
+		msgs   []gproto.Message
+	)
+	for i := 0; i < len(body); i += grpcChunkSize {
+		req := &pb.UpdateBatchRequest{}
+		for _, m := range body[i:min(i+grpcChunkSize, len(body))] {
+			req.Metrics = append(req.Metrics, pb.FromMetrics(m))
+		}
+		chunks = append(chunks, req)
+		msgs = append(msgs, req)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+
+	ctx, err := g.withCheckSum(ctx, msgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := g.client.UpdateBatch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range chunks {
+		// при io.EOF сервер закрыл поток, причина будет получена в CloseAndRecv.
+		if err = stream.Send(c); err != nil {
+			break
+		}
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if _, err = stream.CloseAndRecv(); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// SetHashKey меняет ключ подписи запросов и его идентификатор.
+func (g *GRPCClient) SetHashKey(keyID, key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.keyID, g.hashKey = keyID, key
+}
+
+// Close закрывает соединение.
+func (g *GRPCClient) Close() error {
+	return g.conn.Close()
+}
+
+func (g *GRPCClient) withCheckSum(ctx context.Context, msgs ...gproto.Message) (context.Context, error) {
+	g.mu.RLock()
+	hashKey, keyID := g.hashKey, g.keyID
+	g.mu.RUnlock()
+
+	if hashKey == "" || len(msgs) == 0 {
+		return ctx, nil
+	}
+	ts, nonce, err := h.NewNonce()
+	if err != nil {
+		return nil, err
+	}
+	sum, err := pb.Hash(hashKey, ts, nonce, msgs...)
+	if err != nil {
+		return nil, err
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		pb.HashMetadataKey, sum,
+		pb.TimestampMetadataKey, ts,
+		pb.NonceMetadataKey, nonce,
+	)
+	if keyID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, pb.KeyIDMetadataKey, keyID)
+	}
+	return ctx, nil
+}
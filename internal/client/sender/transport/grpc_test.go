@@ -0,0 +1,131 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	pb "github.com/ktigay/metrics-collector/internal/proto"
+)
+
+type testMetricsServer struct {
+	pb.UnimplementedMetricsServiceServer
+	checkSums  []string
+	keyIDs     []string
+	timestamps []string
+	nonces     []string
+	received   []*pb.Metric
+	chunks     int
+}
+
+func (s *testMetricsServer) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.checkSums = append(s.checkSums, md.Get(pb.HashMetadataKey)...)
+	s.keyIDs = append(s.keyIDs, md.Get(pb.KeyIDMetadataKey)...)
+	s.timestamps = append(s.timestamps, md.Get(pb.TimestampMetadataKey)...)
+	s.nonces = append(s.nonces, md.Get(pb.NonceMetadataKey)...)
+	s.received = append(s.received, req.GetMetric())
+	return &pb.UpdateResponse{Metric: req.GetMetric()}, nil
+}
+
+func (s *testMetricsServer) UpdateBatch(stream pb.MetricsService_UpdateBatchServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.checkSums = append(s.checkSums, md.Get(pb.HashMetadataKey)...)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&pb.UpdateBatchResponse{Received: int64(len(s.received))})
+		}
+		if err != nil {
+			return err
+		}
+		s.chunks++
+		s.received = append(s.received, req.GetMetrics()...)
+	}
+}
+
+func newTestGRPCClient(t *testing.T, srv *testMetricsServer, hashKey, keyID string) *GRPCClient {
+	t.Helper()
+
+	gs := grpc.NewServer()
+	pb.RegisterMetricsServiceServer(gs, srv)
+
+	ln := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = gs.Serve(ln)
+	}()
+	t.Cleanup(gs.Stop)
+
+	c, err := NewGRPCClient("passthrough:///bufnet", hashKey, keyID, zap.NewNop().Sugar(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+
+	return c
+}
+
+func TestGRPCClient_Send(t *testing.T) {
+	srv := &testMetricsServer{}
+	c := newTestGRPCClient(t, srv, "secret", "v2")
+
+	v := 1.5
+	b, err := c.Send(metric.Metrics{ID: "Alloc", Type: "gauge", Value: &v, Labels: metric.Labels{"host": "a"}})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"Alloc","type":"gauge","value":1.5,"labels":{"host":"a"}}`, string(b))
+
+	require.Len(t, srv.timestamps, 1)
+	require.Len(t, srv.nonces, 1)
+	require.NotEmpty(t, srv.nonces[0])
+	want, err := pb.Hash("secret", srv.timestamps[0], srv.nonces[0], &pb.UpdateRequest{Metric: srv.received[0]})
+	require.NoError(t, err)
+	require.Equal(t, []string{want}, srv.checkSums)
+	require.Equal(t, []string{"v2"}, srv.keyIDs)
+}
+
+func TestGRPCClient_SetHashKey(t *testing.T) {
+	srv := &testMetricsServer{}
+	c := newTestGRPCClient(t, srv, "secret", "v1")
+
+	d := int64(1)
+	m := metric.Metrics{ID: "PollCount", Type: "counter", Delta: &d}
+	_, err := c.Send(m)
+	require.NoError(t, err)
+	c.SetHashKey("v2", "rotated")
+	_, err = c.Send(m)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"v1", "v2"}, srv.keyIDs)
+	want, err := pb.Hash("rotated", srv.timestamps[1], srv.nonces[1], &pb.UpdateRequest{Metric: srv.received[1]})
+	require.NoError(t, err)
+	require.Equal(t, want, srv.checkSums[1])
+}
+
+func TestGRPCClient_SendBatch(t *testing.T) {
+	srv := &testMetricsServer{}
+	c := newTestGRPCClient(t, srv, "", "")
+
+	metrics := make([]metric.Metrics, grpcChunkSize+1)
+	for i := range metrics {
+		d := int64(i)
+		metrics[i] = metric.Metrics{ID: "PollCount", Type: "counter", Delta: &d}
+	}
+
+	_, err := c.SendBatch(metrics)
+	require.NoError(t, err)
+	require.Equal(t, 2, srv.chunks)
+	require.Len(t, srv.received, grpcChunkSize+1)
+	require.Empty(t, srv.checkSums)
+}
@@ -1,12 +1,17 @@
 package transport
 
 import (
+	"crypto/tls"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"sync"
 
 	"go.uber.org/zap"
 
 	"github.com/ktigay/metrics-collector/internal/compress"
+	ihttp "github.com/ktigay/metrics-collector/internal/http"
 	"github.com/ktigay/metrics-collector/internal/metric"
 )
 
@@ -20,16 +25,24 @@ type HTTPClient struct {
 	url          string
 	compressType compress.Type
 	logger       *zap.SugaredLogger
+	mu           sync.RWMutex
 	hashKey      string
+	keyID        string
+	opts         []compress.Option
+	client       *compress.Client
 }
 
-// NewHTTPClient конструктор.
-func NewHTTPClient(url, hashKey string, logger *zap.SugaredLogger) *HTTPClient {
+// NewHTTPClient конструктор. keyID - идентификатор ключа hashKey, tlsConfig - конфигурация TLS для https (nil - по умолчанию),
+// opts - дополнительные опции запросов (например, [compress.WithPublicKey]).
+func NewHTTPClient(url, hashKey, keyID string, tlsConfig *tls.Config, logger *zap.SugaredLogger, opts ...compress.Option) *HTTPClient {
 	return &HTTPClient{
 		url:          url,
 		compressType: compress.Gzip,
 		hashKey:      hashKey,
+		keyID:        keyID,
 		logger:       logger,
+		opts:         opts,
+		client:       compress.NewClient(tlsConfig),
 	}
 }
 
@@ -43,6 +56,19 @@ func (h *HTTPClient) SendBatch(body []metric.Metrics) ([]byte, error) {
 	return h.send(h.url+updatesPath, body)
 }
 
+// SetHashKey меняет ключ подписи запросов и его идентификатор.
+func (h *HTTPClient) SetHashKey(keyID, key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.keyID, h.hashKey = keyID, key
+}
+
+func (h *HTTPClient) getHashKey() (keyID, key string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.keyID, h.hashKey
+}
+
 func (h *HTTPClient) send(url string, body any) ([]byte, error) {
 	var (
 		err  error
@@ -50,18 +76,28 @@ func (h *HTTPClient) send(url string, body any) ([]byte, error) {
 		resp *http.Response
 	)
 
+	keyID, hashKey := h.getHashKey()
+	opts := append([]compress.Option{
+		compress.WithHashKey(hashKey),
+		compress.WithKeyID(keyID),
+		compress.WithLogger(h.logger),
+	}, h.opts...)
+
 	if req, err = compress.NewJSONRequest(
 		http.MethodPost,
 		url,
 		h.compressType,
 		body,
-		compress.WithHashKey(h.hashKey),
-		compress.WithLogger(h.logger),
+		opts...,
 	); err != nil {
 		return nil, err
 	}
 
-	if resp, err = compress.NewClient().Do(req); err != nil {
+	if ip := outboundIP(req.URL); ip != "" {
+		req.Header.Set(ihttp.RealIPHeader, ip)
+	}
+
+	if resp, err = h.client.Do(req); err != nil {
 		return nil, err
 	}
 	defer func() {
@@ -72,3 +108,29 @@ func (h *HTTPClient) send(url string, body any) ([]byte, error) {
 
 	return io.ReadAll(resp.Body)
 }
+
+// outboundIP IP исходящего интерфейса, через который доступен сервер u.
+// UDP-сокет только выбирает маршрут, пакеты не отправляются.
+func outboundIP(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	conn, err := net.Dial("udp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return ""
+	}
+	return addr.IP.String()
+}
@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/compress"
+	ihttp "github.com/ktigay/metrics-collector/internal/http"
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+func TestHTTPClient_RealIP(t *testing.T) {
+	var realIP string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		realIP = r.Header.Get(ihttp.RealIPHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewHTTPClient(srv.URL, "", "", nil, zap.NewNop().Sugar())
+	_, err := c.SendBatch([]metric.Metrics{})
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", realIP)
+}
+
+func TestHTTPClient_SetHashKey(t *testing.T) {
+	keys := map[string]string{"v1": "secret", "v2": "rotated"}
+	var keyIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc, err := compress.ReaderFactory(compress.Gzip, r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(rc)
+		require.NoError(t, err)
+
+		keyID := r.Header.Get(ihttp.KeyIDHeader)
+		keyIDs = append(keyIDs, keyID)
+		sum := ihttp.RequestCheckSum(body, r.Header.Get(ihttp.TimestampHeader), r.Header.Get(ihttp.NonceHeader), keys[keyID])
+		require.Equal(t, fmt.Sprintf("%x", sum), r.Header.Get(ihttp.HashSHA256Header))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewHTTPClient(srv.URL, "secret", "v1", nil, zap.NewNop().Sugar())
+	d := int64(1)
+	m := metric.Metrics{ID: "PollCount", Type: "counter", Delta: &d}
+
+	_, err := c.Send(m)
+	require.NoError(t, err)
+	c.SetHashKey("v2", "rotated")
+	_, err = c.Send(m)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"v1", "v2"}, keyIDs)
+}
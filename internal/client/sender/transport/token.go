@@ -0,0 +1,45 @@
+package transport
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/ktigay/metrics-collector/internal/proto"
+)
+
+// tokenCredentials токен агента в метаданных authorization каждого вызова.
+type tokenCredentials string
+
+// GetRequestMetadata метаданные вызова.
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity токен передается и без TLS, как и в HTTP-транспорте.
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// WithToken опция gRPC-клиента с токеном агента.
+func WithToken(token string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(tokenCredentials(token))
+}
+
+// tenantCredentials тенант агента в метаданных x-tenant каждого вызова.
+type tenantCredentials string
+
+// GetRequestMetadata метаданные вызова.
+func (t tenantCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{pb.TenantMetadataKey: string(t)}, nil
+}
+
+// RequireTransportSecurity тенант не секретен.
+func (t tenantCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// WithTenant опция gRPC-клиента с тенантом агента.
+func WithTenant(tenant string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(tenantCredentials(tenant))
+}
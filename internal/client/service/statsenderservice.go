@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -24,17 +25,52 @@ type StatSender interface {
 	SendMetrics([]metric.Metrics, chan<- error)
 }
 
+// Queue очередь метрик, не отправленных из-за недоступности сервера.
+//
+//go:generate mockgen -destination=./mocks/mock_queue.go -package=mocks github.com/ktigay/metrics-collector/internal/client/service Queue
+type Queue interface {
+	Append(metrics []metric.Metrics) error
+	Replay(send func([]metric.Metrics) error) error
+}
+
 // StatSenderService провайдер статистики.
 type StatSenderService struct {
 	sender   StatSender
 	handler  MetricsHandler
+	mu       sync.Mutex
 	interval time.Duration
-	logger   *zap.SugaredLogger
+	// changed сигнал об изменении интервала.
+	changed chan struct{}
+	queue   Queue
+	logger  *zap.SugaredLogger
+}
+
+// Option опция провайдера статистики.
+type Option func(*StatSenderService)
+
+// WithQueue сохранять неотправленные метрики в очередь q и досылать их при восстановлении связи.
+func WithQueue(q Queue) Option {
+	return func(s *StatSenderService) {
+		s.queue = q
+	}
+}
+
+// SetInterval меняет интервал отправки статистики без перезапуска SendStat.
+// Уже собранные метрики отправляются со следующим тиком.
+func (s *StatSenderService) SetInterval(d time.Duration) {
+	s.mu.Lock()
+	s.interval = d
+	s.mu.Unlock()
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
 }
 
 // SendStat отправляет статистику.
 func (s *StatSenderService) SendStat(ctx context.Context, ch <-chan []metric.Metrics) {
-	ticker := time.NewTicker(s.interval)
+	ticker := time.NewTicker(s.getInterval())
 	defer ticker.Stop()
 
 	for {
@@ -42,6 +78,8 @@ func (s *StatSenderService) SendStat(ctx context.Context, ch <-chan []metric.Met
 		case <-ctx.Done():
 			s.logger.Debug("saveStat done")
 			return
+		case <-s.changed:
+			ticker.Reset(s.getInterval())
 		case <-ticker.C:
 			var metrics []metric.Metrics
 
@@ -52,12 +90,18 @@ func (s *StatSenderService) SendStat(ctx context.Context, ch <-chan []metric.Met
 			s.logger.Debug("readCh finished")
 
 			s.logger.Debug("send started")
-			s.send(ctx, metrics)
+			s.sendOrEnqueue(ctx, metrics)
 			s.logger.Debug("send retry finished")
 		}
 	}
 }
 
+func (s *StatSenderService) getInterval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval
+}
+
 func (s *StatSenderService) readCh(ch <-chan []metric.Metrics) []metric.Metrics {
 	metrics := make([][]metric.Metrics, 0)
 loop:
@@ -80,43 +124,80 @@ loop:
 	return s.handler.Processing(metrics)
 }
 
-func (s *StatSenderService) send(ctx context.Context, metrics []metric.Metrics) {
+// sendOrEnqueue отправляет метрики, предварительно досылая очередь.
+// Пока очередь не отправлена, новые метрики сразу ставятся в очередь без ретраев,
+// чтобы недоступность сервера не задерживала чтение канала.
+func (s *StatSenderService) sendOrEnqueue(ctx context.Context, metrics []metric.Metrics) {
+	if s.queue == nil {
+		_ = s.send(ctx, metrics)
+		return
+	}
+
+	err := s.queue.Replay(func(m []metric.Metrics) error {
+		return s.sendOnce(ctx, m)
+	})
+	if err == nil {
+		err = s.send(ctx, metrics)
+	}
+	if err == nil {
+		return
+	}
+
+	if err = s.queue.Append(metrics); err != nil {
+		s.logger.Errorf("can't enqueue metrics: %v", err)
+	}
+}
+
+func (s *StatSenderService) send(ctx context.Context, metrics []metric.Metrics) error {
+	var err error
 	retry.Ret(func(_ retry.Policy) bool {
-		var err error
-		start := time.Now()
+		err = s.sendOnce(ctx, metrics)
+		return err == nil || ctx.Err() != nil
+	})
+	return err
+}
 
-		errChan := make(chan error)
-		s.sender.SendMetrics(metrics, errChan)
+func (s *StatSenderService) sendOnce(ctx context.Context, metrics []metric.Metrics) error {
+	var err error
+	start := time.Now()
 
-	loop:
-		for {
-			select {
-			case <-ctx.Done():
-				s.logger.Debug("send done")
+	errChan := make(chan error)
+	s.sender.SendMetrics(metrics, errChan)
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Debug("send done")
+			err = ctx.Err()
+			break loop
+		case e, ok := <-errChan:
+			if !ok {
 				break loop
-			case e, ok := <-errChan:
-				if !ok {
-					break loop
-				}
-				if e != nil {
-					err = e
-					s.logger.Errorf("sendMetrics failed: %s", e)
-				}
+			}
+			if e != nil {
+				err = e
+				s.logger.Errorf("sendMetrics failed: %s", e)
 			}
 		}
+	}
 
-		s.logger.Debug("SendMetrics time %v", time.Since(start))
+	s.logger.Debug("SendMetrics time %v", time.Since(start))
 
-		return err == nil
-	})
+	return err
 }
 
 // NewStatSenderService конструктор.
-func NewStatSenderService(s StatSender, h MetricsHandler, i time.Duration, l *zap.SugaredLogger) *StatSenderService {
-	return &StatSenderService{
+func NewStatSenderService(s StatSender, h MetricsHandler, i time.Duration, l *zap.SugaredLogger, opts ...Option) *StatSenderService {
+	srv := &StatSenderService{
 		sender:   s,
 		handler:  h,
 		interval: i,
+		changed:  make(chan struct{}, 1),
 		logger:   l,
 	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	return srv
 }
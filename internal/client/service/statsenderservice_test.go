@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -58,3 +59,92 @@ func TestStatSenderService_SendStat(t *testing.T) {
 		})
 	}
 }
+
+func TestStatSenderService_SetInterval(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+
+	sender := mocks.NewMockStatSender(mockCtrl)
+	sender.
+		EXPECT().
+		SendMetrics(gomock.Any(), gomock.Any()).
+		Do(func(_ []metric.Metrics, errChan chan<- error) {
+			close(errChan)
+		}).
+		Times(1)
+
+	handler := mocks.NewMockMetricsHandler(mockCtrl)
+	handler.EXPECT().Processing(gomock.Any()).Times(1).Return([]metric.Metrics{})
+
+	s := NewStatSenderService(sender, handler, time.Hour, zap.NewNop().Sugar())
+	s.SetInterval(30 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan []metric.Metrics, 1)
+	defer close(ch)
+
+	ch <- []metric.Metrics{}
+	s.SendStat(ctx, ch)
+}
+
+func TestStatSenderService_SendStat_Queue(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+
+	tests := []struct {
+		name  string
+		queue func(mockCtrl *gomock.Controller, batch []metric.Metrics) Queue
+		sends int
+	}{
+		{
+			name: "Positive_test_replay_then_send",
+			queue: func(mockCtrl *gomock.Controller, _ []metric.Metrics) Queue {
+				q := mocks.NewMockQueue(mockCtrl)
+				q.EXPECT().Replay(gomock.Any()).DoAndReturn(func(send func([]metric.Metrics) error) error {
+					return send([]metric.Metrics{{ID: "queued", Type: "gauge"}})
+				}).Times(1)
+				return q
+			},
+			sends: 2,
+		},
+		{
+			name: "Negative_test_server_unavailable",
+			queue: func(mockCtrl *gomock.Controller, batch []metric.Metrics) Queue {
+				q := mocks.NewMockQueue(mockCtrl)
+				q.EXPECT().Replay(gomock.Any()).Return(errUnavailable).Times(1)
+				q.EXPECT().Append(batch).Return(nil).Times(1)
+				return q
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+
+			batch := []metric.Metrics{{ID: "PollCount", Type: "counter"}}
+
+			sender := mocks.NewMockStatSender(mockCtrl)
+			sender.
+				EXPECT().
+				SendMetrics(gomock.Any(), gomock.Any()).
+				Do(func(_ []metric.Metrics, errChan chan<- error) {
+					close(errChan)
+				}).
+				Times(tt.sends)
+
+			handler := mocks.NewMockMetricsHandler(mockCtrl)
+			handler.EXPECT().Processing(gomock.Any()).Times(1).Return(batch)
+
+			s := NewStatSenderService(sender, handler, 50*time.Millisecond, zap.NewNop().Sugar(), WithQueue(tt.queue(mockCtrl, batch)))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			ch := make(chan []metric.Metrics, 1)
+			defer close(ch)
+
+			ch <- batch
+			s.SendStat(ctx, ch)
+		})
+	}
+}
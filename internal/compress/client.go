@@ -1,6 +1,7 @@
 package compress
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,9 +14,17 @@ type Client struct {
 	http.Client
 }
 
-// NewClient конструктор.
-func NewClient() *Client {
-	return &Client{}
+// NewClient конструктор. tlsConfig - конфигурация TLS для https (nil - по умолчанию).
+func NewClient(tlsConfig *tls.Config) *Client {
+	if tlsConfig == nil {
+		return &Client{}
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = tlsConfig
+	return &Client{
+		Client: http.Client{Transport: tr},
+	}
 }
 
 // Do выполнить запрос.
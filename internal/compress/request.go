@@ -2,19 +2,24 @@ package compress
 
 import (
 	"bytes"
-	"crypto/sha256"
+	"crypto/rsa"
 	"fmt"
 	"net/http"
 
 	"go.uber.org/zap/buffer"
 
+	"github.com/ktigay/metrics-collector/internal/encryption"
 	h "github.com/ktigay/metrics-collector/internal/http"
 )
 
 // Options опции реквеста.
 type Options struct {
-	hashKey string
-	logger  Logger
+	hashKey   string
+	keyID     string
+	token     string
+	tenant    string
+	logger    Logger
+	publicKey *rsa.PublicKey
 }
 
 // NewOptions конструктор.
@@ -36,6 +41,27 @@ func WithHashKey(hashKey string) Option {
 	}
 }
 
+// WithKeyID реквест с идентификатором ключа подписи.
+func WithKeyID(keyID string) Option {
+	return func(opt *Options) {
+		opt.keyID = keyID
+	}
+}
+
+// WithBearerToken реквест с токеном агента в хедере Authorization.
+func WithBearerToken(token string) Option {
+	return func(opt *Options) {
+		opt.token = token
+	}
+}
+
+// WithTenant реквест с тенантом в хедере X-Tenant.
+func WithTenant(tenant string) Option {
+	return func(opt *Options) {
+		opt.tenant = tenant
+	}
+}
+
 // WithLogger реквест с логгером.
 func WithLogger(logger Logger) Option {
 	return func(opt *Options) {
@@ -43,12 +69,20 @@ func WithLogger(logger Logger) Option {
 	}
 }
 
+// WithPublicKey реквест с телом, зашифрованным публичным ключом.
+func WithPublicKey(key *rsa.PublicKey) Option {
+	return func(opt *Options) {
+		opt.publicKey = key
+	}
+}
+
 // NewJSONRequest запрос.
 func NewJSONRequest(method, url string, t Type, body any, opt ...Option) (*http.Request, error) {
 	var (
-		comp *WriteCloser
-		err  error
-		req  *http.Request
+		comp    *WriteCloser
+		err     error
+		req     *http.Request
+		payload []byte
 	)
 
 	opts := NewOptions(opt)
@@ -62,7 +96,14 @@ func NewJSONRequest(method, url string, t Type, body any, opt ...Option) (*http.
 		return nil, err
 	}
 
-	if req, err = http.NewRequest(method, url, bytes.NewReader(w.Bytes())); err != nil {
+	payload = w.Bytes()
+	if opts.publicKey != nil {
+		if payload, err = encryption.Encrypt(opts.publicKey, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	if req, err = http.NewRequest(method, url, bytes.NewReader(payload)); err != nil {
 		return nil, err
 	}
 
@@ -76,10 +117,31 @@ func NewJSONRequest(method, url string, t Type, body any, opt ...Option) (*http.
 		"Accept-Encoding":  enc,
 	}
 
+	if opts.token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.token)
+	}
+	if opts.tenant != "" {
+		req.Header[h.TenantHeader] = []string{opts.tenant}
+	}
+
+	if opts.publicKey != nil {
+		req.Header.Set(h.EncryptionHeader, h.EncryptionRSAAESGCM)
+	}
+
 	rb := comp.RawBody()
 	if opts.hashKey != "" && len(rb) > 0 {
-		hash := sha256.Sum256(append(rb, opts.hashKey...))
+		ts, ns, err := h.NewNonce()
+		if err != nil {
+			return nil, err
+		}
+
+		hash := h.RequestCheckSum(rb, ts, ns, opts.hashKey)
 		req.Header[h.HashSHA256Header] = []string{fmt.Sprintf("%x", hash)}
+		req.Header[h.TimestampHeader] = []string{ts}
+		req.Header[h.NonceHeader] = []string{ns}
+		if opts.keyID != "" {
+			req.Header[h.KeyIDHeader] = []string{opts.keyID}
+		}
 	}
 
 	return req, nil
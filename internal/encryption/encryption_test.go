@@ -0,0 +1,82 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, typ string, b []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0o600))
+	return path
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	pkix, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		pubPath string
+		keyPath string
+	}{
+		{
+			name:    "pkcs1",
+			pubPath: writePEM(t, "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&priv.PublicKey)),
+			keyPath: writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(priv)),
+		},
+		{
+			name:    "pkix_pkcs8",
+			pubPath: writePEM(t, "PUBLIC KEY", pkix),
+			keyPath: writePEM(t, "PRIVATE KEY", pkcs8),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub, err := LoadPublicKey(tt.pubPath)
+			require.NoError(t, err)
+			key, err := LoadPrivateKey(tt.keyPath)
+			require.NoError(t, err)
+
+			plaintext := []byte(`{"id":"Alloc","type":"gauge","value":1.5}`)
+			ciphertext, err := Encrypt(pub, plaintext)
+			require.NoError(t, err)
+			require.NotContains(t, string(ciphertext), "Alloc")
+
+			got, err := Decrypt(key, ciphertext)
+			require.NoError(t, err)
+			require.Equal(t, plaintext, got)
+
+			ciphertext[len(ciphertext)-1] ^= 0xff
+			_, err = Decrypt(key, ciphertext)
+			require.True(t, errors.Is(err, ErrMalformed))
+
+			_, err = Decrypt(key, []byte{0x01})
+			require.True(t, errors.Is(err, ErrMalformed))
+		})
+	}
+}
+
+func TestLoadPublicKey_Invalid(t *testing.T) {
+	_, err := LoadPublicKey(filepath.Join(t.TempDir(), "missing.pem"))
+	require.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "garbage.pem")
+	require.NoError(t, os.WriteFile(path, []byte("not a pem"), 0o600))
+	_, err = LoadPublicKey(path)
+	require.Error(t, err)
+}
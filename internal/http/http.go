@@ -2,16 +2,64 @@
 package http
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// nonceSize размер nonce подписи запроса в байтах.
+const nonceSize = 16
+
 const (
 	// HashSHA256Header имя хедера HashSHA256.
 	HashSHA256Header = "HashSHA256"
+	// EncryptionHeader имя хедера с алгоритмом шифрования тела запроса.
+	EncryptionHeader = "X-Encryption"
+	// EncryptionRSAAESGCM гибридное шифрование RSA-OAEP + AES-256-GCM.
+	EncryptionRSAAESGCM = "rsa-oaep-aes256gcm"
+	// RealIPHeader имя хедера с IP агента.
+	RealIPHeader = "X-Real-IP"
+	// TimestampHeader имя хедера с временем подписи запроса (unix-секунды).
+	TimestampHeader = "X-Timestamp"
+	// NonceHeader имя хедера с одноразовым значением подписи запроса.
+	NonceHeader = "X-Nonce"
+	// KeyIDHeader имя хедера с идентификатором ключа подписи.
+	KeyIDHeader = "X-Key-Id"
+	// TenantHeader имя хедера с тенантом запроса.
+	TenantHeader = "X-Tenant"
 )
 
+// NewNonce время подписи (unix-секунды) и случайный nonce в hex для защиты от повтора запроса.
+func NewNonce() (timestamp, nonce string, err error) {
+	b := make([]byte, nonceSize)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	return strconv.FormatInt(time.Now().Unix(), 10), hex.EncodeToString(b), nil
+}
+
+// RequestCheckSum подпись запроса: HMAC-SHA256 от тела, времени и nonce.
+func RequestCheckSum(body []byte, timestamp, nonce, key string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+	if timestamp != "" || nonce != "" {
+		mac.Write([]byte("\n" + timestamp + "\n" + nonce))
+	}
+	return mac.Sum(nil)
+}
+
+// ResponseCheckSum подпись ответа: HMAC-SHA256 от тела.
+func ResponseCheckSum(body []byte, key string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
 type (
 	// ResponseData статистика по ответу.
 	ResponseData struct {
@@ -26,6 +74,7 @@ type (
 		http.ResponseWriter
 		responseData *ResponseData
 		hashKey      string
+		keyID        string
 	}
 )
 
@@ -55,6 +104,12 @@ func (w *Writer) ResponseData() *ResponseData {
 	return w.responseData
 }
 
+// SetHashKey устанавливает ключ подписи ответа, которым был подписан запрос.
+func (w *Writer) SetHashKey(keyID, key string) {
+	w.keyID = keyID
+	w.hashKey = key
+}
+
 // WithWriter оборачивает исходный src [http.ResponseWriter] в [http.ResponseWriter] возращенный callback функцией.
 func (w *Writer) WithWriter(callback func(src http.ResponseWriter) http.ResponseWriter) {
 	w.ResponseWriter = callback(w.ResponseWriter)
@@ -65,8 +120,11 @@ func (w *Writer) Flush() {
 	rw := w.ResponseWriter
 
 	if w.hashKey != "" {
-		srvCheckSum := sha256.Sum256(append(w.responseData.Body, w.hashKey...))
+		srvCheckSum := ResponseCheckSum(w.responseData.Body, w.hashKey)
 		rw.Header().Set(HashSHA256Header, fmt.Sprintf("%x", srvCheckSum))
+		if w.keyID != "" {
+			rw.Header().Set(KeyIDHeader, w.keyID)
+		}
 	}
 
 	if w.responseData.Status == 0 {
@@ -0,0 +1,105 @@
+package metric
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultBuckets границы бакетов гистограммы по умолчанию (в секундах).
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram гистограмма распределения значений.
+// Counts содержит на один элемент больше, чем Bounds: последний бакет +Inf.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"` // верхние границы бакетов (включительно)
+	Counts []int64   `json:"counts"` // кол-во наблюдений в каждом бакете
+	Sum    float64   `json:"sum"`    // сумма наблюдений
+	Count  int64     `json:"count"`  // кол-во наблюдений
+}
+
+// NewHistogram конструктор. Если bounds пустой, используются DefaultBuckets.
+func NewHistogram(bounds []float64) *Histogram {
+	if len(bounds) == 0 {
+		bounds = DefaultBuckets
+	}
+	b := slices.Clone(bounds)
+	slices.Sort(b)
+
+	return &Histogram{
+		Bounds: b,
+		Counts: make([]int64, len(b)+1),
+	}
+}
+
+// Observe добавляет наблюдение в гистограмму.
+func (h *Histogram) Observe(v float64) {
+	i, _ := slices.BinarySearch(h.Bounds, v)
+	h.Counts[i]++
+	h.Sum += v
+	h.Count++
+}
+
+// Validate проверяет согласованность границ и счетчиков.
+func (h *Histogram) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("histogram counts len %d, want %d", len(h.Counts), len(h.Bounds)+1)
+	}
+	for i := 1; i < len(h.Bounds); i++ {
+		if h.Bounds[i] <= h.Bounds[i-1] {
+			return fmt.Errorf("histogram bounds are not strictly increasing")
+		}
+	}
+	return nil
+}
+
+// SameBounds проверяет совпадение границ бакетов.
+func (h *Histogram) SameBounds(o *Histogram) bool {
+	return o != nil && slices.Equal(h.Bounds, o.Bounds)
+}
+
+// Merge добавляет к гистограмме наблюдения из o.
+// Если границы бакетов не совпадают, гистограмма заменяется на o.
+func (h *Histogram) Merge(o *Histogram) {
+	if o == nil {
+		return
+	}
+	if !h.SameBounds(o) {
+		*h = *o.Clone()
+		return
+	}
+	for i := range h.Counts {
+		h.Counts[i] += o.Counts[i]
+	}
+	h.Sum += o.Sum
+	h.Count += o.Count
+}
+
+// Clone копия гистограммы.
+func (h *Histogram) Clone() *Histogram {
+	if h == nil {
+		return nil
+	}
+	return &Histogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: slices.Clone(h.Counts),
+		Sum:    h.Sum,
+		Count:  h.Count,
+	}
+}
+
+// String гистограмма в строку.
+func (h *Histogram) String() string {
+	var sb strings.Builder
+	sb.WriteString("count=" + strconv.FormatInt(h.Count, 10))
+	sb.WriteString(" sum=" + strconv.FormatFloat(h.Sum, 'g', -1, 64))
+	for i, c := range h.Counts {
+		le := "+Inf"
+		if i < len(h.Bounds) {
+			le = strconv.FormatFloat(h.Bounds[i], 'g', -1, 64)
+		}
+		sb.WriteString(" le_" + le + "=" + strconv.FormatInt(c, 10))
+	}
+	return sb.String()
+}
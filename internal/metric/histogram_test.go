@@ -0,0 +1,99 @@
+package metric
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram_Observe(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds []float64
+		values []float64
+		want   *Histogram
+	}{
+		{
+			name:   "Positive_test_bucket_bounds_inclusive",
+			bounds: []float64{1, 0.5},
+			values: []float64{0.1, 0.5, 0.7, 1, 3},
+			want: &Histogram{
+				Bounds: []float64{0.5, 1},
+				Counts: []int64{2, 2, 1},
+				Sum:    5.3,
+				Count:  5,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistogram(tt.bounds)
+			for _, v := range tt.values {
+				h.Observe(v)
+			}
+			assert.Equal(t, tt.want.Bounds, h.Bounds)
+			assert.Equal(t, tt.want.Counts, h.Counts)
+			assert.Equal(t, tt.want.Count, h.Count)
+			assert.InDelta(t, tt.want.Sum, h.Sum, 1e-9)
+		})
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	tests := []struct {
+		name string
+		h    *Histogram
+		o    *Histogram
+		want *Histogram
+	}{
+		{
+			name: "Positive_test_same_bounds",
+			h:    &Histogram{Bounds: []float64{1, 2}, Counts: []int64{1, 2, 3}, Sum: 10, Count: 6},
+			o:    &Histogram{Bounds: []float64{1, 2}, Counts: []int64{1, 0, 1}, Sum: 4, Count: 2},
+			want: &Histogram{Bounds: []float64{1, 2}, Counts: []int64{2, 2, 4}, Sum: 14, Count: 8},
+		},
+		{
+			name: "Positive_test_other_bounds_replaced",
+			h:    &Histogram{Bounds: []float64{1, 2}, Counts: []int64{1, 2, 3}, Sum: 10, Count: 6},
+			o:    &Histogram{Bounds: []float64{5}, Counts: []int64{1, 1}, Sum: 7, Count: 2},
+			want: &Histogram{Bounds: []float64{5}, Counts: []int64{1, 1}, Sum: 7, Count: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.h.Merge(tt.o)
+			assert.Equal(t, tt.want, tt.h)
+		})
+	}
+}
+
+func TestHistogram_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       *Histogram
+		wantErr bool
+	}{
+		{
+			name: "Positive_test",
+			h:    &Histogram{Bounds: []float64{1, 2}, Counts: []int64{0, 0, 0}},
+		},
+		{
+			name:    "Negative_test_counts_len",
+			h:       &Histogram{Bounds: []float64{1, 2}, Counts: []int64{0, 0}},
+			wantErr: true,
+		},
+		{
+			name:    "Negative_test_unsorted_bounds",
+			h:       &Histogram{Bounds: []float64{2, 1}, Counts: []int64{0, 0, 0}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.h.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package metric
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Labels метки метрики.
+type Labels map[string]string
+
+// String каноничная форма меток: отсортированные по имени пары name="value" через запятую.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for i, k := range slices.Sorted(maps.Keys(l)) {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(l[k]))
+	}
+	return sb.String()
+}
+
+// Validate проверяет имена меток.
+func (l Labels) Validate() error {
+	for k := range l {
+		if !labelNameRe.MatchString(k) {
+			return fmt.Errorf("invalid label name: %q", k)
+		}
+	}
+	return nil
+}
+
+// SanitizeLabelName приводит имя к правилам имени метки [a-zA-Z_][a-zA-Z0-9_]*,
+// заменяя недопустимые символы на '_'. Для имен из протоколов, где они шире (Influx, Graphite).
+func SanitizeLabelName(name string) string {
+	if name == "" {
+		return "_"
+	}
+
+	b := []byte(name)
+	for i, c := range b {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			continue
+		}
+		b[i] = '_'
+	}
+	// ведущая цифра сохраняется, перед ней добавляется '_'.
+	if b[0] >= '0' && b[0] <= '9' {
+		b = append([]byte{'_'}, b...)
+	}
+	return string(b)
+}
+
+// Clone копия меток.
+func (l Labels) Clone() Labels {
+	if len(l) == 0 {
+		return nil
+	}
+	return maps.Clone(l)
+}
+
+// Merge возвращает копию меток, дополненную метками o. Совпадающие имена не перезаписываются.
+func (l Labels) Merge(o Labels) Labels {
+	if len(o) == 0 {
+		return l.Clone()
+	}
+	merged := make(Labels, len(l)+len(o))
+	maps.Copy(merged, o)
+	maps.Copy(merged, l)
+	return merged
+}
+
+// ParseLabels разбирает метки из строки вида name=value,name2="value2".
+// Принимает как каноничную форму из [Labels.String], так и значения без кавычек.
+func ParseLabels(s string) (Labels, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	labels := make(Labels)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid labels: missing '=' in %q", s)
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " ")
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			q, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid labels: %w", err)
+			}
+			if value, err = strconv.Unquote(q); err != nil {
+				return nil, fmt.Errorf("invalid labels: %w", err)
+			}
+			s = strings.TrimLeft(s[len(q):], " ")
+			if s != "" && s[0] != ',' {
+				return nil, fmt.Errorf("invalid labels: unexpected %q", s)
+			}
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		s = strings.TrimPrefix(s, ",")
+
+		labels[name] = value
+	}
+
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
@@ -0,0 +1,105 @@
+package metric
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Labels
+		wantErr bool
+	}{
+		{
+			name: "Positive_test_plain_values",
+			s:    "host=web-1, env=prod",
+			want: Labels{"host": "web-1", "env": "prod"},
+		},
+		{
+			name: "Positive_test_canonical_form",
+			s:    `env="prod",host="a,b=\"c\""`,
+			want: Labels{"host": `a,b="c"`, "env": "prod"},
+		},
+		{
+			name: "Positive_test_empty",
+			s:    "",
+			want: nil,
+		},
+		{
+			name:    "Negative_test_missing_value",
+			s:       "host",
+			wantErr: true,
+		},
+		{
+			name:    "Negative_test_invalid_name",
+			s:       "1host=a",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabels(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLabels() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLabels_String(t *testing.T) {
+	l := Labels{"host": `a,b="c"`, "env": "prod"}
+	assert.Equal(t, `env="prod",host="a,b=\"c\""`, l.String())
+
+	parsed, err := ParseLabels(l.String())
+	assert.NoError(t, err)
+	assert.Equal(t, l, parsed)
+}
+
+func TestSanitizeLabelName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "valid", in: "host_name", want: "host_name"},
+		{name: "dots_and_dashes", in: "dc.zone-a", want: "dc_zone_a"},
+		{name: "colon", in: "a:b", want: "a_b"},
+		{name: "leading_digit", in: "1st", want: "_1st"},
+		{name: "empty", in: "", want: "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeLabelName(tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, Labels{got: "v"}.Validate())
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels Labels
+		want   string
+	}{
+		{
+			name: "Positive_test_without_labels",
+			want: "gauge:Alloc",
+		},
+		{
+			name:   "Positive_test_with_labels",
+			labels: Labels{"host": "b", "env": "a"},
+			want:   `gauge:Alloc{env="a",host="b"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Key("gauge", "Alloc", tt.labels))
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"runtime"
 	"strconv"
+	"time"
 
 	"github.com/ktigay/metrics-collector/internal/server/errors"
 )
@@ -53,15 +54,98 @@ const (
 	FreeMemory      GaugeMetric = "FreeMemory"
 	CPUutilization1 GaugeMetric = "CPUutilization1"
 
+	// CPUUser доля времени CPU в пользовательском режиме, %.
+	CPUUser GaugeMetric = "CPUUser"
+	// CPUSystem доля времени CPU в режиме ядра, %.
+	CPUSystem GaugeMetric = "CPUSystem"
+	// CPUIowait доля времени ожидания ввода-вывода, %.
+	CPUIowait GaugeMetric = "CPUIowait"
+	// CPUSteal доля времени, отданного гипервизором другим ВМ, %.
+	CPUSteal GaugeMetric = "CPUSteal"
+	// Load1 средняя загрузка за 1 минуту.
+	Load1 GaugeMetric = "Load1"
+	// Load5 средняя загрузка за 5 минут.
+	Load5 GaugeMetric = "Load5"
+	// Load15 средняя загрузка за 15 минут.
+	Load15 GaugeMetric = "Load15"
+
+	// DiskTotal размер файловой системы в байтах.
+	DiskTotal GaugeMetric = "DiskTotal"
+	// DiskFree свободно байт.
+	DiskFree GaugeMetric = "DiskFree"
+	// DiskUsed занято байт.
+	DiskUsed GaugeMetric = "DiskUsed"
+	// DiskInodesTotal кол-во inode.
+	DiskInodesTotal GaugeMetric = "DiskInodesTotal"
+	// DiskInodesFree кол-во свободных inode.
+	DiskInodesFree GaugeMetric = "DiskInodesFree"
+	// DiskInodesUsed кол-во занятых inode.
+	DiskInodesUsed GaugeMetric = "DiskInodesUsed"
+	// TCPConnections кол-во TCP-соединений в состоянии.
+	TCPConnections GaugeMetric = "TCPConnections"
+
+	// CgroupMemoryUsage память, используемая cgroup, в байтах.
+	CgroupMemoryUsage GaugeMetric = "CgroupMemoryUsage"
+	// CgroupMemoryLimit ограничение памяти cgroup в байтах.
+	CgroupMemoryLimit GaugeMetric = "CgroupMemoryLimit"
+	// CgroupPids кол-во процессов cgroup.
+	CgroupPids GaugeMetric = "CgroupPids"
+	// CgroupPidsLimit ограничение кол-ва процессов cgroup.
+	CgroupPidsLimit GaugeMetric = "CgroupPidsLimit"
+	// CgroupIOPressure доля времени ожидания ввода-вывода (PSI), %.
+	CgroupIOPressure GaugeMetric = "CgroupIOPressure"
+
 	// TypeGauge тип gauge.
 	TypeGauge Type = "gauge"
 	// TypeCounter тип counter.
 	TypeCounter Type = "counter"
+	// TypeHistogram тип histogram.
+	TypeHistogram Type = "histogram"
 
 	// RandomValue рандомное число gauge.RandomValue.
 	RandomValue string = "RandomValue"
 	// PollCount counter.PollCount.
 	PollCount string = "PollCount"
+
+	// ContextSwitches кол-во переключений контекста.
+	ContextSwitches string = "ContextSwitches"
+
+	// DiskReadBytes прочитано байт с устройства.
+	DiskReadBytes string = "DiskReadBytes"
+	// DiskWriteBytes записано байт на устройство.
+	DiskWriteBytes string = "DiskWriteBytes"
+	// DiskReadOps кол-во операций чтения.
+	DiskReadOps string = "DiskReadOps"
+	// DiskWriteOps кол-во операций записи.
+	DiskWriteOps string = "DiskWriteOps"
+
+	// CgroupCPUUsage процессорное время cgroup, мкс.
+	CgroupCPUUsage string = "CgroupCPUUsage"
+	// CgroupCPUPeriods кол-во периодов планировщика CFS.
+	CgroupCPUPeriods string = "CgroupCPUPeriods"
+	// CgroupCPUThrottled кол-во периодов, в которых cgroup была ограничена.
+	CgroupCPUThrottled string = "CgroupCPUThrottled"
+	// CgroupCPUThrottledTime время ограничения cgroup, мкс.
+	CgroupCPUThrottledTime string = "CgroupCPUThrottledTime"
+	// CgroupIOStall время ожидания ввода-вывода (PSI), мкс.
+	CgroupIOStall string = "CgroupIOStall"
+
+	// NetBytesRecv получено байт через интерфейс.
+	NetBytesRecv string = "NetBytesRecv"
+	// NetBytesSent отправлено байт через интерфейс.
+	NetBytesSent string = "NetBytesSent"
+	// NetPacketsRecv получено пакетов.
+	NetPacketsRecv string = "NetPacketsRecv"
+	// NetPacketsSent отправлено пакетов.
+	NetPacketsSent string = "NetPacketsSent"
+	// NetErrIn ошибок приема.
+	NetErrIn string = "NetErrIn"
+	// NetErrOut ошибок отправки.
+	NetErrOut string = "NetErrOut"
+	// NetDropIn отброшено входящих пакетов.
+	NetDropIn string = "NetDropIn"
+	// NetDropOut отброшено исходящих пакетов.
+	NetDropOut string = "NetDropOut"
 )
 
 // String название метрики в строку.
@@ -69,12 +153,19 @@ func (m GaugeMetric) String() string {
 	return string(m)
 }
 
+// CPUutilization загрузка ядра CPU с номером n, начиная с 1.
+func CPUutilization(n int) GaugeMetric {
+	return GaugeMetric("CPUutilization" + strconv.Itoa(n))
+}
+
 // Metrics структура для обновления метрик.
 type Metrics struct {
-	ID    string   `json:"id"`              // имя метрики
-	Type  string   `json:"type"`            // параметр, принимающий значение gauge или counter
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+	ID        string     `json:"id"`                  // имя метрики
+	Type      string     `json:"type"`                // параметр, принимающий значение gauge, counter или histogram
+	Delta     *int64     `json:"delta,omitempty"`     // значение метрики в случае передачи counter
+	Value     *float64   `json:"value,omitempty"`     // значение метрики в случае передачи gauge
+	Histogram *Histogram `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+	Labels    Labels     `json:"labels,omitempty"`    // метки метрики
 }
 
 // ValueByType значение метрики в зависимости от типа.
@@ -88,6 +179,10 @@ func (m *Metrics) ValueByType() any {
 		if m.Delta != nil {
 			return *m.Delta
 		}
+	case string(TypeHistogram):
+		if m.Histogram != nil {
+			return m.Histogram
+		}
 	}
 	return nil
 }
@@ -110,7 +205,7 @@ func (m *Metrics) GetValue() float64 {
 
 // Key ключ метрики.
 func (m *Metrics) Key() string {
-	return Key(m.Type, m.ID)
+	return Key(m.Type, m.ID, m.Labels)
 }
 
 // SetValueByType присваивает значение в зависимости от типа метрики.
@@ -143,6 +238,24 @@ func (m *Metrics) SetValueByType(v any) error {
 		default:
 			return errors.ErrInvalidValueType
 		}
+	case TypeHistogram:
+		switch vt := v.(type) {
+		case string:
+			// одиночное наблюдение раскладывается по бакетам по умолчанию.
+			var vts float64
+			if vts, err = strconv.ParseFloat(vt, 64); err != nil {
+				return errors.ErrWrongValue
+			}
+			m.Histogram = NewHistogram(nil)
+			m.Histogram.Observe(vts)
+		case *Histogram:
+			if vt == nil || vt.Validate() != nil {
+				return errors.ErrWrongValue
+			}
+			m.Histogram = vt
+		default:
+			return errors.ErrInvalidValueType
+		}
 	}
 
 	return nil
@@ -155,14 +268,19 @@ func ResolveType(s string) (m Type, err error) {
 		return TypeGauge, nil
 	case string(TypeCounter):
 		return TypeCounter, nil
+	case string(TypeHistogram):
+		return TypeHistogram, nil
 	default:
 		return m, fmt.Errorf("unknown metric type: %s", s)
 	}
 }
 
-// Key возвращает ключ по типу и наименованию метрики.
-func Key(mType, mName string) string {
-	return fmt.Sprintf("%s:%s", mType, mName)
+// Key возвращает ключ по типу, наименованию и меткам метрики.
+func Key(mType, mName string, labels Labels) string {
+	if len(labels) == 0 {
+		return fmt.Sprintf("%s:%s", mType, mName)
+	}
+	return fmt.Sprintf("%s:%s{%s}", mType, mName, labels)
 }
 
 // MapGaugeFromMemStats преобразует метрики из runtime в map.
@@ -197,3 +315,14 @@ func MapGaugeFromMemStats(m runtime.MemStats) map[GaugeMetric]float64 {
 		TotalAlloc:    float64(m.TotalAlloc),
 	}
 }
+
+// Sample отсчет метрики во времени или агрегат отсчетов за интервал.
+type Sample struct {
+	Time  time.Time `json:"time"`            // время получения или начало интервала агрегата
+	Delta *int64    `json:"delta,omitempty"` // дельта counter или кол-во наблюдений histogram
+	Value *float64  `json:"value,omitempty"` // значение gauge или сумма наблюдений histogram, для агрегата - последнее
+	Min   *float64  `json:"min,omitempty"`   // минимальное значение за интервал агрегата
+	Max   *float64  `json:"max,omitempty"`   // максимальное значение за интервал агрегата
+	Avg   *float64  `json:"avg,omitempty"`   // среднее значение за интервал агрегата
+	Count *int64    `json:"count,omitempty"` // кол-во отсчетов в агрегате
+}
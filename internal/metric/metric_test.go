@@ -25,6 +25,14 @@ func TestResolveType(t *testing.T) {
 			wantM:   TypeGauge,
 			wantErr: false,
 		},
+		{
+			name: "Positive_test_histogram",
+			args: args{
+				s: "histogram",
+			},
+			wantM:   TypeHistogram,
+			wantErr: false,
+		},
 		{
 			name: "Negative test",
 			args: args{
@@ -0,0 +1,74 @@
+package proto
+
+import (
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+var (
+	typeToProto = map[metric.Type]Metric_MType{
+		metric.TypeGauge:     Metric_GAUGE,
+		metric.TypeCounter:   Metric_COUNTER,
+		metric.TypeHistogram: Metric_HISTOGRAM,
+	}
+	typeFromProto = map[Metric_MType]metric.Type{
+		Metric_GAUGE:     metric.TypeGauge,
+		Metric_COUNTER:   metric.TypeCounter,
+		Metric_HISTOGRAM: metric.TypeHistogram,
+	}
+)
+
+// FromMetrics конвертирует метрику в protobuf-сообщение.
+func FromMetrics(m metric.Metrics) *Metric {
+	pm := &Metric{
+		Id:     m.ID,
+		Type:   typeToProto[metric.Type(m.Type)],
+		Labels: m.Labels,
+	}
+	if m.Delta != nil {
+		pm.Delta = *m.Delta
+	}
+	if m.Value != nil {
+		pm.Value = *m.Value
+	}
+	if h := m.Histogram; h != nil {
+		pm.Histogram = &Histogram{
+			Bounds: h.Bounds,
+			Counts: h.Counts,
+			Sum:    h.Sum,
+			Count:  h.Count,
+		}
+	}
+	return pm
+}
+
+// ToMetrics конвертирует protobuf-сообщение в метрику.
+// Заполняется только поле значения, соответствующее типу.
+func (x *Metric) ToMetrics() metric.Metrics {
+	t := typeFromProto[x.GetType()]
+	m := metric.Metrics{
+		ID:   x.GetId(),
+		Type: string(t),
+	}
+	if len(x.GetLabels()) > 0 {
+		m.Labels = x.GetLabels()
+	}
+
+	switch t {
+	case metric.TypeCounter:
+		d := x.GetDelta()
+		m.Delta = &d
+	case metric.TypeGauge:
+		v := x.GetValue()
+		m.Value = &v
+	case metric.TypeHistogram:
+		if h := x.GetHistogram(); h != nil {
+			m.Histogram = &metric.Histogram{
+				Bounds: h.GetBounds(),
+				Counts: h.GetCounts(),
+				Sum:    h.GetSum(),
+				Count:  h.GetCount(),
+			}
+		}
+	}
+	return m
+}
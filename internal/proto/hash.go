@@ -0,0 +1,74 @@
+package proto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"strings"
+
+	gproto "google.golang.org/protobuf/proto"
+
+	h "github.com/ktigay/metrics-collector/internal/http"
+)
+
+var (
+	// HashMetadataKey ключ метаданных с подписью запроса.
+	HashMetadataKey = strings.ToLower(h.HashSHA256Header)
+	// KeyIDMetadataKey ключ метаданных с идентификатором ключа подписи.
+	KeyIDMetadataKey = strings.ToLower(h.KeyIDHeader)
+	// TenantMetadataKey ключ метаданных с тенантом.
+	TenantMetadataKey = strings.ToLower(h.TenantHeader)
+	// TimestampMetadataKey ключ метаданных со временем подписи.
+	TimestampMetadataKey = strings.ToLower(h.TimestampHeader)
+	// NonceMetadataKey ключ метаданных с nonce подписи.
+	NonceMetadataKey = strings.ToLower(h.NonceHeader)
+)
+
+var marshalOpts = gproto.MarshalOptions{Deterministic: true}
+
+// Hasher подпись последовательности сообщений: HMAC-SHA256 от их детерминированной сериализации,
+// времени и nonce, как в [h.RequestCheckSum].
+type Hasher struct {
+	h         hash.Hash
+	timestamp string
+	nonce     string
+}
+
+// NewHasher конструктор.
+func NewHasher(key, timestamp, nonce string) *Hasher {
+	return &Hasher{
+		h:         hmac.New(sha256.New, []byte(key)),
+		timestamp: timestamp,
+		nonce:     nonce,
+	}
+}
+
+// Write добавляет сообщение в подпись.
+func (s *Hasher) Write(m gproto.Message) error {
+	b, err := marshalOpts.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = s.h.Write(b)
+	return err
+}
+
+// Sum подпись в hex.
+func (s *Hasher) Sum() string {
+	if s.timestamp != "" || s.nonce != "" {
+		_, _ = s.h.Write([]byte("\n" + s.timestamp + "\n" + s.nonce))
+	}
+	return fmt.Sprintf("%x", s.h.Sum(nil))
+}
+
+// Hash подпись сообщений ключом key со временем timestamp и nonce.
+func Hash(key, timestamp, nonce string, msgs ...gproto.Message) (string, error) {
+	s := NewHasher(key, timestamp, nonce)
+	for _, m := range msgs {
+		if err := s.Write(m); err != nil {
+			return "", err
+		}
+	}
+	return s.Sum(), nil
+}
@@ -0,0 +1,68 @@
+// Package auth Аутентификация агентов по токенам.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Agent идентичность агента.
+type Agent struct {
+	// Name имя агента.
+	Name string `json:"name"`
+	// Prefixes разрешенные префиксы имен метрик (пусто - любые).
+	Prefixes []string `json:"prefixes"`
+	// Tenant тенант агента (пусто - из запроса).
+	Tenant string `json:"tenant"`
+}
+
+// Allowed может ли агент писать метрику name.
+func (a *Agent) Allowed(name string) bool {
+	if len(a.Prefixes) == 0 {
+		return true
+	}
+	for _, p := range a.Prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Store хранилище токенов агентов.
+//
+//go:generate mockgen -destination=./mocks/mock_store.go -package=mocks github.com/ktigay/metrics-collector/internal/server/auth Store
+type Store interface {
+	// Lookup агент по токену, nil - токен неизвестен.
+	Lookup(ctx context.Context, token string) (*Agent, error)
+}
+
+type agentKey struct{}
+
+// WithAgent контекст с агентом.
+func WithAgent(ctx context.Context, a *Agent) context.Context {
+	return context.WithValue(ctx, agentKey{}, a)
+}
+
+// AgentFromContext агент из контекста.
+func AgentFromContext(ctx context.Context) (*Agent, bool) {
+	a, ok := ctx.Value(agentKey{}).(*Agent)
+	return a, ok && a != nil
+}
+
+// TokenFromHeader токен из значения хедера Authorization: Bearer <token>.
+func TokenFromHeader(h string) string {
+	scheme, token, ok := strings.Cut(h, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// HashToken хеш токена для хранения в БД.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
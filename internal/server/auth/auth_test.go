@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_Allowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes []string
+		metric   string
+		want     bool
+	}{
+		{name: "Positive_test_prefix", prefixes: []string{"billing_", "app_"}, metric: "app_requests", want: true},
+		{name: "Positive_test_no_prefixes", metric: "Alloc", want: true},
+		{name: "Negative_test_other_prefix", prefixes: []string{"billing_"}, metric: "Alloc", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Agent{Name: "agent", Prefixes: tt.prefixes}
+			require.Equal(t, tt.want, a.Allowed(tt.metric))
+		})
+	}
+}
+
+func TestTokenFromHeader(t *testing.T) {
+	require.Equal(t, "abc", TokenFromHeader("Bearer abc"))
+	require.Equal(t, "abc", TokenFromHeader("bearer  abc "))
+	require.Empty(t, TokenFromHeader("Basic abc"))
+	require.Empty(t, TokenFromHeader("abc"))
+	require.Empty(t, TokenFromHeader(""))
+}
+
+func TestAgentFromContext(t *testing.T) {
+	_, ok := AgentFromContext(context.Background())
+	require.False(t, ok)
+
+	a := &Agent{Name: "agent"}
+	got, ok := AgentFromContext(WithAgent(context.Background(), a))
+	require.True(t, ok)
+	require.Same(t, a, got)
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"token": "t1", "name": "web-1", "prefixes": ["app_"]},
+		{"token": "t2", "name": "billing"}
+	]`), 0o600))
+
+	s, err := NewFileStore(path)
+	require.NoError(t, err)
+
+	a, err := s.Lookup(context.Background(), "t1")
+	require.NoError(t, err)
+	require.Equal(t, &Agent{Name: "web-1", Prefixes: []string{"app_"}}, a)
+
+	a, err = s.Lookup(context.Background(), "unknown")
+	require.NoError(t, err)
+	require.Nil(t, a)
+
+	dup := filepath.Join(dir, "dup.json")
+	require.NoError(t, os.WriteFile(dup, []byte(`[{"token": "t", "name": "a"}, {"token": "t", "name": "b"}]`), 0o600))
+	_, err = NewFileStore(dup)
+	require.Error(t, err)
+
+	noName := filepath.Join(dir, "noname.json")
+	require.NoError(t, os.WriteFile(noName, []byte(`[{"token": "t"}]`), 0o600))
+	_, err = NewFileStore(noName)
+	require.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	findAgentQuery = `SELECT "name", "prefixes", "tenant" FROM agents WHERE "token_hash" = $1`
+	timeout        = 1 * time.Second
+)
+
+// DBStore токены агентов из таблицы agents. В таблице хранится sha256-хеш токена.
+type DBStore struct {
+	db *sql.DB
+}
+
+// NewDBStore конструктор.
+func NewDBStore(db *sql.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// Lookup агент по токену.
+func (s *DBStore) Lookup(ctx context.Context, token string) (*Agent, error) {
+	c, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var a Agent
+	err := s.db.QueryRowContext(c, findAgentQuery, HashToken(token)).
+		Scan(&a.Name, pgtype.NewMap().SQLScanner(&a.Prefixes), &a.Tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FileStore токены агентов из JSON-файла.
+type FileStore struct {
+	agents map[string]*Agent
+}
+
+type fileEntry struct {
+	Agent
+	Token string `json:"token"`
+}
+
+// NewFileStore загружает токены из файла вида [{"token": "...", "name": "...", "prefixes": ["..."]}].
+func NewFileStore(path string) (*FileStore, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fileEntry
+	if err = json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	s := &FileStore{agents: make(map[string]*Agent, len(entries))}
+	for _, e := range entries {
+		if e.Token == "" || e.Name == "" {
+			return nil, fmt.Errorf("%s: token and name are required", path)
+		}
+		if _, ok := s.agents[e.Token]; ok {
+			return nil, fmt.Errorf("%s: duplicate token of agent %s", path, e.Name)
+		}
+		a := e.Agent
+		s.agents[e.Token] = &a
+	}
+	return s, nil
+}
+
+// Lookup агент по токену.
+func (s *FileStore) Lookup(_ context.Context, token string) (*Agent, error) {
+	return s.agents[token], nil
+}
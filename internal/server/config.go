@@ -3,8 +3,14 @@ package server
 import (
 	"flag"
 	"fmt"
+	"net"
+	"time"
 
 	"github.com/caarlos0/env/v6"
+
+	"github.com/ktigay/metrics-collector/internal/server/keyring"
+	"github.com/ktigay/metrics-collector/internal/server/repository"
+	"github.com/ktigay/metrics-collector/internal/server/subnet"
 )
 
 const (
@@ -16,6 +22,36 @@ const (
 	defaultDatabaseDSN     = ""
 	defaultDatabaseDriver  = "pgx"
 	defaultHashKey         = ""
+	defaultHistoryEnabled  = false
+	defaultHistorySize     = 1000
+	defaultCompactInterval = time.Minute
+	defaultRetentionRaw    = time.Hour
+	defaultRetentionMinute = 24 * time.Hour
+	defaultRetentionHour   = 30 * 24 * time.Hour
+	defaultStatsdAddress   = ""
+	defaultStatsdFlush     = 10 * time.Second
+	defaultGraphiteAddress = ""
+	defaultGraphiteTimeout = 30 * time.Second
+	defaultGraphiteMaxLine = 4096
+	defaultGRPCAddress     = ""
+	defaultCryptoKey       = ""
+	defaultTLSCert         = ""
+	defaultTLSKey          = ""
+	defaultTLSCA           = ""
+	defaultTrustedSubnet   = ""
+	defaultTrustedProxies  = ""
+	defaultReplayWindow    = 5 * time.Minute
+	defaultNonceCacheSize  = 100000
+	defaultHashKeys        = ""
+	defaultHashKeyFile     = ""
+	defaultAgentTokensFile = ""
+	defaultAgentAuthDB     = false
+	defaultAllowAnonListen = false
+	defaultTenantMaxMetric = 0
+	defaultClientRate      = 0
+	defaultClientBurst     = 10
+	defaultMaxBodySize     = 10 << 20
+	defaultMaxUnpackedSize = 50 << 20
 )
 
 // Config конфигурация сервера.
@@ -28,6 +64,123 @@ type Config struct {
 	DatabaseDSN     string `env:"DATABASE_DSN"`
 	DatabaseDriver  string `env:"DATABASE_DRIVER"`
 	HashKey         string `env:"KEY"`
+	HistoryEnabled  bool   `env:"HISTORY_ENABLED"`
+	HistorySize     int    `env:"HISTORY_SIZE"`
+	// CompactInterval интервал свертки истории.
+	CompactInterval time.Duration `env:"COMPACT_INTERVAL"`
+	// RetentionRaw возраст, после которого отсчеты сворачиваются в минутные агрегаты.
+	RetentionRaw time.Duration `env:"RETENTION_RAW"`
+	// RetentionMinute возраст, после которого минутные агрегаты сворачиваются в часовые.
+	RetentionMinute time.Duration `env:"RETENTION_MINUTE"`
+	// RetentionHour возраст, после которого удаляются часовые агрегаты (0 - не удалять).
+	RetentionHour time.Duration `env:"RETENTION_HOUR"`
+	// StatsdAddress адрес UDP-сервера StatsD (пусто - не запускать).
+	StatsdAddress string `env:"STATSD_ADDRESS"`
+	// StatsdFlushInterval интервал сброса метрик StatsD.
+	StatsdFlushInterval time.Duration `env:"STATSD_FLUSH_INTERVAL"`
+	// GraphiteAddress адрес TCP-сервера Graphite (пусто - не запускать).
+	GraphiteAddress string `env:"GRAPHITE_ADDRESS"`
+	// GraphiteReadTimeout таймаут чтения строки из соединения Graphite.
+	GraphiteReadTimeout time.Duration `env:"GRAPHITE_READ_TIMEOUT"`
+	// GraphiteMaxLineLength максимальная длина строки Graphite в байтах.
+	GraphiteMaxLineLength int `env:"GRAPHITE_MAX_LINE_LENGTH"`
+	// GRPCAddress адрес gRPC-сервера (пусто - не запускать).
+	GRPCAddress string `env:"GRPC_ADDRESS"`
+	// CryptoKey путь к приватному ключу RSA для расшифровки тела запросов.
+	CryptoKey string `env:"CRYPTO_KEY"`
+	// TLSCert путь к сертификату сервера (пусто - HTTP без TLS).
+	TLSCert string `env:"TLS_CERT"`
+	// TLSKey путь к ключу сертификата сервера.
+	TLSKey string `env:"TLS_KEY"`
+	// TLSCA путь к CA клиентских сертификатов (задан - требовать mTLS).
+	TLSCA string `env:"TLS_CA"`
+	// TrustedSubnet доверенные подсети агентов в CIDR через запятую (пусто - без ограничений).
+	// Проверяется на всех эндпоинтах приема метрик: HTTP, gRPC, StatsD и Graphite.
+	TrustedSubnet string `env:"TRUSTED_SUBNET"`
+	// TrustedProxies подсети обратных прокси в CIDR через запятую: только от них
+	// принимается хедер X-Real-IP при ограничении частоты запросов по IP.
+	TrustedProxies string `env:"TRUSTED_PROXIES"`
+	// ReplayWindow допустимое расхождение времени подписи запроса (0 - без защиты от повтора).
+	ReplayWindow time.Duration `env:"REPLAY_WINDOW"`
+	// NonceCacheSize максимальное количество запоминаемых nonce. При заполнении подписанные запросы отклоняются (503).
+	NonceCacheSize int `env:"NONCE_CACHE_SIZE"`
+	// HashKeys активные ключи подписи с идентификаторами: id1:key1,id2:key2.
+	HashKeys string `env:"HASH_KEYS"`
+	// HashKeyFile путь к файлу активных ключей подписи: по одному "id key" на строку.
+	HashKeyFile string `env:"HASH_KEY_FILE"`
+	// AgentTokensFile путь к JSON-файлу токенов агентов (задан - эндпоинты записи требуют токен).
+	AgentTokensFile string `env:"AGENT_TOKENS_FILE"`
+	// AgentAuthDB брать токены агентов из таблицы agents БД.
+	AgentAuthDB bool `env:"AGENT_AUTH_DB"`
+	// AllowUnauthenticatedListeners разрешить StatsD и Graphite при включенной аутентификации агентов.
+	// Эти протоколы не передают токен: метрики принимаются без агента в тенант по умолчанию,
+	// поэтому без этого флага сервер с аутентификацией их не запускает.
+	AllowUnauthenticatedListeners bool `env:"ALLOW_UNAUTHENTICATED_LISTENERS"`
+	// TenantMaxMetrics максимальное кол-во метрик одного тенанта, 0 - без ограничений.
+	TenantMaxMetrics int `env:"TENANT_MAX_METRICS"`
+	// ClientRateLimit запросов в секунду от одного агента или IP, 0 - без ограничений.
+	ClientRateLimit float64 `env:"CLIENT_RATE_LIMIT"`
+	// ClientRateBurst допустимый всплеск запросов сверх ClientRateLimit.
+	ClientRateBurst int `env:"CLIENT_RATE_BURST"`
+	// MaxBodySize максимальный размер тела запроса в байтах до распаковки, 0 - без ограничений.
+	MaxBodySize int64 `env:"MAX_BODY_SIZE"`
+	// MaxDecompressedSize максимальный размер тела запроса в байтах после распаковки и суммарный размер
+	// потока gRPC UpdateBatch, 0 - без ограничений.
+	MaxDecompressedSize int64 `env:"MAX_DECOMPRESSED_SIZE"`
+}
+
+// RetentionPolicy политика хранения истории.
+func (c *Config) RetentionPolicy() repository.RetentionPolicy {
+	return repository.RetentionPolicy{
+		Raw:    c.RetentionRaw,
+		Minute: c.RetentionMinute,
+		Hour:   c.RetentionHour,
+	}
+}
+
+// IsTLSEnabled обслуживать HTTPS.
+func (c *Config) IsTLSEnabled() bool {
+	return c.TLSCert != ""
+}
+
+// Keyring активные ключи подписи из HashKeys, HashKeyFile и HashKey (для запросов без идентификатора ключа).
+func (c *Config) Keyring() (keyring.Keyring, error) {
+	keys, err := keyring.Parse(c.HashKeys)
+	if err != nil {
+		return nil, err
+	}
+	if c.HashKeyFile != "" {
+		fileKeys, err := keyring.LoadFile(c.HashKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		for id, key := range fileKeys {
+			if err = keys.Add(id, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c.HashKey != "" {
+		if err = keys.Add(keyring.DefaultID, c.HashKey); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// TrustedSubnets разбирает TrustedSubnet.
+func (c *Config) TrustedSubnets() ([]*net.IPNet, error) {
+	return subnet.Parse(c.TrustedSubnet)
+}
+
+// TrustedProxyNets разбирает TrustedProxies.
+func (c *Config) TrustedProxyNets() ([]*net.IPNet, error) {
+	return subnet.Parse(c.TrustedProxies)
+}
+
+// IsAuthEnabled требовать токен агента на эндпоинтах записи.
+func (c *Config) IsAuthEnabled() bool {
+	return c.AgentTokensFile != "" || c.AgentAuthDB
 }
 
 // IsUseSQLDB использовать БД SQL.
@@ -51,7 +204,38 @@ func InitializeConfig(args []string) (*Config, error) {
 	flags.StringVar(&config.FileStoragePath, "f", defaultFileStoragePath, "file storage path")
 	flags.BoolVar(&config.Restore, "r", defaultRestoreFlag, "restore data from storage")
 	flags.StringVar(&config.DatabaseDSN, "d", defaultDatabaseDSN, "database DSN")
-	flags.StringVar(&config.HashKey, "k", defaultHashKey, "SHA256 hash key")
+	flags.StringVar(&config.HashKey, "k", defaultHashKey, "HMAC-SHA256 key for requests without key id")
+	flags.StringVar(&config.HashKeys, "keys", defaultHashKeys, "active HMAC-SHA256 keys id1:key1,id2:key2")
+	flags.StringVar(&config.HashKeyFile, "key-file", defaultHashKeyFile, "path to file with active HMAC-SHA256 keys, one \"id key\" per line")
+	flags.BoolVar(&config.HistoryEnabled, "history", defaultHistoryEnabled, "store metrics history")
+	flags.IntVar(&config.HistorySize, "history-size", defaultHistorySize, "in-memory history samples per metric")
+	flags.DurationVar(&config.CompactInterval, "compact-interval", defaultCompactInterval, "history compaction interval")
+	flags.DurationVar(&config.RetentionRaw, "retention-raw", defaultRetentionRaw, "raw samples retention")
+	flags.DurationVar(&config.RetentionMinute, "retention-minute", defaultRetentionMinute, "1-minute aggregates retention")
+	flags.DurationVar(&config.RetentionHour, "retention-hour", defaultRetentionHour, "1-hour aggregates retention, 0 to keep forever")
+	flags.StringVar(&config.StatsdAddress, "statsd-address", defaultStatsdAddress, "StatsD UDP address")
+	flags.DurationVar(&config.StatsdFlushInterval, "statsd-flush-interval", defaultStatsdFlush, "StatsD flush interval")
+	flags.StringVar(&config.GraphiteAddress, "graphite-address", defaultGraphiteAddress, "Graphite plaintext TCP address")
+	flags.DurationVar(&config.GraphiteReadTimeout, "graphite-read-timeout", defaultGraphiteTimeout, "Graphite connection read timeout")
+	flags.IntVar(&config.GraphiteMaxLineLength, "graphite-max-line-length", defaultGraphiteMaxLine, "Graphite max line length in bytes")
+	flags.StringVar(&config.GRPCAddress, "grpc-address", defaultGRPCAddress, "gRPC server address")
+	flags.StringVar(&config.CryptoKey, "crypto-key", defaultCryptoKey, "path to RSA private key for payload decryption")
+	flags.StringVar(&config.TLSCert, "tls-cert", defaultTLSCert, "path to TLS certificate")
+	flags.StringVar(&config.TLSKey, "tls-key", defaultTLSKey, "path to TLS certificate key")
+	flags.StringVar(&config.TLSCA, "tls-ca", defaultTLSCA, "path to CA certificate to require and verify client certificates")
+	flags.StringVar(&config.TrustedSubnet, "t", defaultTrustedSubnet, "trusted agent subnets in CIDR, comma separated")
+	flags.StringVar(&config.TrustedProxies, "trusted-proxies", defaultTrustedProxies, "reverse proxy subnets in CIDR allowed to set X-Real-IP, comma separated")
+
+	flags.DurationVar(&config.ReplayWindow, "replay-window", defaultReplayWindow, "allowed clock skew of signed requests, 0 to disable replay protection")
+	flags.IntVar(&config.NonceCacheSize, "nonce-cache-size", defaultNonceCacheSize, "max remembered nonces of signed requests, signed requests are refused while the cache is full")
+	flags.StringVar(&config.AgentTokensFile, "agent-tokens", defaultAgentTokensFile, "path to JSON file with agent tokens")
+	flags.BoolVar(&config.AgentAuthDB, "agent-auth-db", defaultAgentAuthDB, "authenticate agents by tokens from agents table")
+	flags.BoolVar(&config.AllowUnauthenticatedListeners, "allow-unauthenticated-listeners", defaultAllowAnonListen, "run StatsD and Graphite listeners without agent authentication when agent auth is enabled")
+	flags.IntVar(&config.TenantMaxMetrics, "tenant-max-metrics", defaultTenantMaxMetric, "max metrics per tenant (0 - unlimited)")
+	flags.Float64Var(&config.ClientRateLimit, "client-rate-limit", defaultClientRate, "max requests per second from one agent or IP (0 - unlimited)")
+	flags.IntVar(&config.ClientRateBurst, "client-rate-burst", defaultClientBurst, "max burst of requests from one agent or IP")
+	flags.Int64Var(&config.MaxBodySize, "max-body-size", defaultMaxBodySize, "max request body size in bytes (0 - unlimited)")
+	flags.Int64Var(&config.MaxDecompressedSize, "max-decompressed-size", defaultMaxUnpackedSize, "max decompressed request body size in bytes (0 - unlimited)")
 
 	if err = flags.Parse(args); err != nil {
 		return nil, err
@@ -62,6 +246,48 @@ func InitializeConfig(args []string) (*Config, error) {
 	if config.ServerHost == "" {
 		return nil, fmt.Errorf("host flag is required")
 	}
+	if config.HistoryEnabled && config.CompactInterval <= 0 {
+		return nil, fmt.Errorf("compact interval must be positive")
+	}
+	if config.StatsdAddress != "" && config.StatsdFlushInterval <= 0 {
+		return nil, fmt.Errorf("statsd flush interval must be positive")
+	}
+	if config.GraphiteAddress != "" && (config.GraphiteReadTimeout <= 0 || config.GraphiteMaxLineLength <= 0) {
+		return nil, fmt.Errorf("graphite read timeout and max line length must be positive")
+	}
+	if (config.TLSCert == "") != (config.TLSKey == "") {
+		return nil, fmt.Errorf("tls cert and key must be set together")
+	}
+	if config.TLSCA != "" && !config.IsTLSEnabled() {
+		return nil, fmt.Errorf("tls ca requires tls cert and key")
+	}
+	if config.ReplayWindow < 0 || (config.ReplayWindow > 0 && config.NonceCacheSize <= 0) {
+		return nil, fmt.Errorf("replay window must not be negative and nonce cache size must be positive")
+	}
+	if config.AgentAuthDB && (!config.IsUseSQLDB() || config.AgentTokensFile != "") {
+		return nil, fmt.Errorf("agent auth db requires database and excludes agent tokens file")
+	}
+	if config.IsAuthEnabled() && (config.StatsdAddress != "" || config.GraphiteAddress != "") && !config.AllowUnauthenticatedListeners {
+		return nil, fmt.Errorf("statsd and graphite listeners can't authenticate agents, set allow unauthenticated listeners to run them with agent auth")
+	}
+	if config.TenantMaxMetrics < 0 {
+		return nil, fmt.Errorf("tenant max metrics must not be negative")
+	}
+	if config.ClientRateLimit < 0 || (config.ClientRateLimit > 0 && config.ClientRateBurst <= 0) {
+		return nil, fmt.Errorf("client rate limit must not be negative and burst must be positive")
+	}
+	if config.MaxBodySize < 0 || config.MaxDecompressedSize < 0 {
+		return nil, fmt.Errorf("max body sizes must not be negative")
+	}
+	if _, err = config.Keyring(); err != nil {
+		return nil, fmt.Errorf("invalid hash keys: %w", err)
+	}
+	if _, err = config.TrustedSubnets(); err != nil {
+		return nil, fmt.Errorf("invalid trusted subnet: %w", err)
+	}
+	if _, err = config.TrustedProxyNets(); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 
 	return &config, nil
 }
@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgconn"
@@ -13,8 +14,15 @@ import (
 	"github.com/ktigay/metrics-collector/internal/retry"
 )
 
-const connTimeout = 100 * time.Millisecond
+const (
+	connTimeout = 100 * time.Millisecond
+	// migrationTimeout время на создание структуры: перестроение индексов и ограничений
+	// на заполненных таблицах занимает заметное время.
+	migrationTimeout = 10 * time.Minute
+)
 
+// structure DDL выполняется по одному оператору. Удаление индекса или ограничения и создание
+// его замены идут одним оператором (DO-блок), чтобы ошибка не оставила схему без них.
 var structure = []string{
 	`
 	DO ' BEGIN
@@ -36,6 +44,102 @@ var structure = []string{
 		PRIMARY KEY (guid),
 		CONSTRAINT type_name_uidx UNIQUE (type, name)
 	)`,
+	`ALTER TYPE metric_type ADD VALUE IF NOT EXISTS 'histogram'`,
+	`
+	ALTER TABLE metrics
+		ADD COLUMN IF NOT EXISTS hist_bounds DOUBLE PRECISION[],
+		ADD COLUMN IF NOT EXISTS hist_counts BIGINT[],
+		ADD COLUMN IF NOT EXISTS hist_sum    DOUBLE PRECISION DEFAULT .0,
+		ADD COLUMN IF NOT EXISTS hist_count  BIGINT           DEFAULT 0
+	`,
+	`ALTER TABLE metrics ADD COLUMN IF NOT EXISTS labels TEXT NOT NULL DEFAULT ''`,
+	`
+	DO ' BEGIN
+	IF NOT EXISTS (
+		SELECT 1 FROM pg_constraint c
+			JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey)
+		WHERE c.conname = ''type_name_uidx'' AND a.attname = ''labels''
+	) THEN
+		ALTER TABLE metrics DROP CONSTRAINT IF EXISTS type_name_uidx;
+		ALTER TABLE metrics ADD CONSTRAINT type_name_uidx UNIQUE (type, name, labels);
+	END IF;
+	END '
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS metric_samples
+	(
+		id     BIGSERIAL,
+		type   metric_type              NOT NULL,
+		name   VARCHAR(255)             NOT NULL,
+		labels TEXT                     NOT NULL DEFAULT '',
+		delta  BIGINT                            DEFAULT 0,
+		value  DOUBLE PRECISION                  DEFAULT .0,
+		ts     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS metric_samples_key_ts_idx ON metric_samples (type, name, labels, ts)`,
+	`CREATE INDEX IF NOT EXISTS metric_samples_ts_idx ON metric_samples (ts)`,
+	`
+	CREATE TABLE IF NOT EXISTS metric_aggregates
+	(
+		type       metric_type              NOT NULL,
+		name       VARCHAR(255)             NOT NULL,
+		labels     TEXT                     NOT NULL DEFAULT '',
+		resolution INTEGER                  NOT NULL,
+		start      TIMESTAMP WITH TIME ZONE NOT NULL,
+		min        DOUBLE PRECISION                  DEFAULT .0,
+		max        DOUBLE PRECISION                  DEFAULT .0,
+		sum        DOUBLE PRECISION                  DEFAULT .0,
+		last       DOUBLE PRECISION                  DEFAULT .0,
+		last_ts    TIMESTAMP WITH TIME ZONE NOT NULL,
+		count      BIGINT                            DEFAULT 0,
+		delta      BIGINT                            DEFAULT 0,
+		PRIMARY KEY (type, name, labels, resolution, start)
+	)`,
+	`
+	CREATE TABLE IF NOT EXISTS agents
+	(
+		name       VARCHAR(255)             NOT NULL,
+		token_hash CHAR(64)                 NOT NULL,
+		prefixes   TEXT[]                   NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP WITH TIME ZONE          DEFAULT NOW(),
+		PRIMARY KEY (name),
+		CONSTRAINT agents_token_hash_uidx UNIQUE (token_hash)
+	)`,
+	`ALTER TABLE agents ADD COLUMN IF NOT EXISTS tenant VARCHAR(64) NOT NULL DEFAULT ''`,
+	`ALTER TABLE metrics ADD COLUMN IF NOT EXISTS tenant VARCHAR(64) NOT NULL DEFAULT ''`,
+	`
+	DO ' BEGIN
+	IF NOT EXISTS (
+		SELECT 1 FROM pg_constraint c
+			JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey)
+		WHERE c.conname = ''type_name_uidx'' AND a.attname = ''tenant''
+	) THEN
+		ALTER TABLE metrics DROP CONSTRAINT IF EXISTS type_name_uidx;
+		ALTER TABLE metrics ADD CONSTRAINT type_name_uidx UNIQUE (tenant, type, name, labels);
+	END IF;
+	END '
+	`,
+	`ALTER TABLE metric_samples ADD COLUMN IF NOT EXISTS tenant VARCHAR(64) NOT NULL DEFAULT ''`,
+	`
+	DO ' BEGIN
+		CREATE INDEX IF NOT EXISTS metric_samples_tenant_key_ts_idx ON metric_samples (tenant, type, name, labels, ts);
+		DROP INDEX IF EXISTS metric_samples_key_ts_idx;
+	END '
+	`,
+	`ALTER TABLE metric_aggregates ADD COLUMN IF NOT EXISTS tenant VARCHAR(64) NOT NULL DEFAULT ''`,
+	`
+	DO ' BEGIN
+	IF NOT EXISTS (
+		SELECT 1 FROM pg_constraint c
+			JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey)
+		WHERE c.conrelid = ''metric_aggregates''::regclass AND c.contype = ''p'' AND a.attname = ''tenant''
+	) THEN
+		ALTER TABLE metric_aggregates DROP CONSTRAINT IF EXISTS metric_aggregates_pkey;
+		ALTER TABLE metric_aggregates ADD PRIMARY KEY (tenant, type, name, labels, resolution, start);
+	END IF;
+	END '
+	`,
 }
 
 // InitializeDB инициализация соединения к БД.
@@ -69,14 +173,15 @@ func InitializeDB(ctx context.Context, driver, dsn string, logger *zap.SugaredLo
 	return dbPool, nil
 }
 
-// CreateStructure создает структуру БД.
-func CreateStructure(ctx context.Context, dbPool *sql.DB) (err error) {
-	c, cancel := context.WithTimeout(ctx, 1*time.Second)
+// CreateStructure создает структуру БД. Ошибка любого оператора прерывает создание:
+// сервер не должен работать с частично обновленной схемой.
+func CreateStructure(ctx context.Context, dbPool *sql.DB) error {
+	c, cancel := context.WithTimeout(ctx, migrationTimeout)
 	defer cancel()
 
-	for _, s := range structure {
-		if _, err = dbPool.ExecContext(c, s); err != nil {
-			return nil
+	for i, s := range structure {
+		if _, err := dbPool.ExecContext(c, s); err != nil {
+			return fmt.Errorf("structure statement %d: %w", i, err)
 		}
 	}
 
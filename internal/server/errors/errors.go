@@ -12,4 +12,10 @@ var (
 	ErrInvalidValueType = errors.New("invalid value type")
 	// ErrValueNotFound значение не найдено.
 	ErrValueNotFound = errors.New("value not found")
+	// ErrHistoryDisabled хранение истории не включено.
+	ErrHistoryDisabled = errors.New("history disabled")
+	// ErrForbidden метрика не разрешена агенту.
+	ErrForbidden = errors.New("metric is not allowed for agent")
+	// ErrQuotaExceeded превышена квота метрик тенанта.
+	ErrQuotaExceeded = errors.New("tenant metric quota exceeded")
 )
@@ -0,0 +1,258 @@
+package graphite
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/graphite/mocks"
+)
+
+func TestParseLine(t *testing.T) {
+	v := 12.5
+	tests := []struct {
+		name    string
+		line    string
+		want    metric.Metrics
+		wantErr bool
+	}{
+		{
+			name: "with_timestamp",
+			line: "servers.web1.load 12.5 1735689600",
+			want: metric.Metrics{ID: "servers.web1.load", Type: "gauge", Value: &v},
+		},
+		{
+			name: "with_tags",
+			line: "load;host=web1;dc=eu 12.5",
+			want: metric.Metrics{ID: "load", Type: "gauge", Value: &v, Labels: metric.Labels{"host": "web1", "dc": "eu"}},
+		},
+		{
+			name: "sanitized_tags",
+			line: "load;host-name=web1;dc.zone=eu;dc_zone=us 12.5",
+			want: metric.Metrics{ID: "load", Type: "gauge", Value: &v, Labels: metric.Labels{"host_name": "web1", "dc_zone": "eu"}},
+		},
+		{
+			name:    "missing_value",
+			line:    "servers.web1.load",
+			wantErr: true,
+		},
+		{
+			name:    "bad_value",
+			line:    "servers.web1.load high 1735689600",
+			wantErr: true,
+		},
+		{
+			name:    "bad_tag",
+			line:    "load;host 1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.wantErr {
+				require.True(t, errors.Is(err, ErrInvalidLine))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServer_Serve(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	a, b := 1.0, 2.0
+	saved := make(chan struct{})
+	col := mocks.NewMockCollector(mockCtrl)
+	col.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+		{ID: "a", Type: "gauge", Value: &a},
+		{ID: "b", Type: "gauge", Value: &b},
+	}).DoAndReturn(func(context.Context, []metric.Metrics) error {
+		close(saved)
+		return nil
+	}).Times(1)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	exitCtx, cancel := context.WithCancel(context.Background())
+	s := NewServer("", time.Second, 32, col, zap.NewNop().Sugar())
+
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(context.Background(), exitCtx, ln)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("a 1 1735689600\nbroken\nb 2 1735689600\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	select {
+	case <-saved:
+	case <-time.After(time.Second):
+		t.Fatal("batch was not saved")
+	}
+
+	// строка длиннее лимита закрывает соединение, принятые строки сохраняются.
+	c := 3.0
+	col.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+		{ID: "c", Type: "gauge", Value: &c},
+	}).Return(nil).Times(1)
+
+	conn, err = net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("c 3\n" + strings.Repeat("x", 64) + " 1\nd 4\n"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 1)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(buf)
+	var ne net.Error
+	require.Error(t, err)
+	require.False(t, errors.As(err, &ne) && ne.Timeout(), "connection must be closed by server")
+	_ = conn.Close()
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestServer_Serve_BadTagKey(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	a, b, c := 1.0, 2.0, 3.0
+	saved := make(chan struct{})
+	col := mocks.NewMockCollector(mockCtrl)
+	col.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+		{ID: "a", Type: "gauge", Value: &a},
+		{ID: "b", Type: "gauge", Value: &b, Labels: metric.Labels{"host_name": "web1"}},
+		{ID: "c", Type: "gauge", Value: &c},
+	}).DoAndReturn(func(_ context.Context, mm []metric.Metrics) error {
+		for _, m := range mm {
+			require.NoError(t, m.Labels.Validate())
+		}
+		close(saved)
+		return nil
+	}).Times(1)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	exitCtx, cancel := context.WithCancel(context.Background())
+	s := NewServer("", time.Second, 64, col, zap.NewNop().Sugar())
+
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(context.Background(), exitCtx, ln)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("a 1\nb;host-name=web1 2\nc 3\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	select {
+	case <-saved:
+	case <-time.After(time.Second):
+		t.Fatal("batch was not saved")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestServer_Serve_SlowSender(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	a := 1.0
+	saved := make(chan struct{})
+	col := mocks.NewMockCollector(mockCtrl)
+	col.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+		{ID: "a", Type: "gauge", Value: &a},
+	}).DoAndReturn(func(context.Context, []metric.Metrics) error {
+		close(saved)
+		return nil
+	}).Times(1)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	exitCtx, cancel := context.WithCancel(context.Background())
+	s := NewServer("", time.Minute, 32, col, zap.NewNop().Sugar())
+	s.flushInterval = 50 * time.Millisecond
+
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(context.Background(), exitCtx, ln)
+	}()
+
+	// соединение остается открытым, батч не заполнен: строка сохраняется по таймеру.
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	_, err = conn.Write([]byte("a 1\n"))
+	require.NoError(t, err)
+
+	select {
+	case <-saved:
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed by timer")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestServer_Serve_TrustedSubnets(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	// соединение с адреса вне подсети закрывается без сохранения строк.
+	col := mocks.NewMockCollector(mockCtrl)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	exitCtx, cancel := context.WithCancel(context.Background())
+	s := NewServer("", time.Second, 32, col, zap.NewNop().Sugar(), WithTrustedSubnets([]*net.IPNet{trusted}))
+
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(context.Background(), exitCtx, ln)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	_, _ = conn.Write([]byte("a 1\n"))
+
+	buf := make([]byte, 1)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(buf)
+	var ne net.Error
+	require.Error(t, err)
+	require.False(t, errors.As(err, &ne) && ne.Timeout(), "connection must be closed by server")
+	_ = conn.Close()
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,61 @@
+// Package graphite Прием метрик по протоколу Graphite plaintext (TCP).
+package graphite
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+// ErrInvalidLine некорректная строка.
+var ErrInvalidLine = errors.New("invalid graphite line")
+
+// ParseLine разбирает строку вида "path value [timestamp]" в gauge.
+// Теги в формате path;tag=value становятся метками, недопустимые в именах меток символы
+// заменяются на '_'. Если имена тегов совпали после замены, остается первый. Время не сохраняется.
+func ParseLine(line string) (metric.Metrics, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return metric.Metrics{}, fmt.Errorf("%w: %q", ErrInvalidLine, line)
+	}
+
+	v, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return metric.Metrics{}, fmt.Errorf("%w: bad value %q", ErrInvalidLine, fields[1])
+	}
+	if len(fields) == 3 {
+		if _, err = strconv.ParseFloat(fields[2], 64); err != nil {
+			return metric.Metrics{}, fmt.Errorf("%w: bad timestamp %q", ErrInvalidLine, fields[2])
+		}
+	}
+
+	parts := strings.Split(fields[0], ";")
+	if parts[0] == "" {
+		return metric.Metrics{}, fmt.Errorf("%w: empty path", ErrInvalidLine)
+	}
+
+	var labels metric.Labels
+	for _, t := range parts[1:] {
+		k, val, ok := strings.Cut(t, "=")
+		if !ok || k == "" {
+			return metric.Metrics{}, fmt.Errorf("%w: bad tag %q", ErrInvalidLine, t)
+		}
+		if labels == nil {
+			labels = make(metric.Labels, len(parts)-1)
+		}
+		k = metric.SanitizeLabelName(k)
+		if _, ok = labels[k]; !ok {
+			labels[k] = val
+		}
+	}
+
+	return metric.Metrics{
+		ID:     parts[0],
+		Type:   string(metric.TypeGauge),
+		Value:  &v,
+		Labels: labels,
+	}, nil
+}
@@ -0,0 +1,206 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/subnet"
+)
+
+const (
+	batchSize = 500
+	// defaultFlushInterval интервал сохранения неполного батча.
+	defaultFlushInterval = time.Second
+)
+
+// Collector интерфейс сохранения метрик.
+//
+//go:generate mockgen -destination=./mocks/mock_collector.go -package=mocks github.com/ktigay/metrics-collector/internal/server/graphite Collector
+type Collector interface {
+	SaveAll(ctx context.Context, mt []metric.Metrics) error
+}
+
+// Server TCP-сервер Graphite.
+type Server struct {
+	addr          string
+	readTimeout   time.Duration
+	maxLineLength int
+	flushInterval time.Duration
+	collector     Collector
+	logger        *zap.SugaredLogger
+	// subnets доверенные подсети, пусто - соединения принимаются с любых адресов.
+	subnets []*net.IPNet
+
+	mx    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// Option опция сервера.
+type Option func(*Server)
+
+// WithTrustedSubnets принимать соединения только с адресов из подсетей subnets.
+func WithTrustedSubnets(subnets []*net.IPNet) Option {
+	return func(s *Server) {
+		s.subnets = subnets
+	}
+}
+
+// NewServer конструктор.
+func NewServer(addr string, readTimeout time.Duration, maxLineLength int, collector Collector, logger *zap.SugaredLogger, opts ...Option) *Server {
+	s := &Server{
+		addr:          addr,
+		readTimeout:   readTimeout,
+		maxLineLength: maxLineLength,
+		flushInterval: defaultFlushInterval,
+		collector:     collector,
+		logger:        logger,
+		conns:         make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ListenAndServe слушает TCP-адрес и обслуживает его до завершения exitCtx.
+func (s *Server) ListenAndServe(mainCtx, exitCtx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(mainCtx, exitCtx, ln)
+}
+
+// Serve принимает соединения из ln. При завершении exitCtx закрывает listener
+// и открытые соединения, дожидаясь сохранения принятых строк.
+func (s *Server) Serve(mainCtx, exitCtx context.Context, ln net.Listener) error {
+	go func() {
+		<-exitCtx.Done()
+		_ = ln.Close()
+
+		s.mx.Lock()
+		for c := range s.conns {
+			_ = c.Close()
+		}
+		s.mx.Unlock()
+	}()
+
+	defer s.wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if exitCtx.Err() != nil {
+				s.logger.Debug("graphite server shutting down")
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		if len(s.subnets) > 0 && !subnet.Contains(s.subnets, subnet.AddrIP(conn.RemoteAddr())) {
+			s.logger.Warnf("graphite untrusted ip %v", conn.RemoteAddr())
+			_ = conn.Close()
+			continue
+		}
+
+		s.mx.Lock()
+		if exitCtx.Err() != nil {
+			s.mx.Unlock()
+			_ = conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mx.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.handle(mainCtx, conn)
+
+			s.mx.Lock()
+			delete(s.conns, conn)
+			s.mx.Unlock()
+		}()
+	}
+}
+
+// handle сохраняет строки соединения батчами: по заполнении батча, раз в flushInterval
+// и при закрытии соединения, чтобы редкие строки медленного отправителя не копились.
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	lines := make(chan metric.Metrics)
+	go s.scan(conn, lines)
+
+	batch := make([]metric.Metrics, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.collector.SaveAll(ctx, batch); err != nil {
+			s.logger.Errorf("graphite save error: %v", err)
+		}
+		batch = batch[:0]
+	}
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case m, ok := <-lines:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, m)
+			if len(batch) == batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// scan читает и разбирает строки соединения, закрывая lines по окончании чтения.
+func (s *Server) scan(conn net.Conn, lines chan<- metric.Metrics) {
+	defer close(lines)
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 0, min(s.maxLineLength, 4096)), s.maxLineLength)
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(s.readTimeout)); err != nil {
+			s.logger.Errorf("graphite set deadline error: %v", err)
+			return
+		}
+		if !sc.Scan() {
+			if err := sc.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+				s.logger.Debugf("graphite connection %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		m, err := ParseLine(line)
+		if err != nil {
+			s.logger.Debugf("graphite skip line: %v", err)
+			continue
+		}
+		lines <- m
+	}
+}
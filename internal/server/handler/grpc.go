@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"io"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	gproto "google.golang.org/protobuf/proto"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	pb "github.com/ktigay/metrics-collector/internal/proto"
+	"github.com/ktigay/metrics-collector/internal/server/errors"
+)
+
+var errCodeMap = map[error]codes.Code{
+	errors.ErrWrongType:       codes.InvalidArgument,
+	errors.ErrWrongValue:      codes.InvalidArgument,
+	errors.ErrValueNotFound:   codes.NotFound,
+	errors.ErrHistoryDisabled: codes.Unimplemented,
+	errors.ErrForbidden:       codes.PermissionDenied,
+	errors.ErrQuotaExceeded:   codes.ResourceExhausted,
+}
+
+func statusErrorFromError(err error) error {
+	c, ok := errCodeMap[err]
+	if !ok {
+		c = codes.Internal
+	}
+	return status.Error(c, err.Error())
+}
+
+// MetricsServer реализация gRPC-сервиса MetricsService.
+type MetricsServer struct {
+	pb.UnimplementedMetricsServiceServer
+	collector CollectorInterface
+	// maxBatchSize максимальный суммарный размер сообщений потока UpdateBatch в байтах, 0 - без ограничений.
+	maxBatchSize int64
+	logger       *zap.SugaredLogger
+}
+
+// NewMetricsServer конструктор. maxBatchSize - максимальный суммарный размер сообщений
+// потока UpdateBatch в байтах (как размер распакованного тела HTTP-запроса), 0 - без ограничений.
+func NewMetricsServer(collector CollectorInterface, maxBatchSize int64, logger *zap.SugaredLogger) *MetricsServer {
+	return &MetricsServer{
+		collector:    collector,
+		maxBatchSize: maxBatchSize,
+		logger:       logger,
+	}
+}
+
+// Update обновляет метрику и возвращает ее текущее значение.
+func (s *MetricsServer) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	if req.GetMetric() == nil {
+		return nil, status.Error(codes.InvalidArgument, "metric is required")
+	}
+
+	m := req.GetMetric().ToMetrics()
+	if err := s.collector.Save(ctx, m); err != nil {
+		return nil, statusErrorFromError(err)
+	}
+
+	mm, err := s.collector.Find(ctx, m.Type, m.ID, m.Labels)
+	if err != nil {
+		return nil, statusErrorFromError(err)
+	}
+
+	return &pb.UpdateResponse{Metric: pb.FromMetrics(*mm)}, nil
+}
+
+// UpdateBatch принимает поток пачек метрик и сохраняет их одним батчем после закрытия потока клиентом.
+// Поток больше maxBatchSize отклоняется с codes.ResourceExhausted без сохранения.
+func (s *MetricsServer) UpdateBatch(stream pb.MetricsService_UpdateBatchServer) error {
+	var (
+		mm   []metric.Metrics
+		size int64
+	)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if size += int64(gproto.Size(req)); s.maxBatchSize > 0 && size > s.maxBatchSize {
+			s.logger.Warnf("UpdateBatch: batch exceeds %d bytes", s.maxBatchSize)
+			return status.Errorf(codes.ResourceExhausted, "batch exceeds %d bytes", s.maxBatchSize)
+		}
+		for _, m := range req.GetMetrics() {
+			mm = append(mm, m.ToMetrics())
+		}
+	}
+
+	if len(mm) > 0 {
+		if err := s.collector.SaveAll(stream.Context(), mm); err != nil {
+			return statusErrorFromError(err)
+		}
+	}
+
+	return stream.SendAndClose(&pb.UpdateBatchResponse{Received: int64(len(mm))})
+}
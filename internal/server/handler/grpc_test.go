@@ -0,0 +1,352 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	gproto "google.golang.org/protobuf/proto"
+
+	h "github.com/ktigay/metrics-collector/internal/http"
+	"github.com/ktigay/metrics-collector/internal/metric"
+	pb "github.com/ktigay/metrics-collector/internal/proto"
+	"github.com/ktigay/metrics-collector/internal/server/errors"
+	"github.com/ktigay/metrics-collector/internal/server/handler/mocks"
+	"github.com/ktigay/metrics-collector/internal/server/interceptor"
+	"github.com/ktigay/metrics-collector/internal/server/keyring"
+	"github.com/ktigay/metrics-collector/internal/server/replay"
+)
+
+const (
+	testHashKey      = "secret"
+	testMaxBatchSize = 1024
+)
+
+var testKeys = keyring.Keyring{keyring.DefaultID: testHashKey, "v2": "rotated"}
+
+func newTestGRPCClient(t *testing.T, collector CollectorInterface) pb.MetricsServiceClient {
+	t.Helper()
+
+	logger := zap.NewNop().Sugar()
+	guard := replay.NewGuard(time.Minute, 100)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			interceptor.CheckSumUnary(logger, testKeys),
+			interceptor.ReplayUnary(logger, guard),
+		),
+		grpc.ChainStreamInterceptor(
+			interceptor.CheckSumStream(logger, testKeys),
+			interceptor.ReplayStream(logger, guard),
+		),
+	)
+	pb.RegisterMetricsServiceServer(srv, NewMetricsServer(collector, testMaxBatchSize, logger))
+
+	ln := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return pb.NewMetricsServiceClient(conn)
+}
+
+func TestMetricsServer_Update(t *testing.T) {
+	v := 1.5
+	tests := []struct {
+		name      string
+		req       *pb.UpdateRequest
+		signKey   string
+		keyID     string
+		collector func(controller *gomock.Controller) CollectorInterface
+		wantCode  codes.Code
+		want      *pb.Metric
+	}{
+		{
+			name:    "Positive_test_signed",
+			req:     &pb.UpdateRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1.5}},
+			signKey: testHashKey,
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				m := metric.Metrics{ID: "Alloc", Type: "gauge", Value: &v}
+				st.EXPECT().Save(gomock.Any(), m).Return(nil).Times(1)
+				st.EXPECT().Find(gomock.Any(), "gauge", "Alloc", metric.Labels(nil)).Return(&m, nil).Times(1)
+				return st
+			},
+			wantCode: codes.OK,
+			want:     &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1.5},
+		},
+		{
+			name:    "Positive_test_signed_key_id",
+			req:     &pb.UpdateRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1.5}},
+			signKey: "rotated",
+			keyID:   "v2",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				m := metric.Metrics{ID: "Alloc", Type: "gauge", Value: &v}
+				st.EXPECT().Save(gomock.Any(), m).Return(nil).Times(1)
+				st.EXPECT().Find(gomock.Any(), "gauge", "Alloc", metric.Labels(nil)).Return(&m, nil).Times(1)
+				return st
+			},
+			wantCode: codes.OK,
+			want:     &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1.5},
+		},
+		{
+			name:    "Negative_test_unknown_key_id",
+			req:     &pb.UpdateRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1.5}},
+			signKey: "rotated",
+			keyID:   "v3",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().Save(gomock.Any(), gomock.Any()).Times(0)
+				return st
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:    "Negative_test_wrong_type",
+			req:     &pb.UpdateRequest{Metric: &pb.Metric{Id: "Alloc"}},
+			signKey: testHashKey,
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().Save(gomock.Any(), gomock.Any()).Return(errors.ErrWrongType).Times(1)
+				return st
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "Negative_test_unsigned",
+			req:  &pb.UpdateRequest{Metric: &pb.Metric{Id: "PollCount", Type: pb.Metric_COUNTER, Delta: 1}},
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().Save(gomock.Any(), gomock.Any()).Times(0)
+				return st
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:    "Negative_test_invalid_checksum",
+			req:     &pb.UpdateRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1.5}},
+			signKey: "wrong",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().Save(gomock.Any(), gomock.Any()).Times(0)
+				return st
+			},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			client := newTestGRPCClient(t, tt.collector(mockCtrl))
+
+			ctx := context.Background()
+			if tt.signKey != "" {
+				ts, nonce, err := h.NewNonce()
+				require.NoError(t, err)
+				sum, err := pb.Hash(tt.signKey, ts, nonce, tt.req)
+				require.NoError(t, err)
+				ctx = metadata.AppendToOutgoingContext(ctx,
+					pb.HashMetadataKey, sum,
+					pb.TimestampMetadataKey, ts,
+					pb.NonceMetadataKey, nonce,
+				)
+			}
+			if tt.keyID != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, pb.KeyIDMetadataKey, tt.keyID)
+			}
+
+			resp, err := client.Update(ctx, tt.req)
+			require.Equal(t, tt.wantCode, status.Code(err))
+			if tt.want != nil {
+				require.Equal(t, tt.want.String(), resp.GetMetric().String())
+			}
+		})
+	}
+}
+
+func TestMetricsServer_Update_Replay(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	st := mocks.NewMockCollectorInterface(mockCtrl)
+	d := int64(1)
+	m := metric.Metrics{ID: "PollCount", Type: "counter", Delta: &d}
+	st.EXPECT().Save(gomock.Any(), m).Return(nil).Times(1)
+	st.EXPECT().Find(gomock.Any(), "counter", "PollCount", metric.Labels(nil)).Return(&m, nil).Times(1)
+
+	client := newTestGRPCClient(t, st)
+
+	req := &pb.UpdateRequest{Metric: &pb.Metric{Id: "PollCount", Type: pb.Metric_COUNTER, Delta: 1}}
+	ts, nonce, err := h.NewNonce()
+	require.NoError(t, err)
+	sum, err := pb.Hash(testHashKey, ts, nonce, req)
+	require.NoError(t, err)
+	signed := metadata.AppendToOutgoingContext(context.Background(),
+		pb.HashMetadataKey, sum,
+		pb.TimestampMetadataKey, ts,
+		pb.NonceMetadataKey, nonce,
+	)
+
+	_, err = client.Update(signed, req)
+	require.NoError(t, err)
+
+	// повтор с той же подписью.
+	_, err = client.Update(signed, req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// повтор с удаленными метаданными подписи.
+	_, err = client.Update(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// подпись без timestamp и nonce.
+	sum, err = pb.Hash(testHashKey, "", "", req)
+	require.NoError(t, err)
+	_, err = client.Update(metadata.AppendToOutgoingContext(context.Background(), pb.HashMetadataKey, sum), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestMetricsServer_UpdateBatch(t *testing.T) {
+	chunks := []*pb.UpdateBatchRequest{
+		{Metrics: []*pb.Metric{{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1.5}}},
+		{Metrics: []*pb.Metric{{Id: "PollCount", Type: pb.Metric_COUNTER, Delta: 3, Labels: map[string]string{"host": "a"}}}},
+	}
+
+	tests := []struct {
+		name      string
+		signKey   string
+		collector func(controller *gomock.Controller) CollectorInterface
+		wantCode  codes.Code
+	}{
+		{
+			name:    "Positive_test_signed",
+			signKey: testHashKey,
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				v, d := 1.5, int64(3)
+				st.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+					{ID: "Alloc", Type: "gauge", Value: &v},
+					{ID: "PollCount", Type: "counter", Delta: &d, Labels: metric.Labels{"host": "a"}},
+				}).Return(nil).Times(1)
+				return st
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:    "Negative_test_invalid_checksum",
+			signKey: "wrong",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().SaveAll(gomock.Any(), gomock.Any()).Times(0)
+				return st
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "Negative_test_unsigned",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().SaveAll(gomock.Any(), gomock.Any()).Times(0)
+				return st
+			},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			client := newTestGRPCClient(t, tt.collector(mockCtrl))
+
+			ctx := context.Background()
+			if tt.signKey != "" {
+				ts, nonce, err := h.NewNonce()
+				require.NoError(t, err)
+				sum, err := pb.Hash(tt.signKey, ts, nonce, chunks[0], chunks[1])
+				require.NoError(t, err)
+				ctx = metadata.AppendToOutgoingContext(ctx,
+					pb.HashMetadataKey, sum,
+					pb.TimestampMetadataKey, ts,
+					pb.NonceMetadataKey, nonce,
+				)
+			}
+
+			stream, err := client.UpdateBatch(ctx)
+			require.NoError(t, err)
+			for _, c := range chunks {
+				require.NoError(t, stream.Send(c))
+			}
+			resp, err := stream.CloseAndRecv()
+			require.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				require.Equal(t, int64(2), resp.GetReceived())
+			}
+		})
+	}
+}
+
+func TestMetricsServer_UpdateBatch_TooLarge(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	st := mocks.NewMockCollectorInterface(mockCtrl)
+	st.EXPECT().SaveAll(gomock.Any(), gomock.Any()).Times(0)
+	client := newTestGRPCClient(t, st)
+
+	chunk := &pb.UpdateBatchRequest{}
+	for i := range 10 {
+		chunk.Metrics = append(chunk.Metrics, &pb.Metric{Id: "PollCount" + strconv.Itoa(i), Type: pb.Metric_COUNTER, Delta: 1})
+	}
+	chunks := make([]gproto.Message, 0, 10)
+	for range 10 {
+		chunks = append(chunks, chunk)
+	}
+	require.Greater(t, len(chunks)*gproto.Size(chunk), testMaxBatchSize)
+
+	ts, nonce, err := h.NewNonce()
+	require.NoError(t, err)
+	sum, err := pb.Hash(testHashKey, ts, nonce, chunks...)
+	require.NoError(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		pb.HashMetadataKey, sum,
+		pb.TimestampMetadataKey, ts,
+		pb.NonceMetadataKey, nonce,
+	)
+
+	stream, err := client.UpdateBatch(ctx)
+	require.NoError(t, err)
+	for range chunks {
+		// после отказа сервера отправка возвращает io.EOF, ошибка приходит в CloseAndRecv.
+		if err = stream.Send(chunk); err != nil {
+			break
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/server/influx"
+)
+
+// InfluxHandler структура для приема метрик в формате InfluxDB line protocol.
+type InfluxHandler struct {
+	collector CollectorInterface
+	logger    *zap.SugaredLogger
+}
+
+// NewInfluxHandler конструктор.
+func NewInfluxHandler(collector CollectorInterface, logger *zap.SugaredLogger) *InfluxHandler {
+	return &InfluxHandler{
+		collector: collector,
+		logger:    logger,
+	}
+}
+
+// Write обработчик записи точек. При ошибке разбора запрос отклоняется целиком,
+// в теле ответа возвращается номер строки. Целые поля сохраняются как приращения счетчиков,
+// см. [influx.ToMetrics].
+func (ih *InfluxHandler) Write(w http.ResponseWriter, r *http.Request) {
+	points, err := influx.Parse(r.Body)
+	if err != nil {
+		var pe *influx.ParseError
+		if !errors.As(err, &pe) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		if _, err = w.Write([]byte(pe.Error())); err != nil {
+			ih.logger.Errorln("Failed to write response", zap.Error(err))
+		}
+		return
+	}
+
+	if mm := influx.ToMetrics(points); len(mm) > 0 {
+		if err = ih.collector.SaveAll(r.Context(), mm); err != nil {
+			w.WriteHeader(statusFromError(err))
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/handler/mocks"
+)
+
+func TestInfluxHandler_Write(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		collector  func(controller *gomock.Controller) CollectorInterface
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "Positive_test",
+			body: "mem,host=a used=10i,percent=12.5 1735689600000000000\n",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				d, v := int64(10), 12.5
+				st.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+					{ID: "mem_used", Type: "counter", Delta: &d, Labels: metric.Labels{"host": "a"}},
+					{ID: "mem_percent", Type: "gauge", Value: &v, Labels: metric.Labels{"host": "a"}},
+				}).Return(nil).Times(1)
+				return st
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "Positive_test_Sanitized_Tag_Keys",
+			body: "mem,host-name=a,dc.zone=b used=10i\n",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				d := int64(10)
+				st.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+					{ID: "mem_used", Type: "counter", Delta: &d, Labels: metric.Labels{"host_name": "a", "dc_zone": "b"}},
+				}).Return(nil).Times(1)
+				return st
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "Bad_Request_Parse_Error",
+			body: "mem used=10i\nmem used=abc\n",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().SaveAll(gomock.Any(), gomock.Any()).Times(0)
+				return st
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `line 2: invalid line: bad value "abc" of field "used"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			h := NewInfluxHandler(tt.collector(mockCtrl), zap.NewNop().Sugar())
+
+			router := mux.NewRouter()
+			router.HandleFunc("/write", h.Write)
+
+			srv := httptest.NewServer(router)
+			defer srv.Close()
+
+			resp, err := http.Post(srv.URL+"/write", "text/plain", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			b, _ := io.ReadAll(resp.Body)
+			require.Equal(t, tt.wantBody, string(b))
+		})
+	}
+}
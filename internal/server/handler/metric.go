@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
@@ -16,9 +18,12 @@ import (
 )
 
 var errStatusMap = map[error]int{
-	errors.ErrWrongType:     http.StatusBadRequest,
-	errors.ErrWrongValue:    http.StatusBadRequest,
-	errors.ErrValueNotFound: http.StatusNotFound,
+	errors.ErrWrongType:       http.StatusBadRequest,
+	errors.ErrWrongValue:      http.StatusBadRequest,
+	errors.ErrValueNotFound:   http.StatusNotFound,
+	errors.ErrHistoryDisabled: http.StatusNotImplemented,
+	errors.ErrForbidden:       http.StatusForbidden,
+	errors.ErrQuotaExceeded:   http.StatusTooManyRequests,
 }
 
 func statusFromError(err error) int {
@@ -34,9 +39,10 @@ func statusFromError(err error) int {
 type CollectorInterface interface {
 	Save(ctx context.Context, mt metric.Metrics) error
 	All(ctx context.Context) ([]repository.MetricEntity, error)
-	Find(ctx context.Context, t, n string) (*metric.Metrics, error)
-	Remove(ctx context.Context, t, n string) error
+	Find(ctx context.Context, t, n string, l metric.Labels) (*metric.Metrics, error)
+	Remove(ctx context.Context, t, n string, l metric.Labels) error
 	SaveAll(ctx context.Context, mt []metric.Metrics) error
+	History(ctx context.Context, t, n string, l metric.Labels, res time.Duration, from, to time.Time) ([]metric.Sample, error)
 }
 
 // MetricHandler структура с обработчиками запросов.
@@ -54,12 +60,14 @@ func NewMetricHandler(collector CollectorInterface, logger *zap.SugaredLogger) *
 }
 
 // CollectHandler обработчик для сборка метрик.
+// Метки передаются в query-параметрах: /update/gauge/Alloc/1?host=a.
 func (mh *MetricHandler) CollectHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	mt := metric.Metrics{
-		ID:   vars["name"],
-		Type: vars["type"],
+		ID:     vars["name"],
+		Type:   vars["type"],
+		Labels: labelsFromQuery(r),
 	}
 	if err := mt.SetValueByType(vars["value"]); err != nil {
 		w.WriteHeader(statusFromError(err))
@@ -81,7 +89,7 @@ func (mh *MetricHandler) GetValueHandler(w http.ResponseWriter, r *http.Request)
 		m   *metric.Metrics
 	)
 
-	if m, err = mh.collector.Find(r.Context(), vars["type"], vars["name"]); err != nil {
+	if m, err = mh.collector.Find(r.Context(), vars["type"], vars["name"], labelsFromQuery(r)); err != nil {
 		w.WriteHeader(statusFromError(err))
 		return
 	}
@@ -98,6 +106,10 @@ func (mh *MetricHandler) GetAllHandler(w http.ResponseWriter, r *http.Request) {
 
 	names := make([]string, 0, len(metrics))
 	for _, m := range metrics {
+		if len(m.Labels) > 0 {
+			names = append(names, m.Name+"{"+m.Labels.String()+"}")
+			continue
+		}
 		names = append(names, m.Name)
 	}
 
@@ -134,7 +146,7 @@ func (mh *MetricHandler) UpdateJSONHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if mm, err = mh.collector.Find(ctx, m.Type, m.ID); err != nil {
+	if mm, err = mh.collector.Find(ctx, m.Type, m.ID, m.Labels); err != nil {
 		w.WriteHeader(statusFromError(err))
 		return
 	}
@@ -190,7 +202,7 @@ func (mh *MetricHandler) GetJSONValueHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	mm, err = mh.collector.Find(r.Context(), m.Type, m.ID)
+	mm, err = mh.collector.Find(r.Context(), m.Type, m.ID, m.Labels)
 	if err != nil {
 		w.WriteHeader(statusFromError(err))
 		return
@@ -200,3 +212,71 @@ func (mh *MetricHandler) GetJSONValueHandler(w http.ResponseWriter, r *http.Requ
 		mh.logger.Errorln("Failed to write response", zap.Error(err))
 	}
 }
+
+// HistoryHandler возвращает историю метрики в виде json-строки.
+// Границы интервала from и to задаются в RFC3339 или unix-секундах,
+// resolution (1m, 1h) выбирает агрегаты вместо исходных отсчетов, метки - остальными query-параметрами.
+func (mh *MetricHandler) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var (
+		from, to time.Time
+		res      time.Duration
+		samples  []metric.Sample
+		err      error
+	)
+
+	q := r.URL.Query()
+	if s := q.Get("resolution"); s != "" {
+		if res, err = time.ParseDuration(s); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if from, err = parseTime(q.Get("from")); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if to, err = parseTime(q.Get("to")); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	labels := labelsFromQuery(r, "from", "to", "resolution")
+	if samples, err = mh.collector.History(r.Context(), vars["type"], vars["name"], labels, res, from, to); err != nil {
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(samples); err != nil {
+		mh.logger.Errorln("Failed to write response", zap.Error(err))
+	}
+}
+
+// labelsFromQuery метки из query-параметров, кроме перечисленных в skip.
+func labelsFromQuery(r *http.Request, skip ...string) metric.Labels {
+	q := r.URL.Query()
+	for _, k := range skip {
+		q.Del(k)
+	}
+	if len(q) == 0 {
+		return nil
+	}
+
+	labels := make(metric.Labels, len(q))
+	for k := range q {
+		labels[k] = q.Get(k)
+	}
+	return labels
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
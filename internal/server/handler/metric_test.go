@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
@@ -16,6 +17,7 @@ import (
 	"github.com/ktigay/metrics-collector/internal/server/handler/mocks"
 	"github.com/ktigay/metrics-collector/internal/server/repository"
 	"github.com/ktigay/metrics-collector/internal/server/service"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
 )
 
 func TestServer_CollectHandler(t *testing.T) {
@@ -186,14 +188,14 @@ func TestServer_UpdateJSONHandler(t *testing.T) {
 			fields: fields{
 				collector: service.NewMetricCollector(
 					&repository.MemMetricRepository{
-						Metrics: map[string]repository.MetricEntity{
+						Metrics: map[string]map[string]repository.MetricEntity{tenant.Default: {
 							"counter:TestSet91": {
 								Key:   "counter:TestSet91",
 								Name:  "TestSet91",
 								Type:  "counter",
 								Delta: int64(10),
 							},
-						},
+						}},
 					},
 					zap.NewNop().Sugar(),
 				),
@@ -319,14 +321,14 @@ func TestServer_GetJSONValueHandler(t *testing.T) {
 			fields: fields{
 				collector: service.NewMetricCollector(
 					&repository.MemMetricRepository{
-						Metrics: map[string]repository.MetricEntity{
+						Metrics: map[string]map[string]repository.MetricEntity{tenant.Default: {
 							"gauge:TestSet90": {
 								Key:   "counter:TestSet90",
 								Name:  "TestSet90",
 								Type:  "gauge",
 								Value: 15.444,
 							},
-						},
+						}},
 					},
 					zap.NewNop().Sugar(),
 				),
@@ -347,14 +349,14 @@ func TestServer_GetJSONValueHandler(t *testing.T) {
 			fields: fields{
 				collector: service.NewMetricCollector(
 					&repository.MemMetricRepository{
-						Metrics: map[string]repository.MetricEntity{
+						Metrics: map[string]map[string]repository.MetricEntity{tenant.Default: {
 							"counter:TestSet91": {
 								Key:   "counter:TestSet91",
 								Name:  "TestSet91",
 								Type:  "counter",
 								Delta: int64(10),
 							},
-						},
+						}},
 					},
 					zap.NewNop().Sugar(),
 				),
@@ -604,3 +606,91 @@ func TestMetricHandler_UpdatesJSONHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_HistoryHandler(t *testing.T) {
+	type args struct {
+		request string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		collector  func(controller *gomock.Controller) CollectorInterface
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "Positive_test_history",
+			args: args{
+				request: "/history/gauge/Alloc?from=1735689600&to=2025-01-01T00:10:00Z&resolution=1m&host=a",
+			},
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				v := 1.5
+				st.EXPECT().History(
+					gomock.Any(),
+					"gauge",
+					"Alloc",
+					metric.Labels{"host": "a"},
+					time.Minute,
+					time.Unix(1735689600, 0),
+					time.Date(2025, 1, 1, 0, 10, 0, 0, time.UTC),
+				).Return([]metric.Sample{
+					{Time: time.Date(2025, 1, 1, 0, 5, 0, 0, time.UTC), Value: &v},
+				}, nil).Times(1)
+				return st
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "[{\"time\":\"2025-01-01T00:05:00Z\",\"value\":1.5}]\n",
+		},
+		{
+			name: "Bad_Request_Wrong_From",
+			args: args{
+				request: "/history/gauge/Alloc?from=yesterday",
+			},
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().History(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				return st
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Not_Implemented_History_Disabled",
+			args: args{
+				request: "/history/gauge/Alloc",
+			},
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st, _ := repository.NewMemRepository(nil, zap.NewNop().Sugar())
+				return service.NewMetricCollector(st, zap.NewNop().Sugar())
+			},
+			wantStatus: http.StatusNotImplemented,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			h := NewMetricHandler(tt.collector(mockCtrl), zap.NewNop().Sugar())
+
+			router := mux.NewRouter()
+			router.HandleFunc("/history/{type}/{name}", h.HistoryHandler)
+
+			srv := httptest.NewServer(router)
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL + tt.args.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			b, _ := io.ReadAll(resp.Body)
+			require.Equal(t, tt.wantBody, string(b))
+		})
+	}
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 
@@ -53,38 +54,53 @@ func (mr *MockCollectorInterfaceMockRecorder) All(arg0 interface{}) *gomock.Call
 }
 
 // Find mocks base method.
-func (m *MockCollectorInterface) Find(arg0 context.Context, arg1, arg2 string) (*metric.Metrics, error) {
+func (m *MockCollectorInterface) Find(arg0 context.Context, arg1, arg2 string, arg3 metric.Labels) (*metric.Metrics, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Find", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "Find", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(*metric.Metrics)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Find indicates an expected call of Find.
-func (mr *MockCollectorInterfaceMockRecorder) Find(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockCollectorInterfaceMockRecorder) Find(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockCollectorInterface)(nil).Find), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockCollectorInterface)(nil).Find), arg0, arg1, arg2, arg3)
+}
+
+// History mocks base method.
+func (m *MockCollectorInterface) History(arg0 context.Context, arg1, arg2 string, arg3 metric.Labels, arg4 time.Duration, arg5, arg6 time.Time) ([]metric.Sample, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+	ret0, _ := ret[0].([]metric.Sample)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// History indicates an expected call of History.
+func (mr *MockCollectorInterfaceMockRecorder) History(arg0, arg1, arg2, arg3, arg4, arg5, arg6 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockCollectorInterface)(nil).History), arg0, arg1, arg2, arg3, arg4, arg5, arg6)
 }
 
 // Remove mocks base method.
-func (m *MockCollectorInterface) Remove(arg0 context.Context, arg1, arg2 string) error {
+func (m *MockCollectorInterface) Remove(arg0 context.Context, arg1, arg2 string, arg3 metric.Labels) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Remove", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "Remove", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Remove indicates an expected call of Remove.
-func (mr *MockCollectorInterfaceMockRecorder) Remove(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockCollectorInterfaceMockRecorder) Remove(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockCollectorInterface)(nil).Remove), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockCollectorInterface)(nil).Remove), arg0, arg1, arg2, arg3)
 }
 
 // Save mocks base method.
 func (m *MockCollectorInterface) Save(arg0 context.Context, arg1 metric.Metrics) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Merge", arg0, arg1)
+	ret := m.ctrl.Call(m, "Save", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
@@ -92,7 +108,7 @@ func (m *MockCollectorInterface) Save(arg0 context.Context, arg1 metric.Metrics)
 // Save indicates an expected call of Save.
 func (mr *MockCollectorInterfaceMockRecorder) Save(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockCollectorInterface)(nil).Save), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockCollectorInterface)(nil).Save), arg0, arg1)
 }
 
 // SaveAll mocks base method.
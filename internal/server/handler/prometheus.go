@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"bufio"
+	"cmp"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/repository"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// PrometheusHandler структура для выдачи метрик в формате Prometheus.
+type PrometheusHandler struct {
+	collector CollectorInterface
+	logger    *zap.SugaredLogger
+}
+
+// NewPrometheusHandler конструктор.
+func NewPrometheusHandler(collector CollectorInterface, logger *zap.SugaredLogger) *PrometheusHandler {
+	return &PrometheusHandler{
+		collector: collector,
+		logger:    logger,
+	}
+}
+
+// Metrics обработчик, отдающий все метрики в текстовом формате Prometheus.
+func (p *PrometheusHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	entities, err := p.collector.All(r.Context())
+	if err != nil {
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("content-type", prometheusContentType)
+	if err = WritePrometheus(w, entities); err != nil {
+		p.logger.Errorln("Failed to write response", zap.Error(err))
+	}
+}
+
+// promFamily семейство метрик Prometheus: одно имя и один тип.
+type promFamily struct {
+	name     string
+	mType    metric.Type
+	entities []repository.MetricEntity
+}
+
+// WritePrometheus пишет метрики в текстовом формате Prometheus.
+// Семейства и серии внутри них упорядочены по имени и меткам.
+func WritePrometheus(w io.Writer, entities []repository.MetricEntity) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range promFamilies(entities) {
+		bw.WriteString("# TYPE " + f.name + " " + promType(f.mType) + "\n")
+		for _, e := range f.entities {
+			writePromSeries(bw, f.name, e)
+		}
+	}
+	return bw.Flush()
+}
+
+func promFamilies(entities []repository.MetricEntity) []*promFamily {
+	byKey := make(map[string]*promFamily)
+	for _, e := range entities {
+		if promType(e.Type) == "" {
+			continue
+		}
+		name := SanitizePromName(e.Name)
+		key := name + "\x00" + string(e.Type)
+		f, ok := byKey[key]
+		if !ok {
+			f = &promFamily{name: name, mType: e.Type}
+			byKey[key] = f
+		}
+		f.entities = append(f.entities, e)
+	}
+
+	families := make([]*promFamily, 0, len(byKey))
+	for _, f := range byKey {
+		f.entities = uniqueSeries(f.name, f.entities)
+		families = append(families, f)
+	}
+	slices.SortFunc(families, func(a, b *promFamily) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), cmp.Compare(a.mType, b.mType))
+	})
+	assignPromNames(families)
+
+	return families
+}
+
+// uniqueSeries сортирует серии по меткам и убирает серии с одинаковыми метками, которые получаются,
+// когда разные имена (a.b и a_b) совпали после SanitizePromName. Остается серия с именем без замен,
+// иначе - с меньшим исходным именем.
+func uniqueSeries(name string, entities []repository.MetricEntity) []repository.MetricEntity {
+	slices.SortFunc(entities, func(a, b repository.MetricEntity) int {
+		return cmp.Or(
+			cmp.Compare(a.Labels.String(), b.Labels.String()),
+			cmp.Compare(boolOrder(a.Name != name), boolOrder(b.Name != name)),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+	return slices.CompactFunc(entities, func(a, b repository.MetricEntity) bool {
+		return a.Labels.String() == b.Labels.String()
+	})
+}
+
+// assignPromNames делает имена семейств уникальными: одно имя не может иметь несколько типов,
+// и имена серий гистограммы (_bucket, _sum, _count) не должны совпадать с другими семействами.
+// Семейства в порядке сортировки занимают свои имена, остальным добавляется суффикс типа,
+// а при его занятости - номер.
+func assignPromNames(families []*promFamily) {
+	used := make(map[string]struct{})
+	free := func(f *promFamily, name string) bool {
+		for _, n := range promSeriesNames(name, f.mType) {
+			if _, ok := used[n]; ok {
+				return false
+			}
+		}
+		return true
+	}
+	claim := func(f *promFamily, name string) {
+		f.name = name
+		for _, n := range promSeriesNames(name, f.mType) {
+			used[n] = struct{}{}
+		}
+	}
+
+	var renamed []*promFamily
+	for _, f := range families {
+		if free(f, f.name) {
+			claim(f, f.name)
+			continue
+		}
+		renamed = append(renamed, f)
+	}
+	for _, f := range renamed {
+		name := f.name + "_" + string(f.mType)
+		for i := 2; !free(f, name); i++ {
+			name = f.name + "_" + string(f.mType) + "_" + strconv.Itoa(i)
+		}
+		claim(f, name)
+	}
+}
+
+// promSeriesNames имена серий семейства name типа t.
+func promSeriesNames(name string, t metric.Type) []string {
+	if t == metric.TypeHistogram {
+		return []string{name, name + "_bucket", name + "_sum", name + "_count"}
+	}
+	return []string{name}
+}
+
+func boolOrder(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func writePromSeries(w *bufio.Writer, name string, e repository.MetricEntity) {
+	switch e.Type {
+	case metric.TypeCounter:
+		writePromLine(w, name, e.Labels, "", strconv.FormatInt(e.Delta, 10))
+	case metric.TypeGauge:
+		writePromLine(w, name, e.Labels, "", formatPromFloat(e.Value))
+	case metric.TypeHistogram:
+		h := e.Histogram
+		if h == nil {
+			return
+		}
+		var cumulative int64
+		for i, c := range h.Counts {
+			cumulative += c
+			le := "+Inf"
+			if i < len(h.Bounds) {
+				le = formatPromFloat(h.Bounds[i])
+			}
+			writePromLine(w, name+"_bucket", e.Labels, le, strconv.FormatInt(cumulative, 10))
+		}
+		writePromLine(w, name+"_sum", e.Labels, "", formatPromFloat(h.Sum))
+		writePromLine(w, name+"_count", e.Labels, "", strconv.FormatInt(h.Count, 10))
+	}
+}
+
+func writePromLine(w *bufio.Writer, name string, labels metric.Labels, le, value string) {
+	w.WriteString(name)
+
+	keys := slices.Sorted(func(yield func(string) bool) {
+		for k := range labels {
+			if k == "le" && le != "" {
+				continue
+			}
+			if !yield(k) {
+				return
+			}
+		}
+	})
+	if len(keys) > 0 || le != "" {
+		w.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(k + `="` + labelValueReplacer.Replace(labels[k]) + `"`)
+		}
+		if le != "" {
+			if len(keys) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(`le="` + le + `"`)
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteString(" " + value + "\n")
+}
+
+func promType(t metric.Type) string {
+	switch t {
+	case metric.TypeCounter:
+		return "counter"
+	case metric.TypeGauge:
+		return "gauge"
+	case metric.TypeHistogram:
+		return "histogram"
+	}
+	return ""
+}
+
+func formatPromFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// SanitizePromName приводит имя к правилам Prometheus [a-zA-Z_:][a-zA-Z0-9_:]*,
+// заменяя недопустимые символы на '_'.
+func SanitizePromName(name string) string {
+	if name == "" {
+		return "_"
+	}
+
+	b := []byte(name)
+	for i, c := range b {
+		if c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			continue
+		}
+		b[i] = '_'
+	}
+	// ведущая цифра сохраняется, перед ней добавляется '_'.
+	if b[0] >= '0' && b[0] <= '9' {
+		b = append([]byte{'_'}, b...)
+	}
+	return string(b)
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/handler/mocks"
+	"github.com/ktigay/metrics-collector/internal/server/repository"
+)
+
+func TestPrometheusHandler_Metrics(t *testing.T) {
+	tests := []struct {
+		name            string
+		collector       func(controller *gomock.Controller) CollectorInterface
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name: "Positive_test_all_types",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().All(gomock.Any()).Return([]repository.MetricEntity{
+					{Key: "gauge:Alloc", Type: metric.TypeGauge, Name: "Alloc", Value: 1.5},
+					{Key: "counter:PollCount", Type: metric.TypeCounter, Name: "PollCount", Delta: 10},
+					{
+						Key:    `gauge:Alloc{host="b"}`,
+						Type:   metric.TypeGauge,
+						Name:   "Alloc",
+						Value:  2,
+						Labels: metric.Labels{"host": "b"},
+					},
+					{
+						Key:  "histogram:req.latency",
+						Type: metric.TypeHistogram,
+						Name: "req.latency",
+						Histogram: &metric.Histogram{
+							Bounds: []float64{0.1, 1},
+							Counts: []int64{1, 2, 3},
+							Sum:    7.5,
+							Count:  6,
+						},
+						Labels: metric.Labels{"path": `/a"b`},
+					},
+				}, nil).Times(1)
+				return st
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: prometheusContentType,
+			wantBody: `# TYPE Alloc gauge
+Alloc 1.5
+Alloc{host="b"} 2
+# TYPE PollCount counter
+PollCount 10
+# TYPE req_latency histogram
+req_latency_bucket{path="/a\"b",le="0.1"} 1
+req_latency_bucket{path="/a\"b",le="1"} 3
+req_latency_bucket{path="/a\"b",le="+Inf"} 6
+req_latency_sum{path="/a\"b"} 7.5
+req_latency_count{path="/a\"b"} 6
+`,
+		},
+		{
+			name: "Positive_test_type_conflict",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().All(gomock.Any()).Return([]repository.MetricEntity{
+					{Key: "gauge:1x", Type: metric.TypeGauge, Name: "1x", Value: 3},
+					{Key: "counter:1x", Type: metric.TypeCounter, Name: "1x", Delta: 4},
+				}, nil).Times(1)
+				return st
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: prometheusContentType,
+			wantBody: `# TYPE _1x counter
+_1x 4
+# TYPE _1x_gauge gauge
+_1x_gauge 3
+`,
+		},
+		{
+			name: "Positive_test_same_name_all_types",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().All(gomock.Any()).Return([]repository.MetricEntity{
+					{Key: "counter:x", Type: metric.TypeCounter, Name: "x", Delta: 1},
+					{Key: "gauge:x", Type: metric.TypeGauge, Name: "x", Value: 2},
+					{
+						Key:       "histogram:x",
+						Type:      metric.TypeHistogram,
+						Name:      "x",
+						Histogram: &metric.Histogram{Counts: []int64{1}, Sum: 3, Count: 1},
+					},
+					{Key: "gauge:x_gauge", Type: metric.TypeGauge, Name: "x_gauge", Value: 4},
+					{Key: "gauge:x_histogram_sum", Type: metric.TypeGauge, Name: "x_histogram_sum", Value: 5},
+				}, nil).Times(1)
+				return st
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: prometheusContentType,
+			wantBody: `# TYPE x counter
+x 1
+# TYPE x_gauge_2 gauge
+x_gauge_2 2
+# TYPE x_histogram_2 histogram
+x_histogram_2_bucket{le="+Inf"} 1
+x_histogram_2_sum 3
+x_histogram_2_count 1
+# TYPE x_gauge gauge
+x_gauge 4
+# TYPE x_histogram_sum gauge
+x_histogram_sum 5
+`,
+		},
+		{
+			name: "Positive_test_sanitized_duplicates",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().All(gomock.Any()).Return([]repository.MetricEntity{
+					{Key: "gauge:a.b", Type: metric.TypeGauge, Name: "a.b", Value: 1},
+					{Key: "gauge:a_b", Type: metric.TypeGauge, Name: "a_b", Value: 2},
+					{Key: `gauge:a.b{host="h"}`, Type: metric.TypeGauge, Name: "a.b", Value: 3, Labels: metric.Labels{"host": "h"}},
+					{Key: `gauge:a-b{host="h"}`, Type: metric.TypeGauge, Name: "a-b", Value: 4, Labels: metric.Labels{"host": "h"}},
+				}, nil).Times(1)
+				return st
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: prometheusContentType,
+			wantBody: `# TYPE a_b gauge
+a_b 2
+a_b{host="h"} 4
+`,
+		},
+		{
+			name: "Negative_test_collector_error",
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().All(gomock.Any()).Return(nil, errors.New("boom")).Times(1)
+				return st
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			h := NewPrometheusHandler(tt.collector(mockCtrl), zap.NewNop().Sugar())
+
+			router := mux.NewRouter()
+			router.HandleFunc("/metrics", h.Metrics)
+
+			srv := httptest.NewServer(router)
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL + "/metrics")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantContentType != "" {
+				require.Equal(t, tt.wantContentType, resp.Header.Get("Content-Type"))
+			}
+
+			b, _ := io.ReadAll(resp.Body)
+			require.Equal(t, tt.wantBody, string(b))
+		})
+	}
+}
+
+func TestSanitizePromName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "valid", in: "go_gc:total", want: "go_gc:total"},
+		{name: "dots_and_dashes", in: "req.latency-ms", want: "req_latency_ms"},
+		{name: "leading_digit", in: "5xx", want: "_5xx"},
+		{name: "leading_digit_and_dash", in: "1a-b", want: "_1a_b"},
+		{name: "leading_digit_and_dot", in: "9.x", want: "_9_x"},
+		{name: "single_digit", in: "7", want: "_7"},
+		{name: "empty", in: "", want: "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, SanitizePromName(tt.in))
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/golang/snappy"
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/server/remotewrite"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
+)
+
+// RemoteWriteHandler структура для приема метрик по протоколу Prometheus remote_write.
+type RemoteWriteHandler struct {
+	collector CollectorInterface
+	logger    *zap.SugaredLogger
+	locks     tenant.Locks
+	converter *remotewrite.Converter
+	// maxDecompressed максимальный размер тела после распаковки snappy, 0 - без ограничений.
+	maxDecompressed int64
+}
+
+// NewRemoteWriteHandler конструктор. maxDecompressed - максимальный размер тела
+// после распаковки в байтах, 0 - без ограничений.
+func NewRemoteWriteHandler(collector CollectorInterface, maxDecompressed int64, logger *zap.SugaredLogger) *RemoteWriteHandler {
+	return &RemoteWriteHandler{
+		collector:       collector,
+		logger:          logger,
+		converter:       remotewrite.NewConverter(),
+		maxDecompressed: maxDecompressed,
+	}
+}
+
+// Write обработчик запроса remote_write: snappy-сжатый protobuf WriteRequest.
+func (rw *RemoteWriteHandler) Write(w http.ResponseWriter, r *http.Request) {
+	compressed, err := io.ReadAll(r.Body)
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			rw.logger.Warnf("request body exceeds %d bytes", mbe.Limit)
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		rw.logger.Errorln("Failed to read request", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// размер после распаковки записан в заголовке блока snappy: проверяется до выделения памяти.
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		rw.logger.Errorln("Failed to decode snappy", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if rw.maxDecompressed > 0 && int64(n) > rw.maxDecompressed {
+		rw.logger.Warnf("decompressed body %d bytes exceeds %d bytes", n, rw.maxDecompressed)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	b, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		rw.logger.Errorln("Failed to decode snappy", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	wr, err := remotewrite.Unmarshal(b)
+	if err != nil {
+		rw.logger.Errorln("Failed to unmarshal write request", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// конвертация и сохранение под блокировкой тенанта, чтобы приращения счетчиков не задваивались.
+	t := tenant.FromContext(r.Context())
+	unlock := rw.locks.Lock(t)
+	defer unlock()
+
+	mm, totals := rw.converter.Convert(t, wr)
+	if len(mm) > 0 {
+		if err = rw.collector.SaveAll(r.Context(), mm); err != nil {
+			w.WriteHeader(statusFromError(err))
+			return
+		}
+	}
+	rw.converter.Commit(totals)
+
+	w.WriteHeader(http.StatusNoContent)
+}
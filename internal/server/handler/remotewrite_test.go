@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/snappy"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/errors"
+	"github.com/ktigay/metrics-collector/internal/server/handler/mocks"
+	"github.com/ktigay/metrics-collector/internal/server/remotewrite"
+)
+
+func TestRemoteWriteHandler_Write(t *testing.T) {
+	wr := &remotewrite.WriteRequest{
+		Timeseries: []remotewrite.TimeSeries{
+			{
+				Labels:  []remotewrite.Label{{Name: "__name__", Value: "node_load1"}, {Name: "instance", Value: "a"}},
+				Samples: []remotewrite.Sample{{Value: 0.5, Timestamp: 1}},
+			},
+			{
+				Labels:  []remotewrite.Label{{Name: "__name__", Value: "node_forks_total"}},
+				Samples: []remotewrite.Sample{{Value: 42, Timestamp: 1}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		body       []byte
+		collector  func(controller *gomock.Controller) CollectorInterface
+		wantStatus int
+	}{
+		{
+			name: "Positive_test",
+			body: snappy.Encode(nil, wr.Marshal()),
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				// первое значение счетчика - база, приращение 0.
+				v, d := 0.5, int64(0)
+				st.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+					{ID: "node_load1", Type: "gauge", Value: &v, Labels: metric.Labels{"instance": "a"}},
+					{ID: "node_forks_total", Type: "counter", Delta: &d},
+				}).Return(nil).Times(1)
+				return st
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "Bad_Request_Not_Snappy",
+			body: wr.Marshal(),
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().SaveAll(gomock.Any(), gomock.Any()).Times(0)
+				return st
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Too_Large_Decompressed",
+			body: snappy.Encode(nil, make([]byte, 2048)),
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().SaveAll(gomock.Any(), gomock.Any()).Times(0)
+				return st
+			},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "Bad_Request_Malformed_Protobuf",
+			body: snappy.Encode(nil, []byte{0x0a, 0x05, 0x01}),
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().SaveAll(gomock.Any(), gomock.Any()).Times(0)
+				return st
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Bad_Request_Save_Error",
+			body: snappy.Encode(nil, wr.Marshal()),
+			collector: func(mockCtrl *gomock.Controller) CollectorInterface {
+				st := mocks.NewMockCollectorInterface(mockCtrl)
+				st.EXPECT().SaveAll(gomock.Any(), gomock.Any()).Return(errors.ErrWrongValue).Times(1)
+				return st
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			h := NewRemoteWriteHandler(tt.collector(mockCtrl), 1024, zap.NewNop().Sugar())
+
+			router := mux.NewRouter()
+			router.HandleFunc("/api/v1/write", h.Write)
+
+			srv := httptest.NewServer(router)
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/write", bytes.NewReader(tt.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Encoding", "snappy")
+			req.Header.Set("Content-Type", "application/x-protobuf")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package influx
+
+import (
+	"slices"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+// ToMetrics преобразует точки в метрики с именами measurement_field.
+// Теги становятся метками, недопустимые символы в именах тегов заменяются на '_'.
+// Целые поля становятся счетчиками: значение считается приращением, поэтому
+// накопительные целые поля (например, счетчики Telegraf) надо отправлять как float,
+// иначе каждое значение будет прибавлено к счетчику целиком.
+// Числа с плавающей точкой и логические - gauge. Строковые поля пропускаются,
+// время точки не сохраняется.
+func ToMetrics(points []Point) []metric.Metrics {
+	var mm []metric.Metrics
+	for _, p := range points {
+		labels := tagLabels(p.Tags)
+
+		for _, f := range p.Fields {
+			m := metric.Metrics{
+				ID:     p.Measurement + "_" + f.Key,
+				Labels: labels.Clone(),
+			}
+			switch f.Kind {
+			case FieldInteger:
+				d := f.Int
+				m.Type, m.Delta = string(metric.TypeCounter), &d
+			case FieldFloat, FieldBool:
+				v := f.Float
+				m.Type, m.Value = string(metric.TypeGauge), &v
+			default:
+				continue
+			}
+			mm = append(mm, m)
+		}
+	}
+	return mm
+}
+
+// tagLabels метки из тегов. Если имена тегов совпали после замены символов,
+// остается тег с меньшим исходным именем.
+func tagLabels(tags map[string]string) metric.Labels {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	labels := make(metric.Labels, len(tags))
+	for _, k := range keys {
+		name := metric.SanitizeLabelName(k)
+		if _, ok := labels[name]; !ok {
+			labels[name] = tags[k]
+		}
+	}
+	return labels
+}
@@ -0,0 +1,145 @@
+package influx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    Point
+		wantErr bool
+	}{
+		{
+			name: "tags_fields_timestamp",
+			line: "cpu,host=a,region=eu usage=0.5,count=3i 1735689600000000000",
+			want: Point{
+				Measurement: "cpu",
+				Tags:        map[string]string{"host": "a", "region": "eu"},
+				Fields: []Field{
+					{Key: "usage", Kind: FieldFloat, Float: 0.5},
+					{Key: "count", Kind: FieldInteger, Int: 3},
+				},
+				Timestamp: 1735689600000000000,
+			},
+		},
+		{
+			name: "escaped_and_quoted",
+			line: `disk\ io,path=/mnt/a\,b ok=true,msg="hello, \"world\"",free=7u`,
+			want: Point{
+				Measurement: "disk io",
+				Tags:        map[string]string{"path": "/mnt/a,b"},
+				Fields: []Field{
+					{Key: "ok", Kind: FieldBool, Float: 1},
+					{Key: "msg", Kind: FieldString, Str: `hello, "world"`},
+					{Key: "free", Kind: FieldInteger, Int: 7},
+				},
+			},
+		},
+		{
+			name:    "missing_fields",
+			line:    "cpu,host=a",
+			wantErr: true,
+		},
+		{
+			name:    "bad_tag",
+			line:    "cpu,host usage=1",
+			wantErr: true,
+		},
+		{
+			name:    "bad_integer",
+			line:    "cpu count=1.5i",
+			wantErr: true,
+		},
+		{
+			name:    "bad_timestamp",
+			line:    "cpu usage=1 now",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.wantErr {
+				require.True(t, errors.Is(err, ErrInvalidLine))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	points, err := Parse(strings.NewReader("# comment\ncpu usage=1\n\nmem used=2i\n"))
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+
+	_, err = Parse(strings.NewReader("cpu usage=1\n\ncpu usage=\n"))
+	var pe *ParseError
+	require.True(t, errors.As(err, &pe))
+	require.Equal(t, 3, pe.Line)
+}
+
+func TestToMetrics(t *testing.T) {
+	usage, count := 0.5, int64(3)
+	got := ToMetrics([]Point{
+		{
+			Measurement: "cpu",
+			Tags:        map[string]string{"host": "a"},
+			Fields: []Field{
+				{Key: "usage", Kind: FieldFloat, Float: 0.5},
+				{Key: "count", Kind: FieldInteger, Int: 3},
+				{Key: "state", Kind: FieldString, Str: "ok"},
+			},
+		},
+	})
+	require.Equal(t, []metric.Metrics{
+		{ID: "cpu_usage", Type: "gauge", Value: &usage, Labels: metric.Labels{"host": "a"}},
+		{ID: "cpu_count", Type: "counter", Delta: &count, Labels: metric.Labels{"host": "a"}},
+	}, got)
+}
+
+func TestToMetrics_TagNames(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want metric.Labels
+	}{
+		{
+			name: "Positive_test_valid",
+			tags: map[string]string{"host": "a"},
+			want: metric.Labels{"host": "a"},
+		},
+		{
+			name: "Positive_test_sanitized",
+			tags: map[string]string{"host-name": "a", "dc.zone": "b", "1st": "c", "путь": "d"},
+			want: metric.Labels{"host_name": "a", "dc_zone": "b", "_1st": "c", "________": "d"},
+		},
+		{
+			name: "Positive_test_collision",
+			tags: map[string]string{"a-b": "1", "a.b": "2"},
+			want: metric.Labels{"a_b": "1"},
+		},
+		{
+			name: "Positive_test_no_tags",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ToMetrics([]Point{
+				{Measurement: "cpu", Tags: tt.tags, Fields: []Field{{Key: "usage", Kind: FieldFloat, Float: 1}}},
+			})
+			require.Len(t, got, 1)
+			require.Equal(t, tt.want, got[0].Labels)
+			require.NoError(t, got[0].Labels.Validate())
+		})
+	}
+}
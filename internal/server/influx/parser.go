@@ -0,0 +1,220 @@
+// Package influx Прием метрик в формате InfluxDB line protocol.
+package influx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const maxLineSize = 1024 * 1024
+
+// ErrInvalidLine некорректная строка.
+var ErrInvalidLine = errors.New("invalid line")
+
+// ParseError ошибка разбора с номером строки.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+// Error ошибка в строку.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap исходная ошибка.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// FieldKind тип значения поля.
+type FieldKind int
+
+const (
+	// FieldFloat число с плавающей точкой.
+	FieldFloat FieldKind = iota
+	// FieldInteger целое (суффикс i или u).
+	FieldInteger
+	// FieldBool логическое значение.
+	FieldBool
+	// FieldString строка.
+	FieldString
+)
+
+// Field поле точки.
+type Field struct {
+	Key   string
+	Kind  FieldKind
+	Float float64
+	Int   int64
+	Str   string
+}
+
+// Point точка: measurement,tag=value field=value [timestamp].
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      []Field
+	// Timestamp в наносекундах, 0 если не указан.
+	Timestamp int64
+}
+
+// Parse разбирает точки из r. Пустые строки и комментарии (#) пропускаются.
+// Ошибка возвращается как *ParseError с номером строки.
+func Parse(r io.Reader) ([]Point, error) {
+	var (
+		points []Point
+		n      int
+	)
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := ParseLine(line)
+		if err != nil {
+			return nil, &ParseError{Line: n, Err: err}
+		}
+		points = append(points, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, &ParseError{Line: n + 1, Err: err}
+	}
+
+	return points, nil
+}
+
+// ParseLine разбирает одну строку.
+func ParseLine(line string) (Point, error) {
+	var p Point
+
+	sections := splitUnescaped(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return p, fmt.Errorf("%w: expected measurement, fields and optional timestamp", ErrInvalidLine)
+	}
+
+	series := splitUnescaped(sections[0], ',', false)
+	if p.Measurement = unescape(series[0]); p.Measurement == "" {
+		return p, fmt.Errorf("%w: missing measurement", ErrInvalidLine)
+	}
+	for _, t := range series[1:] {
+		kv := splitUnescaped(t, '=', false)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return p, fmt.Errorf("%w: bad tag %q", ErrInvalidLine, t)
+		}
+		if p.Tags == nil {
+			p.Tags = make(map[string]string, len(series)-1)
+		}
+		p.Tags[unescape(kv[0])] = unescape(kv[1])
+	}
+
+	for _, f := range splitUnescaped(sections[1], ',', true) {
+		kv := splitUnescaped(f, '=', true)
+		if len(kv) != 2 || kv[0] == "" {
+			return p, fmt.Errorf("%w: bad field %q", ErrInvalidLine, f)
+		}
+		field, err := parseField(unescape(kv[0]), kv[1])
+		if err != nil {
+			return p, err
+		}
+		p.Fields = append(p.Fields, field)
+	}
+
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("%w: bad timestamp %q", ErrInvalidLine, sections[2])
+		}
+		p.Timestamp = ts
+	}
+
+	return p, nil
+}
+
+func parseField(key, v string) (Field, error) {
+	f := Field{Key: key}
+	if v == "" {
+		return f, fmt.Errorf("%w: empty value of field %q", ErrInvalidLine, key)
+	}
+
+	var err error
+	switch {
+	case v[0] == '"':
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return f, fmt.Errorf("%w: unterminated string in field %q", ErrInvalidLine, key)
+		}
+		f.Kind = FieldString
+		f.Str = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(v[1 : len(v)-1])
+	case strings.HasSuffix(v, "i"):
+		f.Kind = FieldInteger
+		f.Int, err = strconv.ParseInt(v[:len(v)-1], 10, 64)
+	case strings.HasSuffix(v, "u"):
+		var u uint64
+		f.Kind = FieldInteger
+		if u, err = strconv.ParseUint(v[:len(v)-1], 10, 63); err == nil {
+			f.Int = int64(u)
+		}
+	default:
+		switch v {
+		case "t", "T", "true", "True", "TRUE":
+			f.Kind, f.Float = FieldBool, 1
+		case "f", "F", "false", "False", "FALSE":
+			f.Kind, f.Float = FieldBool, 0
+		default:
+			f.Kind = FieldFloat
+			f.Float, err = strconv.ParseFloat(v, 64)
+		}
+	}
+	if err != nil {
+		return f, fmt.Errorf("%w: bad value %q of field %q", ErrInvalidLine, v, key)
+	}
+
+	return f, nil
+}
+
+// splitUnescaped делит строку по sep, пропуская экранированные символы
+// и, если quoted, содержимое двойных кавычек.
+func splitUnescaped(s string, sep byte, quoted bool) []string {
+	var (
+		parts    []string
+		start    int
+		inQuotes bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '"' && quoted:
+			inQuotes = !inQuotes
+		case c == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case ',', '=', ' ', '"', '\\':
+				i++
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,69 @@
+package interceptor
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ktigay/metrics-collector/internal/server/auth"
+)
+
+// AuthUnary аутентифицирует агента по токену из метаданных authorization и помещает его в контекст.
+// store == nil - аутентификация отключена.
+func AuthUnary(logger *zap.SugaredLogger, store auth.Store) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if store == nil {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, store, logger)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthStream аутентифицирует агента потока, см. [AuthUnary].
+func AuthStream(logger *zap.SugaredLogger, store auth.Store) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if store == nil {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), store, logger)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context контекст с агентом и тенантом.
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context, store auth.Store, logger *zap.SugaredLogger) (context.Context, error) {
+	token := auth.TokenFromHeader(metadataValue(ctx, "authorization"))
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	agent, err := store.Lookup(ctx, token)
+	if err != nil {
+		logger.Errorf("Auth: %v", err)
+		return nil, status.Error(codes.Internal, "can't authenticate agent")
+	}
+	if agent == nil {
+		logger.Warn("Auth: unknown token")
+		return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+	return auth.WithAgent(ctx, agent), nil
+}
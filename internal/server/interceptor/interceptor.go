@@ -0,0 +1,138 @@
+// Package interceptor gRPC-перехватчики сервера.
+package interceptor
+
+import (
+	"context"
+	"crypto/hmac"
+	"io"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	gproto "google.golang.org/protobuf/proto"
+
+	pb "github.com/ktigay/metrics-collector/internal/proto"
+	"github.com/ktigay/metrics-collector/internal/server/keyring"
+)
+
+// CheckSumUnary проверяет подпись запроса из метаданных ключом из keys.
+// Все методы сервиса пишут метрики, поэтому запросы без подписи отклоняются. Пустой keys - проверка отключена.
+func CheckSumUnary(logger *zap.SugaredLogger, keys keyring.Keyring) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if len(keys) == 0 {
+			return handler(ctx, req)
+		}
+		checkSum, err := requireCheckSum(ctx, logger)
+		if err != nil {
+			return nil, err
+		}
+		hashKey, err := lookupKey(ctx, keys, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := req.(gproto.Message)
+		if !ok {
+			return nil, status.Error(codes.Internal, "request is not a protobuf message")
+		}
+		sum, err := pb.Hash(hashKey, metadataValue(ctx, pb.TimestampMetadataKey), metadataValue(ctx, pb.NonceMetadataKey), m)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if !hmac.Equal([]byte(sum), []byte(checkSum)) {
+			logger.Warnf("CheckSumUnary: invalid checksum %s", checkSum)
+			return nil, status.Error(codes.InvalidArgument, "invalid checksum")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// CheckSumStream проверяет подпись потока сообщений клиента.
+// Подпись сверяется по окончании потока: вместо io.EOF обработчик получит ошибку, если подпись не совпала.
+func CheckSumStream(logger *zap.SugaredLogger, keys keyring.Keyring) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if len(keys) == 0 {
+			return handler(srv, ss)
+		}
+		checkSum, err := requireCheckSum(ss.Context(), logger)
+		if err != nil {
+			return err
+		}
+		hashKey, err := lookupKey(ss.Context(), keys, logger)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &checkSumStream{
+			ServerStream: ss,
+			hasher: pb.NewHasher(hashKey,
+				metadataValue(ss.Context(), pb.TimestampMetadataKey),
+				metadataValue(ss.Context(), pb.NonceMetadataKey),
+			),
+			checkSum: checkSum,
+			logger:   logger,
+		})
+	}
+}
+
+type checkSumStream struct {
+	grpc.ServerStream
+	hasher   *pb.Hasher
+	checkSum string
+	logger   *zap.SugaredLogger
+}
+
+// RecvMsg читает сообщение и добавляет его в подпись.
+func (s *checkSumStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == io.EOF {
+		if !hmac.Equal([]byte(s.hasher.Sum()), []byte(s.checkSum)) {
+			s.logger.Warnf("CheckSumStream: invalid checksum %s", s.checkSum)
+			return status.Error(codes.InvalidArgument, "invalid checksum")
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if msg, ok := m.(gproto.Message); ok {
+		if err = s.hasher.Write(msg); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	return nil
+}
+
+func requireCheckSum(ctx context.Context, logger *zap.SugaredLogger) (string, error) {
+	checkSum := metadataValue(ctx, pb.HashMetadataKey)
+	if checkSum == "" {
+		logger.Warn("CheckSum: missing checksum")
+		return "", status.Error(codes.InvalidArgument, "missing checksum")
+	}
+	return checkSum, nil
+}
+
+func lookupKey(ctx context.Context, keys keyring.Keyring, logger *zap.SugaredLogger) (string, error) {
+	keyID := metadataValue(ctx, pb.KeyIDMetadataKey)
+	hashKey, ok := keys.Key(keyID)
+	if !ok {
+		logger.Warnf("CheckSum: unknown key id %s", keyID)
+		return "", status.Error(codes.InvalidArgument, "unknown key id")
+	}
+	return hashKey, nil
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
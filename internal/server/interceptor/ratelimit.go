@@ -0,0 +1,60 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/ktigay/metrics-collector/internal/server/auth"
+	"github.com/ktigay/metrics-collector/internal/server/ratelimit"
+	"github.com/ktigay/metrics-collector/internal/server/subnet"
+)
+
+// RateLimitUnary ограничивает частоту вызовов агента, а без аутентификации - IP клиента из соединения.
+// Должен идти после [AuthUnary]. limiter == nil - ограничение отключено.
+func RateLimitUnary(logger *zap.SugaredLogger, limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := allow(ctx, limiter, logger); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitStream ограничивает частоту открытия потоков, см. [RateLimitUnary].
+func RateLimitStream(logger *zap.SugaredLogger, limiter *ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := allow(ss.Context(), limiter, logger); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func allow(ctx context.Context, limiter *ratelimit.Limiter, logger *zap.SugaredLogger) error {
+	if limiter == nil {
+		return nil
+	}
+
+	var ip net.IP
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = subnet.AddrIP(p.Addr)
+	}
+	key := "ip:" + ip.String()
+	if a, ok := auth.AgentFromContext(ctx); ok {
+		key = "agent:" + a.Name
+	}
+
+	if ok, retry := limiter.Allow(key); !ok {
+		logger.Warnf("RateLimit: too many requests from %s", key)
+		return status.Error(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded, retry after %ds", int(math.Ceil(retry.Seconds()))))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/ktigay/metrics-collector/internal/server/auth"
+	"github.com/ktigay/metrics-collector/internal/server/ratelimit"
+)
+
+func TestRateLimitUnary(t *testing.T) {
+	limit := RateLimitUnary(zap.NewNop().Sugar(), ratelimit.NewLimiter(1, 1))
+	call := func(ctx context.Context) codes.Code {
+		_, err := limit(ctx, nil, &grpc.UnaryServerInfo{}, func(context.Context, any) (any, error) {
+			return nil, nil
+		})
+		return status.Code(err)
+	}
+	fromIP := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 5000}})
+	}
+
+	require.Equal(t, codes.OK, call(fromIP("10.0.0.1")))
+	require.Equal(t, codes.ResourceExhausted, call(fromIP("10.0.0.1")))
+
+	// другой IP и агент ограничиваются отдельно, агент - независимо от IP.
+	require.Equal(t, codes.OK, call(fromIP("10.0.0.2")))
+	agent := &auth.Agent{Name: "web-1"}
+	require.Equal(t, codes.OK, call(auth.WithAgent(fromIP("10.0.0.1"), agent)))
+	require.Equal(t, codes.ResourceExhausted, call(auth.WithAgent(fromIP("10.0.0.3"), agent)))
+}
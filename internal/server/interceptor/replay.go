@@ -0,0 +1,52 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/ktigay/metrics-collector/internal/proto"
+	"github.com/ktigay/metrics-collector/internal/server/replay"
+)
+
+// ReplayUnary отклоняет подписанные запросы с устаревшим временем или повторным nonce из метаданных.
+// Должен идти после [CheckSumUnary]. guard == nil - защита отключена.
+func ReplayUnary(logger *zap.SugaredLogger, guard *replay.Guard) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkReplay(ctx, guard, logger); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ReplayStream проверяет время и nonce при открытии потока, см. [ReplayUnary].
+func ReplayStream(logger *zap.SugaredLogger, guard *replay.Guard) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkReplay(ss.Context(), guard, logger); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkReplay(ctx context.Context, guard *replay.Guard, logger *zap.SugaredLogger) error {
+	if guard == nil || metadataValue(ctx, pb.HashMetadataKey) == "" {
+		return nil
+	}
+	if err := guard.Check(
+		metadataValue(ctx, pb.TimestampMetadataKey),
+		metadataValue(ctx, pb.NonceMetadataKey),
+	); err != nil {
+		logger.Warnf("Replay: %v", err)
+		if errors.Is(err, replay.ErrFull) {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package interceptor
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/ktigay/metrics-collector/internal/proto"
+	"github.com/ktigay/metrics-collector/internal/server/replay"
+)
+
+func TestReplayUnary(t *testing.T) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name     string
+		md       []string
+		wantCode codes.Code
+	}{
+		{
+			name:     "Positive_test_signed",
+			md:       []string{pb.HashMetadataKey, "sum", pb.TimestampMetadataKey, now, pb.NonceMetadataKey, "a"},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "Positive_test_unsigned",
+			wantCode: codes.OK,
+		},
+		{
+			name:     "Negative_test_replayed",
+			md:       []string{pb.HashMetadataKey, "sum", pb.TimestampMetadataKey, now, pb.NonceMetadataKey, "a"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Negative_test_expired",
+			md:       []string{pb.HashMetadataKey, "sum", pb.TimestampMetadataKey, old, pb.NonceMetadataKey, "b"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Negative_test_no_nonce",
+			md:       []string{pb.HashMetadataKey, "sum", pb.TimestampMetadataKey, now},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	// кейсы выполняются последовательно с общим guard: Negative_test_replayed повторяет nonce первого кейса.
+	unary := ReplayUnary(zap.NewNop().Sugar(), replay.NewGuard(time.Minute, 100))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tt.md...))
+
+			_, err := unary(ctx, nil, &grpc.UnaryServerInfo{},
+				func(context.Context, any) (any, error) {
+					return nil, nil
+				})
+			require.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestReplayUnary_Disabled(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(pb.HashMetadataKey, "sum"))
+
+	_, err := ReplayUnary(zap.NewNop().Sugar(), nil)(ctx, nil, &grpc.UnaryServerInfo{},
+		func(context.Context, any) (any, error) {
+			return nil, nil
+		})
+	require.NoError(t, err)
+}
+
+func TestReplayUnary_Full(t *testing.T) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	unary := ReplayUnary(zap.NewNop().Sugar(), replay.NewGuard(time.Minute, 1))
+	call := func(nonce string) error {
+		ctx := metadata.NewIncomingContext(context.Background(),
+			metadata.Pairs(pb.HashMetadataKey, "sum", pb.TimestampMetadataKey, now, pb.NonceMetadataKey, nonce))
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{},
+			func(context.Context, any) (any, error) {
+				return nil, nil
+			})
+		return err
+	}
+
+	require.NoError(t, call("a"))
+	require.Equal(t, codes.Unavailable, status.Code(call("b")))
+	require.Equal(t, codes.InvalidArgument, status.Code(call("a")))
+}
@@ -0,0 +1,50 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/ktigay/metrics-collector/internal/server/subnet"
+)
+
+// TrustedSubnetUnary отклоняет вызовы с адресов вне доверенных подсетей.
+// Адрес берется из соединения, пустой список подсетей - проверка отключена.
+func TrustedSubnetUnary(logger *zap.SugaredLogger, subnets []*net.IPNet) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkSubnet(ctx, subnets, logger); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TrustedSubnetStream проверяет адрес клиента потока, см. [TrustedSubnetUnary].
+func TrustedSubnetStream(logger *zap.SugaredLogger, subnets []*net.IPNet) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkSubnet(ss.Context(), subnets, logger); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkSubnet(ctx context.Context, subnets []*net.IPNet, logger *zap.SugaredLogger) error {
+	if len(subnets) == 0 {
+		return nil
+	}
+	var ip net.IP
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = subnet.AddrIP(p.Addr)
+	}
+	if !subnet.Contains(subnets, ip) {
+		logger.Warnf("TrustedSubnet: untrusted ip %v", ip)
+		return status.Error(codes.PermissionDenied, "untrusted ip")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestTrustedSubnetUnary(t *testing.T) {
+	_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		subnets  []*net.IPNet
+		addr     net.Addr
+		wantCode codes.Code
+	}{
+		{
+			name:     "Positive_test_trusted_ip",
+			subnets:  []*net.IPNet{trusted},
+			addr:     &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 5000},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "Positive_test_no_subnets",
+			addr:     &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 5000},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "Negative_test_untrusted_ip",
+			subnets:  []*net.IPNet{trusted},
+			addr:     &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 5000},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "Negative_test_no_peer",
+			subnets:  []*net.IPNet{trusted},
+			wantCode: codes.PermissionDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.addr != nil {
+				ctx = peer.NewContext(ctx, &peer.Peer{Addr: tt.addr})
+			}
+
+			_, err := TrustedSubnetUnary(zap.NewNop().Sugar(), tt.subnets)(ctx, nil, &grpc.UnaryServerInfo{},
+				func(context.Context, any) (any, error) {
+					return nil, nil
+				})
+			require.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/ktigay/metrics-collector/internal/proto"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
+)
+
+// TenantUnary определяет тенант по агенту или метаданным x-tenant и помещает его в контекст.
+// Должен идти после [AuthUnary].
+func TenantUnary(logger *zap.SugaredLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := resolveTenant(ctx, logger)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TenantStream определяет тенант потока, см. [TenantUnary].
+func TenantStream(logger *zap.SugaredLogger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := resolveTenant(ss.Context(), logger)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func resolveTenant(ctx context.Context, logger *zap.SugaredLogger) (context.Context, error) {
+	t, err := tenant.Resolve(ctx, metadataValue(ctx, pb.TenantMetadataKey))
+	switch {
+	case errors.Is(err, tenant.ErrInvalid):
+		logger.Warnf("Tenant: %v", err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, tenant.ErrMismatch):
+		logger.Warnf("Tenant: %v", err)
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return tenant.WithTenant(ctx, t), nil
+}
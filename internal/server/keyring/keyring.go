@@ -0,0 +1,79 @@
+// Package keyring Набор активных ключей подписи запросов по их идентификаторам.
+package keyring
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultID идентификатор ключа для запросов без хедера идентификатора ключа.
+const DefaultID = ""
+
+// Keyring ключи подписи по идентификаторам.
+type Keyring map[string]string
+
+// Parse разбирает список ключей вида id1:key1,id2:key2.
+func Parse(s string) (Keyring, error) {
+	k := Keyring{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		id, key, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || key == "" {
+			return nil, fmt.Errorf("invalid key %q, want id:key", pair)
+		}
+		if err := k.Add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// LoadFile загружает ключи из файла: по одному "id key" на строку, # - комментарий.
+func LoadFile(path string) (Keyring, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	k := Keyring{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want \"id key\"", path, n)
+		}
+		if err = k.Add(fields[0], fields[1]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Add добавляет ключ. Повторный идентификатор - ошибка.
+func (k Keyring) Add(id, key string) error {
+	if _, ok := k[id]; ok {
+		return fmt.Errorf("duplicate key id %q", id)
+	}
+	k[id] = key
+	return nil
+}
+
+// Key ключ по идентификатору.
+func (k Keyring) Key(id string) (string, bool) {
+	key, ok := k[id]
+	return key, ok
+}
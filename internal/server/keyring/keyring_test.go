@@ -0,0 +1,60 @@
+package keyring
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Keyring
+		wantErr bool
+	}{
+		{name: "Positive_test_empty", s: "", want: Keyring{}},
+		{name: "Positive_test_keys", s: "v1:old, v2:new", want: Keyring{"v1": "old", "v2": "new"}},
+		{name: "Positive_test_colon_in_key", s: "v1:a:b", want: Keyring{"v1": "a:b"}},
+		{name: "Negative_test_no_id", s: "secret", wantErr: true},
+		{name: "Negative_test_empty_key", s: "v1:", wantErr: true},
+		{name: "Negative_test_duplicate", s: "v1:a,v1:b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.s)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "keys")
+	require.NoError(t, os.WriteFile(path, []byte("# rotated 2026-10\nv1 old\n\n  v2   new  \n"), 0o600))
+	got, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, Keyring{"v1": "old", "v2": "new"}, got)
+
+	key, ok := got.Key("v2")
+	require.True(t, ok)
+	require.Equal(t, "new", key)
+	_, ok = got.Key(DefaultID)
+	require.False(t, ok)
+
+	bad := filepath.Join(dir, "bad")
+	require.NoError(t, os.WriteFile(bad, []byte("v1 old extra\n"), 0o600))
+	_, err = LoadFile(bad)
+	require.ErrorContains(t, err, "bad:1")
+
+	_, err = LoadFile(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
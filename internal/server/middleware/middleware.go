@@ -3,10 +3,15 @@ package middleware
 
 import (
 	"bytes"
-	"crypto/sha256"
+	"crypto/hmac"
+	"crypto/rsa"
 	"encoding/hex"
+	"errors"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,10 +19,20 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/ktigay/metrics-collector/internal/compress"
+	"github.com/ktigay/metrics-collector/internal/encryption"
 	serverhttp "github.com/ktigay/metrics-collector/internal/http"
+	"github.com/ktigay/metrics-collector/internal/server/auth"
+	"github.com/ktigay/metrics-collector/internal/server/keyring"
+	"github.com/ktigay/metrics-collector/internal/server/ratelimit"
+	"github.com/ktigay/metrics-collector/internal/server/replay"
+	"github.com/ktigay/metrics-collector/internal/server/subnet"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
 )
 
-var acceptTypes = []string{"text/html", "application/json", "*/*"}
+var acceptTypes = []string{"text/html", "text/plain", "application/json", "*/*"}
+
+// redactedHeaders хедеры с секретами, значения которых не пишутся в лог.
+var redactedHeaders = []string{"Authorization", serverhttp.HashSHA256Header}
 
 // WithBufferedWriter буферизованный Writer.
 func WithBufferedWriter(hashKey string) mux.MiddlewareFunc {
@@ -63,7 +78,10 @@ func WithLogging(logger *zap.SugaredLogger) mux.MiddlewareFunc {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 
-			b, _ := io.ReadAll(r.Body)
+			b, ok := readBody(w, r, logger)
+			if !ok {
+				return
+			}
 			r.Body = io.NopCloser(bytes.NewBuffer(b))
 
 			logger.Infow(
@@ -71,7 +89,7 @@ func WithLogging(logger *zap.SugaredLogger) mux.MiddlewareFunc {
 				"requestURI", r.RequestURI,
 				"method", r.Method,
 				"body", string(b),
-				"headers", r.Header,
+				"headers", redactHeaders(r.Header),
 			)
 
 			next.ServeHTTP(w, r)
@@ -94,10 +112,64 @@ func WithLogging(logger *zap.SugaredLogger) mux.MiddlewareFunc {
 	}
 }
 
+// redactHeaders копия хедеров для лога со скрытыми значениями [redactedHeaders].
+func redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range redactedHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "[REDACTED]")
+		}
+	}
+	return redacted
+}
+
+// DecryptHandler расшифровывает тело запроса, помеченного хедером [serverhttp.EncryptionHeader].
+// Должен выполняться до CompressHandler: шифруется уже сжатое тело.
+// maxBody - максимальный размер шифротекста в байтах, 0 - без ограничений.
+func DecryptHandler(logger *zap.SugaredLogger, privateKey *rsa.PrivateKey, maxBody int64) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			alg := r.Header.Get(serverhttp.EncryptionHeader)
+			if alg == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if alg != serverhttp.EncryptionRSAAESGCM || privateKey == nil {
+				logger.Warnf("DecryptHandler: unsupported encryption %s", alg)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			limitBody(w, r, maxBody)
+			buff, ok := readBody(w, r, logger)
+			if !ok {
+				return
+			}
+
+			buff, err := encryption.Decrypt(privateKey, buff)
+			if err != nil {
+				logger.Warnf("DecryptHandler: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewBuffer(buff))
+			r.ContentLength = int64(len(buff))
+			r.Header.Del(serverhttp.EncryptionHeader)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // CompressHandler обработчик сжатия данных.
-func CompressHandler(logger *zap.SugaredLogger) mux.MiddlewareFunc {
+// maxBody и maxDecompressed - максимальные размеры тела запроса до и после распаковки в байтах,
+// 0 - без ограничений. Превышение обнаруживается при чтении тела и дает ответ 413.
+func CompressHandler(logger *zap.SugaredLogger, maxBody, maxDecompressed int64) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limitBody(w, r, maxBody)
+
 			contentEncoding := r.Header.Get("Content-Encoding")
 			if ceAlg := compress.TypeFromString(contentEncoding); ceAlg != "" {
 				cr, err := compress.ReaderFactory(ceAlg, r.Body)
@@ -106,6 +178,7 @@ func CompressHandler(logger *zap.SugaredLogger) mux.MiddlewareFunc {
 					return
 				}
 				r.Body = cr
+				limitBody(w, r, maxDecompressed)
 			}
 
 			acceptEncoding := r.Header.Get("Accept-Encoding")
@@ -160,8 +233,10 @@ func CompressHandler(logger *zap.SugaredLogger) mux.MiddlewareFunc {
 	}
 }
 
-// CheckSumRequestHandler проверка checksum.
-func CheckSumRequestHandler(logger *zap.SugaredLogger, hashKey string) mux.MiddlewareFunc {
+// CheckSumRequestHandler проверка HMAC-подписи запроса ключом из keys по хедеру [serverhttp.KeyIDHeader].
+// Ответ подписывается тем же ключом. Запись метрик агентом (/update/, /updates/) без подписи отклоняется:
+// иначе перехваченное тело можно повторять, убрав хедеры подписи. Пустой keys - проверка отключена.
+func CheckSumRequestHandler(logger *zap.SugaredLogger, keys keyring.Keyring) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var (
@@ -170,13 +245,26 @@ func CheckSumRequestHandler(logger *zap.SugaredLogger, hashKey string) mux.Middl
 				buff     []byte
 				chBytes  []byte
 			)
-			if checkSum = r.Header.Get(serverhttp.HashSHA256Header); checkSum == "" {
+			checkSum = r.Header.Get(serverhttp.HashSHA256Header)
+			if len(keys) == 0 || (checkSum == "" && !isUpdatePath(r.URL.Path)) {
 				next.ServeHTTP(w, r)
 				return
 			}
+			if checkSum == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				logger.Warnf("CheckSumRequestHandler: missing checksum for %s", r.URL.Path)
+				return
+			}
 
-			if buff, err = io.ReadAll(r.Body); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+			keyID := r.Header.Get(serverhttp.KeyIDHeader)
+			hashKey, ok := keys.Key(keyID)
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				logger.Warnf("CheckSumRequestHandler: unknown key id %s", keyID)
+				return
+			}
+
+			if buff, ok = readBody(w, r, logger); !ok {
 				return
 			}
 
@@ -187,14 +275,220 @@ func CheckSumRequestHandler(logger *zap.SugaredLogger, hashKey string) mux.Middl
 				return
 			}
 
-			b := sha256.Sum256(append(buff, hashKey...))
-			if !bytes.Equal(b[:], chBytes) {
+			b := serverhttp.RequestCheckSum(
+				buff,
+				r.Header.Get(serverhttp.TimestampHeader),
+				r.Header.Get(serverhttp.NonceHeader),
+				hashKey,
+			)
+			if !hmac.Equal(b, chBytes) {
 				w.WriteHeader(http.StatusBadRequest)
 				logger.Warnf("CheckSumRequestHandler: invalid checksum %s", checkSum)
 				return
 			}
 
+			if sw, ok := w.(*serverhttp.Writer); ok {
+				sw.SetHashKey(keyID, hashKey)
+			}
+
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// ReplayHandler отклоняет повторно отправленные подписанные запросы и подписанные запросы без timestamp или nonce.
+// Должен выполняться после CheckSumRequestHandler: timestamp и nonce входят в подпись,
+// а неподписанная запись метрик отклоняется там.
+// guard == nil - проверка отключена.
+func ReplayHandler(logger *zap.SugaredLogger, guard *replay.Guard) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if guard == nil || r.Header.Get(serverhttp.HashSHA256Header) == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := guard.Check(
+				r.Header.Get(serverhttp.TimestampHeader),
+				r.Header.Get(serverhttp.NonceHeader),
+			); err != nil {
+				logger.Warnf("ReplayHandler: %v", err)
+				if errors.Is(err, replay.ErrFull) {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TrustedSubnetHandler отклоняет запросы на запись метрик (все эндпоинты приема) с адресов вне доверенных подсетей.
+// IP берется из хедера [serverhttp.RealIPHeader], при его отсутствии - из адреса соединения.
+// Пустой список подсетей - проверка отключена.
+func TrustedSubnetHandler(logger *zap.SugaredLogger, subnets []*net.IPNet) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(subnets) == 0 || !isWritePath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := realIP(r)
+			if subnet.Contains(subnets, ip) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger.Warnf("TrustedSubnetHandler: untrusted ip %v", ip)
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}
+}
+
+func isUpdatePath(path string) bool {
+	return strings.HasPrefix(path, "/update/") || path == "/updates/"
+}
+
+func isWritePath(path string) bool {
+	return isUpdatePath(path) || path == "/write" || path == "/api/v1/write"
+}
+
+// AuthHandler аутентифицирует агента по токену из хедера Authorization: Bearer и помещает его в контекст запроса.
+// Эндпоинты записи требуют токен всегда. Чтение без токена разрешено только из тенанта по умолчанию:
+// хедер X-Tenant без токена отклоняется, иначе любой клиент читал бы метрики чужого тенанта.
+// store == nil - аутентификация отключена.
+func AuthHandler(logger *zap.SugaredLogger, store auth.Store) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if store == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := auth.TokenFromHeader(r.Header.Get("Authorization"))
+			if token == "" && !isWritePath(r.URL.Path) && r.Header.Get(serverhttp.TenantHeader) == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var (
+				agent *auth.Agent
+				err   error
+			)
+			if token != "" {
+				if agent, err = store.Lookup(r.Context(), token); err != nil {
+					logger.Errorf("AuthHandler: %v", err)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+			}
+			if agent == nil {
+				logger.Warnf("AuthHandler: unauthorized request %s", r.URL.Path)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithAgent(r.Context(), agent)))
+		})
+	}
+}
+
+// TenantHandler определяет тенант запроса по агенту или хедеру X-Tenant и помещает его в контекст.
+// Должен идти после [AuthHandler].
+func TenantHandler(logger *zap.SugaredLogger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t, err := tenant.Resolve(r.Context(), r.Header.Get(serverhttp.TenantHeader))
+			switch {
+			case errors.Is(err, tenant.ErrInvalid):
+				logger.Warnf("TenantHandler: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			case errors.Is(err, tenant.ErrMismatch):
+				logger.Warnf("TenantHandler: %v", err)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), t)))
+		})
+	}
+}
+
+// RateLimitHandler ограничивает частоту запросов агента, а без аутентификации - IP клиента.
+// IP берется из адреса соединения, хедер [serverhttp.RealIPHeader] учитывается только
+// от прокси из подсетей proxies. Должен идти после [AuthHandler]. limiter == nil - ограничение отключено.
+func RateLimitHandler(logger *zap.SugaredLogger, limiter *ratelimit.Limiter, proxies []*net.IPNet) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := "ip:" + clientIP(r, proxies).String()
+			if a, ok := auth.AgentFromContext(r.Context()); ok {
+				key = "agent:" + a.Name
+			}
+			if ok, retry := limiter.Allow(key); !ok {
+				logger.Warnf("RateLimitHandler: too many requests from %s", key)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitBody ограничивает размер тела запроса max байтами, 0 - без ограничений.
+func limitBody(w http.ResponseWriter, r *http.Request, max int64) {
+	if max > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+	}
+}
+
+// readBody читает тело запроса. При ошибке отвечает 413, если превышен лимит размера, иначе 500.
+func readBody(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger) ([]byte, bool) {
+	b, err := io.ReadAll(r.Body)
+	if err == nil {
+		return b, true
+	}
+
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		logger.Warnf("request body exceeds %d bytes", mbe.Limit)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	logger.Errorf("can't read request body: %v", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	return nil, false
+}
+
+func realIP(r *http.Request) net.IP {
+	if h := r.Header.Get(serverhttp.RealIPHeader); h != "" {
+		return net.ParseIP(strings.TrimSpace(h))
+	}
+	return subnet.HostIP(r.RemoteAddr)
+}
+
+// clientIP адрес клиента: адрес соединения, а для запросов через прокси из proxies - хедер [serverhttp.RealIPHeader].
+func clientIP(r *http.Request, proxies []*net.IPNet) net.IP {
+	ip := subnet.HostIP(r.RemoteAddr)
+	if !subnet.Contains(proxies, ip) {
+		return ip
+	}
+	if h := r.Header.Get(serverhttp.RealIPHeader); h != "" {
+		if forwarded := net.ParseIP(strings.TrimSpace(h)); forwarded != nil {
+			return forwarded
+		}
+	}
+	return ip
+}
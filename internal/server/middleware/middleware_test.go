@@ -2,21 +2,42 @@ package middleware
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
+	"github.com/ktigay/metrics-collector/internal/compress"
 	h "github.com/ktigay/metrics-collector/internal/http"
+	"github.com/ktigay/metrics-collector/internal/server/auth"
+	authmocks "github.com/ktigay/metrics-collector/internal/server/auth/mocks"
+	"github.com/ktigay/metrics-collector/internal/server/keyring"
+	"github.com/ktigay/metrics-collector/internal/server/ratelimit"
+	"github.com/ktigay/metrics-collector/internal/server/replay"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
 )
 
 func TestCheckSumRequestHandler(t *testing.T) {
+	keys := keyring.Keyring{keyring.DefaultID: "sha256", "v2": "rotated"}
+
 	type args struct {
-		hashKey  string
+		keys     keyring.Keyring
+		path     string
+		keyID    string
 		checksum string
 		body     []byte
 	}
@@ -24,30 +45,71 @@ func TestCheckSumRequestHandler(t *testing.T) {
 		name       string
 		args       args
 		wantStatus int
+		wantKeyID  string
 	}{
 		{
 			name: "Positive_test_checksum",
 			args: args{
-				hashKey:  "sha256",
-				checksum: "20a8636b989d82f2f6b0bc108f5ccd1b22d44f9f8f3281e0b1ad070aedf24aba",
+				keys:     keys,
+				checksum: "160732adaedb0a568b17ddfabdc453ea280dc12673af417ac281d0fc83268d65",
+				body:     []byte("hello world"),
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Positive_test_checksum_key_id",
+			args: args{
+				keys:     keys,
+				keyID:    "v2",
+				checksum: "63457342c90314336654f108851e64702714c70561173740c158ad057ec4f774",
 				body:     []byte("hello world"),
 			},
 			wantStatus: http.StatusOK,
+			wantKeyID:  "v2",
 		},
 		{
 			name: "Positive_test_no_checksum",
 			args: args{
-				hashKey:  "sha2563dd322",
+				keys:     keyring.Keyring{keyring.DefaultID: "sha2563dd322"},
 				checksum: "",
 				body:     []byte("hello world"),
 			},
 			wantStatus: http.StatusOK,
 		},
+		{
+			name: "Negative_test_no_checksum_update",
+			args: args{
+				keys: keys,
+				path: "/updates/",
+				body: []byte("hello world"),
+			},
+			wantStatus: http.StatusBadRequest,
+		},
 		{
 			name: "Negative_test_wrong_checksum",
 			args: args{
-				hashKey:  "sha2563322",
-				checksum: "20a8636b989d82f2f6b0bc108f5ccd1b22d44f9f8f3281e0b1ad070aedf24aba",
+				keys:     keyring.Keyring{keyring.DefaultID: "sha2563322"},
+				checksum: "160732adaedb0a568b17ddfabdc453ea280dc12673af417ac281d0fc83268d65",
+				body:     []byte("hello world"),
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Negative_test_wrong_key_id",
+			args: args{
+				keys:     keys,
+				keyID:    "v2",
+				checksum: "160732adaedb0a568b17ddfabdc453ea280dc12673af417ac281d0fc83268d65",
+				body:     []byte("hello world"),
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Negative_test_unknown_key_id",
+			args: args{
+				keys:     keys,
+				keyID:    "v3",
+				checksum: "160732adaedb0a568b17ddfabdc453ea280dc12673af417ac281d0fc83268d65",
 				body:     []byte("hello world"),
 			},
 			wantStatus: http.StatusBadRequest,
@@ -55,7 +117,7 @@ func TestCheckSumRequestHandler(t *testing.T) {
 		{
 			name: "Negative_test_invalid_byte_error",
 			args: args{
-				hashKey:  "sha256",
+				keys:     keys,
 				checksum: "r0a8636b222d8doi76b0bc108f5ccd1b22d44f9glhu771e0b1ad070aedf24dda",
 				body:     []byte("hello world"),
 			},
@@ -68,15 +130,23 @@ func TestCheckSumRequestHandler(t *testing.T) {
 			defer mockCtrl.Finish()
 
 			router := mux.NewRouter()
-			router.Use(CheckSumRequestHandler(zap.NewNop().Sugar(), tt.args.hashKey))
-			router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
-				writer.WriteHeader(http.StatusOK)
+			router.Use(
+				WithBufferedWriter(tt.args.keys[keyring.DefaultID]),
+				CheckSumRequestHandler(zap.NewNop().Sugar(), tt.args.keys),
+				FlushBufferedWriter,
+			)
+			router.PathPrefix("/").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				_, _ = writer.Write([]byte("ok"))
 			})
 
 			srv := httptest.NewServer(router)
 			defer srv.Close()
 
-			req, err := http.NewRequest(http.MethodPost, srv.URL+"/", bytes.NewReader(tt.args.body))
+			path := tt.args.path
+			if path == "" {
+				path = "/"
+			}
+			req, err := http.NewRequest(http.MethodPost, srv.URL+path, bytes.NewReader(tt.args.body))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -85,7 +155,621 @@ func TestCheckSumRequestHandler(t *testing.T) {
 			if tt.args.checksum != "" {
 				req.Header[h.HashSHA256Header] = []string{tt.args.checksum}
 			}
+			if tt.args.keyID != "" {
+				req.Header.Set(h.KeyIDHeader, tt.args.keyID)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus == http.StatusOK {
+				key := tt.args.keys[tt.args.keyID]
+				assert.Equal(t, fmt.Sprintf("%x", h.ResponseCheckSum([]byte("ok"), key)), resp.Header.Get(h.HashSHA256Header))
+				assert.Equal(t, tt.wantKeyID, resp.Header.Get(h.KeyIDHeader))
+			}
+		})
+	}
+}
+
+func TestDecryptHandler(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]string{"id": "Alloc"}
+
+	tests := []struct {
+		name       string
+		opts       []compress.Option
+		serverKey  *rsa.PrivateKey
+		wantStatus int
+	}{
+		{
+			name:       "Positive_test_encrypted",
+			opts:       []compress.Option{compress.WithPublicKey(&priv.PublicKey), compress.WithHashKey("key")},
+			serverKey:  priv,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Positive_test_not_encrypted",
+			serverKey:  priv,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Negative_test_wrong_key",
+			opts:       []compress.Option{compress.WithPublicKey(&other.PublicKey)},
+			serverKey:  priv,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Negative_test_no_server_key",
+			opts:       []compress.Option{compress.WithPublicKey(&priv.PublicKey)},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zap.NewNop().Sugar()
+
+			router := mux.NewRouter()
+			router.Use(
+				DecryptHandler(logger, tt.serverKey, 0),
+				CompressHandler(logger, 0, 0),
+				CheckSumRequestHandler(logger, keyring.Keyring{keyring.DefaultID: "key"}),
+			)
+			router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+				b, _ := io.ReadAll(request.Body)
+				if !assert.JSONEq(t, `{"id":"Alloc"}`, string(b)) {
+					writer.WriteHeader(http.StatusUnprocessableEntity)
+				}
+			})
+
+			srv := httptest.NewServer(router)
+			defer srv.Close()
+
+			req, err := compress.NewJSONRequest(http.MethodPost, srv.URL+"/", compress.Gzip, body, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestTrustedSubnetHandler(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		subnets    []*net.IPNet
+		path       string
+		realIP     string
+		wantStatus int
+	}{
+		{
+			name:       "Positive_test_trusted_ip",
+			subnets:    []*net.IPNet{subnet},
+			path:       "/updates/",
+			realIP:     "10.1.2.3",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Positive_test_no_subnets",
+			path:       "/updates/",
+			realIP:     "192.168.1.1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Positive_test_not_update_path",
+			subnets:    []*net.IPNet{subnet},
+			path:       "/value/",
+			realIP:     "192.168.1.1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Negative_test_untrusted_ip",
+			subnets:    []*net.IPNet{subnet},
+			path:       "/update/gauge/Alloc/1",
+			realIP:     "192.168.1.1",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Negative_test_untrusted_ip_remote_write",
+			subnets:    []*net.IPNet{subnet},
+			path:       "/api/v1/write",
+			realIP:     "192.168.1.1",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Negative_test_untrusted_ip_influx",
+			subnets:    []*net.IPNet{subnet},
+			path:       "/write",
+			realIP:     "192.168.1.1",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Negative_test_remote_addr_fallback",
+			subnets:    []*net.IPNet{subnet},
+			path:       "/update/",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Negative_test_invalid_ip",
+			subnets:    []*net.IPNet{subnet},
+			path:       "/updates/",
+			realIP:     "not-an-ip",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Use(TrustedSubnetHandler(zap.NewNop().Sugar(), tt.subnets))
+			router.PathPrefix("/").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusOK)
+			})
+
+			srv := httptest.NewServer(router)
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.realIP != "" {
+				req.Header.Set(h.RealIPHeader, tt.realIP)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestReplayHandler(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+
+	router := mux.NewRouter()
+	router.Use(
+		CompressHandler(logger, 0, 0),
+		CheckSumRequestHandler(logger, keyring.Keyring{keyring.DefaultID: "key"}),
+		ReplayHandler(logger, replay.NewGuard(time.Minute, 10)),
+	)
+	router.HandleFunc("/updates/", func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	})
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	send := func(req *http.Request) int {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+	newRequest := func() *http.Request {
+		req, err := compress.NewJSONRequest(http.MethodPost, srv.URL+"/updates/", compress.Gzip, map[string]int{"delta": 1}, compress.WithHashKey("key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	req := newRequest()
+	replayed := req.Clone(context.Background())
+	replayed.Body, _ = req.GetBody()
+	assert.Equal(t, http.StatusOK, send(req))
+	assert.Equal(t, http.StatusBadRequest, send(replayed))
+
+	// повтор с удаленными хедерами подписи.
+	stripped := req.Clone(context.Background())
+	stripped.Body, _ = req.GetBody()
+	for _, name := range []string{h.HashSHA256Header, h.TimestampHeader, h.NonceHeader, h.KeyIDHeader} {
+		delete(stripped.Header, name)
+	}
+	assert.Equal(t, http.StatusBadRequest, send(stripped))
+
+	// подпись без timestamp и nonce.
+	body := []byte(`{"delta":1}`)
+	unsalted, err := http.NewRequest(http.MethodPost, srv.URL+"/updates/", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsalted.Header.Set(h.HashSHA256Header, fmt.Sprintf("%x", h.RequestCheckSum(body, "", "", "key")))
+	assert.Equal(t, http.StatusBadRequest, send(unsalted))
+
+	tampered := newRequest()
+	tampered.Header.Set(h.TimestampHeader, strconv.FormatInt(time.Now().Unix()+1, 10))
+	assert.Equal(t, http.StatusBadRequest, send(tampered))
+
+	assert.Equal(t, http.StatusOK, send(newRequest()))
+}
+
+func TestReplayHandler_Full(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(ReplayHandler(zap.NewNop().Sugar(), replay.NewGuard(time.Minute, 1)))
+	router.HandleFunc("/updates/", func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	})
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	send := func(nonce string) int {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.Header.Set(h.HashSHA256Header, "sum")
+		req.Header.Set(h.TimestampHeader, ts)
+		req.Header.Set(h.NonceHeader, nonce)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("a"))
+	assert.Equal(t, http.StatusServiceUnavailable, send("b"))
+	assert.Equal(t, http.StatusBadRequest, send("a"))
+}
+
+func TestAuthHandler(t *testing.T) {
+	agent := &auth.Agent{Name: "web-1", Prefixes: []string{"app_"}}
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		tenant     string
+		store      func(mockCtrl *gomock.Controller) auth.Store
+		wantStatus int
+		wantAgent  string
+	}{
+		{
+			name:  "Positive_test_valid_token",
+			path:  "/updates/",
+			token: "t1",
+			store: func(mockCtrl *gomock.Controller) auth.Store {
+				st := authmocks.NewMockStore(mockCtrl)
+				st.EXPECT().Lookup(gomock.Any(), "t1").Return(agent, nil).Times(1)
+				return st
+			},
+			wantStatus: http.StatusOK,
+			wantAgent:  "web-1",
+		},
+		{
+			name: "Positive_test_read_path",
+			path: "/value/",
+			store: func(mockCtrl *gomock.Controller) auth.Store {
+				return authmocks.NewMockStore(mockCtrl)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Positive_test_read_path_with_token",
+			path:  "/value/",
+			token: "t1",
+			store: func(mockCtrl *gomock.Controller) auth.Store {
+				st := authmocks.NewMockStore(mockCtrl)
+				st.EXPECT().Lookup(gomock.Any(), "t1").Return(agent, nil).Times(1)
+				return st
+			},
+			wantStatus: http.StatusOK,
+			wantAgent:  "web-1",
+		},
+		{
+			name:   "Negative_test_read_path_tenant_without_token",
+			path:   "/value/",
+			tenant: "team-a",
+			store: func(mockCtrl *gomock.Controller) auth.Store {
+				return authmocks.NewMockStore(mockCtrl)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Positive_test_disabled",
+			path: "/updates/",
+			store: func(*gomock.Controller) auth.Store {
+				return nil
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Negative_test_no_token",
+			path: "/write",
+			store: func(mockCtrl *gomock.Controller) auth.Store {
+				return authmocks.NewMockStore(mockCtrl)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:  "Negative_test_unknown_token",
+			path:  "/update/gauge/app_x/1",
+			token: "t2",
+			store: func(mockCtrl *gomock.Controller) auth.Store {
+				st := authmocks.NewMockStore(mockCtrl)
+				st.EXPECT().Lookup(gomock.Any(), "t2").Return(nil, nil).Times(1)
+				return st
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			var gotAgent string
+			router := mux.NewRouter()
+			router.Use(AuthHandler(zap.NewNop().Sugar(), tt.store(mockCtrl)))
+			router.PathPrefix("/").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				if a, ok := auth.AgentFromContext(request.Context()); ok {
+					gotAgent = a.Name
+				}
+				writer.WriteHeader(http.StatusOK)
+			})
+
+			srv := httptest.NewServer(router)
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.tenant != "" {
+				req.Header.Set(h.TenantHeader, tt.tenant)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantAgent, gotAgent)
+		})
+	}
+}
+
+func TestTenantHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		agent      *auth.Agent
+		header     string
+		wantStatus int
+		wantTenant string
+	}{
+		{
+			name:       "Positive_test_default",
+			wantStatus: http.StatusOK,
+			wantTenant: tenant.Default,
+		},
+		{
+			name:       "Positive_test_header",
+			header:     "team-a",
+			wantStatus: http.StatusOK,
+			wantTenant: "team-a",
+		},
+		{
+			name:       "Positive_test_agent",
+			agent:      &auth.Agent{Name: "web-1", Tenant: "team-b"},
+			wantStatus: http.StatusOK,
+			wantTenant: "team-b",
+		},
+		{
+			name:       "Negative_test_invalid",
+			header:     "team a",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Negative_test_mismatch",
+			agent:      &auth.Agent{Name: "web-1", Tenant: "team-b"},
+			header:     "team-a",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTenant string
+			handler := TenantHandler(zap.NewNop().Sugar())(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				gotTenant = tenant.FromContext(request.Context())
+				writer.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil)
+			if tt.agent != nil {
+				req = req.WithContext(auth.WithAgent(req.Context(), tt.agent))
+			}
+			if tt.header != "" {
+				req.Header.Set(h.TenantHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantTenant, gotTenant)
+		})
+	}
+}
 
+func TestAuthHandler_CrossTenantRead(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	st := authmocks.NewMockStore(mockCtrl)
+	st.EXPECT().Lookup(gomock.Any(), "team-b-token").Return(&auth.Agent{Name: "web-1", Tenant: "team-b"}, nil).AnyTimes()
+
+	logger := zap.NewNop().Sugar()
+	router := mux.NewRouter()
+	router.Use(AuthHandler(logger, st), TenantHandler(logger))
+	router.PathPrefix("/").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte(tenant.FromContext(request.Context())))
+	})
+
+	tests := []struct {
+		name       string
+		token      string
+		tenant     string
+		wantStatus int
+		wantTenant string
+	}{
+		{
+			name:       "Positive_test_own_tenant",
+			token:      "team-b-token",
+			wantStatus: http.StatusOK,
+			wantTenant: "team-b",
+		},
+		{
+			name:       "Positive_test_anonymous_default_tenant",
+			wantStatus: http.StatusOK,
+			wantTenant: tenant.Default,
+		},
+		{
+			name:       "Negative_test_anonymous_foreign_tenant",
+			tenant:     "team-a",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Negative_test_agent_foreign_tenant",
+			token:      "team-b-token",
+			tenant:     "team-a",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.tenant != "" {
+				req.Header.Set(h.TenantHeader, tt.tenant)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantTenant, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	// httptest.NewRequest отправляет запросы с адреса 192.0.2.1.
+	_, proxy, err := net.ParseCIDR("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := RateLimitHandler(logger, ratelimit.NewLimiter(1, 2), []*net.IPNet{proxy})(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(realIP string, agent *auth.Agent) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.Header.Set(h.RealIPHeader, realIP)
+		if agent != nil {
+			req = req.WithContext(auth.WithAgent(req.Context(), agent))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, send("10.0.0.1", nil).Code)
+	assert.Equal(t, http.StatusOK, send("10.0.0.1", nil).Code)
+	rec := send("10.0.0.1", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	// другой IP и агент ограничиваются отдельно, агент - независимо от IP.
+	assert.Equal(t, http.StatusOK, send("10.0.0.2", nil).Code)
+	agent := &auth.Agent{Name: "web-1"}
+	assert.Equal(t, http.StatusOK, send("10.0.0.1", agent).Code)
+	assert.Equal(t, http.StatusOK, send("10.0.0.3", agent).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.4", agent).Code)
+}
+
+func TestRateLimitHandler_UntrustedProxy(t *testing.T) {
+	handler := RateLimitHandler(zap.NewNop().Sugar(), ratelimit.NewLimiter(1, 2), nil)(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(realIP string) int {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.Header.Set(h.RealIPHeader, realIP)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// без доверенного прокси подмена X-Real-IP не дает нового лимита: ключ - адрес соединения.
+	assert.Equal(t, http.StatusOK, send("10.0.0.1"))
+	assert.Equal(t, http.StatusOK, send("10.0.0.2"))
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.3"))
+}
+
+func TestCompressHandler_Limits(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	payload := map[string]string{"id": strings.Repeat("a", 4096)}
+
+	tests := []struct {
+		name            string
+		maxBody         int64
+		maxDecompressed int64
+		wantStatus      int
+	}{
+		{name: "Positive_test_unlimited", wantStatus: http.StatusOK},
+		{name: "Positive_test_within_limits", maxBody: 1024, maxDecompressed: 8192, wantStatus: http.StatusOK},
+		{name: "Negative_test_compressed_too_large", maxBody: 16, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "Negative_test_decompression_bomb", maxBody: 1024, maxDecompressed: 1024, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Use(
+				CompressHandler(logger, tt.maxBody, tt.maxDecompressed),
+				WithLogging(logger),
+			)
+			router.HandleFunc("/", func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(http.StatusOK)
+			})
+
+			srv := httptest.NewServer(router)
+			defer srv.Close()
+
+			req, err := compress.NewJSONRequest(http.MethodPost, srv.URL+"/", compress.Gzip, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
 			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
 				t.Fatal(err)
@@ -96,3 +780,30 @@ func TestCheckSumRequestHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestWithLogging_RedactsSecrets(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := mux.NewRouter()
+	router.Use(WithLogging(zap.New(core).Sugar()))
+	router.HandleFunc("/updates/", func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set(h.HashSHA256Header, "secret-sum")
+	req.Header.Set(h.NonceHeader, "nonce")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterMessage("request").All()
+	if !assert.Len(t, entries, 1) {
+		return
+	}
+	logged := fmt.Sprint(entries[0].ContextMap()["headers"])
+	assert.NotContains(t, logged, "secret-token")
+	assert.NotContains(t, logged, "secret-sum")
+	assert.Contains(t, logged, "nonce")
+	// хедеры запроса не изменились.
+	assert.Equal(t, "Bearer secret-token", req.Header.Get("Authorization"))
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter(2, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		require.True(t, ok)
+	}
+	ok, retry := l.Allow("a")
+	require.False(t, ok)
+	require.Equal(t, 500*time.Millisecond, retry)
+
+	// у другого клиента свое ведро.
+	ok, _ = l.Allow("b")
+	require.True(t, ok)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = l.Allow("a")
+	require.True(t, ok)
+	ok, _ = l.Allow("a")
+	require.False(t, ok)
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter(1, 2)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.Allow("b")
+	require.Equal(t, 2, l.Len())
+
+	now = now.Add(time.Second)
+	l.Allow("b")
+	require.Equal(t, 2, l.Len())
+
+	now = now.Add(2 * time.Second)
+	l.Allow("c")
+	require.Equal(t, 1, l.Len())
+}
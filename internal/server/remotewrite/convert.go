@@ -0,0 +1,131 @@
+package remotewrite
+
+import (
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+const nameLabel = "__name__"
+
+// Converter преобразует ряды remote_write в метрики.
+// Счетчики Prometheus накопительные, а сервер хранит приращения, поэтому
+// Converter запоминает последнее значение каждого счетчика тенанта.
+// Первое значение неизвестного ряда (в т.ч. после перезапуска сервера) становится базой:
+// приращение за время до него не учитывается, чтобы не задваивать уже сохраненную историю.
+// Безопасен для параллельного использования, но Convert и Commit одного тенанта
+// нужно выполнять последовательно.
+type Converter struct {
+	mx     sync.RWMutex
+	totals map[string]float64
+}
+
+// NewConverter конструктор.
+func NewConverter() *Converter {
+	return &Converter{
+		totals: make(map[string]float64),
+	}
+}
+
+// Convert преобразует запрос в метрики. Ряды с суффиксом _total становятся счетчиками, остальные - gauge.
+// Значения счетчиков запоминаются отдельно для каждого тенанта t.
+// Возвращает также новые значения счетчиков, которые нужно передать в Commit после сохранения метрик.
+func (c *Converter) Convert(t string, wr *WriteRequest) ([]metric.Metrics, map[string]float64) {
+	var (
+		mm     = make([]metric.Metrics, 0, len(wr.Timeseries))
+		totals = make(map[string]float64)
+	)
+
+	for _, ts := range wr.Timeseries {
+		name, labels := splitLabels(ts.Labels)
+		if name == "" {
+			continue
+		}
+
+		samples := slices.DeleteFunc(slices.Clone(ts.Samples), func(s Sample) bool {
+			// NaN используется Prometheus как маркер устаревшего ряда.
+			return math.IsNaN(s.Value)
+		})
+		if len(samples) == 0 {
+			continue
+		}
+		slices.SortStableFunc(samples, func(a, b Sample) int {
+			return cmp.Compare(a.Timestamp, b.Timestamp)
+		})
+
+		if !IsCounterName(name) {
+			v := samples[len(samples)-1].Value
+			mm = append(mm, metric.Metrics{ID: name, Type: string(metric.TypeGauge), Value: &v, Labels: labels})
+			continue
+		}
+
+		// одноименные ряды разных тенантов - разные счетчики.
+		key := t + "/" + metric.Key(string(metric.TypeCounter), name, labels)
+		prev, ok := totals[key]
+		if !ok {
+			prev, ok = c.total(key)
+		}
+		if !ok {
+			// неизвестный ряд: первое значение - база.
+			prev = samples[0].Value
+		}
+
+		var delta int64
+		for _, s := range samples {
+			if s.Value < prev {
+				// сброс счетчика.
+				delta += int64(s.Value)
+			} else {
+				delta += int64(s.Value) - int64(prev)
+			}
+			prev = s.Value
+		}
+		totals[key] = prev
+
+		mm = append(mm, metric.Metrics{ID: name, Type: string(metric.TypeCounter), Delta: &delta, Labels: labels})
+	}
+
+	return mm, totals
+}
+
+// Commit запоминает значения счетчиков, полученные из Convert.
+func (c *Converter) Commit(totals map[string]float64) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	maps.Copy(c.totals, totals)
+}
+
+func (c *Converter) total(key string) (float64, bool) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	v, ok := c.totals[key]
+	return v, ok
+}
+
+// IsCounterName проверяет, является ли ряд счетчиком по имени.
+func IsCounterName(name string) bool {
+	return strings.HasSuffix(name, "_total")
+}
+
+func splitLabels(ll []Label) (string, metric.Labels) {
+	var (
+		name   string
+		labels metric.Labels
+	)
+	for _, l := range ll {
+		if l.Name == nameLabel {
+			name = l.Value
+			continue
+		}
+		if labels == nil {
+			labels = make(metric.Labels, len(ll))
+		}
+		labels[l.Name] = l.Value
+	}
+	return name, labels
+}
@@ -0,0 +1,179 @@
+// Package remotewrite Прием метрик по протоколу Prometheus remote_write.
+package remotewrite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Номера полей из prometheus/prompb (remote.proto, types.proto).
+const (
+	writeRequestTimeseries protowire.Number = 1
+
+	timeSeriesLabels  protowire.Number = 1
+	timeSeriesSamples protowire.Number = 2
+
+	labelName  protowire.Number = 1
+	labelValue protowire.Number = 2
+
+	sampleValue     protowire.Number = 1
+	sampleTimestamp protowire.Number = 2
+)
+
+// ErrMalformed некорректное protobuf-сообщение.
+var ErrMalformed = errors.New("malformed protobuf message")
+
+// WriteRequest запрос remote_write. Метаданные и exemplars не поддерживаются и пропускаются.
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+// TimeSeries временной ряд.
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// Label метка ряда.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample значение ряда. Timestamp в миллисекундах.
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// Unmarshal разбирает WriteRequest из protobuf.
+func Unmarshal(b []byte) (*WriteRequest, error) {
+	wr := &WriteRequest{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != writeRequestTimeseries || typ != protowire.BytesType {
+			return nil
+		}
+		ts, err := unmarshalTimeSeries(v)
+		if err != nil {
+			return err
+		}
+		wr.Timeseries = append(wr.Timeseries, ts)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// Marshal кодирует WriteRequest в protobuf.
+func (wr *WriteRequest) Marshal() []byte {
+	var b []byte
+	for _, ts := range wr.Timeseries {
+		var tb []byte
+		for _, l := range ts.Labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, labelName, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.Name)
+			lb = protowire.AppendTag(lb, labelValue, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.Value)
+
+			tb = protowire.AppendTag(tb, timeSeriesLabels, protowire.BytesType)
+			tb = protowire.AppendBytes(tb, lb)
+		}
+		for _, s := range ts.Samples {
+			var sb []byte
+			sb = protowire.AppendTag(sb, sampleValue, protowire.Fixed64Type)
+			sb = protowire.AppendFixed64(sb, math.Float64bits(s.Value))
+			sb = protowire.AppendTag(sb, sampleTimestamp, protowire.VarintType)
+			sb = protowire.AppendVarint(sb, uint64(s.Timestamp))
+
+			tb = protowire.AppendTag(tb, timeSeriesSamples, protowire.BytesType)
+			tb = protowire.AppendBytes(tb, sb)
+		}
+
+		b = protowire.AppendTag(b, writeRequestTimeseries, protowire.BytesType)
+		b = protowire.AppendBytes(b, tb)
+	}
+	return b
+}
+
+func unmarshalTimeSeries(b []byte) (TimeSeries, error) {
+	var ts TimeSeries
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case timeSeriesLabels:
+			var l Label
+			if err := walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if typ != protowire.BytesType {
+					return nil
+				}
+				switch num {
+				case labelName:
+					l.Name = string(v)
+				case labelValue:
+					l.Value = string(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			ts.Labels = append(ts.Labels, l)
+		case timeSeriesSamples:
+			var s Sample
+			if err := walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				switch {
+				case num == sampleValue && typ == protowire.Fixed64Type:
+					u, _ := protowire.ConsumeFixed64(v)
+					s.Value = math.Float64frombits(u)
+				case num == sampleTimestamp && typ == protowire.VarintType:
+					u, _ := protowire.ConsumeVarint(v)
+					s.Timestamp = int64(u)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return nil
+	})
+	return ts, err
+}
+
+// walk обходит поля сообщения. Для BytesType в fn передается содержимое поля,
+// для остальных типов - закодированное значение.
+func walk(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrMalformed, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var v []byte
+		if typ == protowire.BytesType {
+			v, n = protowire.ConsumeBytes(b)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				v = b[:n]
+			}
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrMalformed, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
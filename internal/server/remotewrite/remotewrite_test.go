@@ -0,0 +1,116 @@
+package remotewrite
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
+)
+
+func TestUnmarshal(t *testing.T) {
+	want := &WriteRequest{
+		Timeseries: []TimeSeries{
+			{
+				Labels:  []Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "node"}},
+				Samples: []Sample{{Value: 1, Timestamp: 1735689600000}},
+			},
+			{
+				Labels:  []Label{{Name: "__name__", Value: "http_requests_total"}},
+				Samples: []Sample{{Value: 10, Timestamp: 1}, {Value: 12.5, Timestamp: 2}},
+			},
+		},
+	}
+
+	got, err := Unmarshal(want.Marshal())
+	require.NoError(t, err)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unmarshal() mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err = Unmarshal([]byte{0x0a, 0x05, 0x01})
+	require.True(t, errors.Is(err, ErrMalformed))
+}
+
+func TestConverter_Convert(t *testing.T) {
+	c := NewConverter()
+
+	wr := &WriteRequest{
+		Timeseries: []TimeSeries{
+			{
+				Labels:  []Label{{Name: "__name__", Value: "temperature"}, {Name: "room", Value: "a"}},
+				Samples: []Sample{{Value: 21, Timestamp: 2}, {Value: 20, Timestamp: 1}},
+			},
+			{
+				Labels:  []Label{{Name: "__name__", Value: "requests_total"}},
+				Samples: []Sample{{Value: 10, Timestamp: 1}, {Value: 15, Timestamp: 2}},
+			},
+			{
+				Labels:  []Label{{Name: "__name__", Value: "stale"}},
+				Samples: []Sample{{Value: math.NaN(), Timestamp: 1}},
+			},
+			{
+				Labels:  []Label{{Name: "job", Value: "no_name"}},
+				Samples: []Sample{{Value: 1, Timestamp: 1}},
+			},
+		},
+	}
+
+	// первое значение неизвестного ряда - база, приращение 15 - 10.
+	gauge, delta := 21.0, int64(5)
+	mm, totals := c.Convert(tenant.Default, wr)
+	require.Equal(t, []metric.Metrics{
+		{ID: "temperature", Type: "gauge", Value: &gauge, Labels: metric.Labels{"room": "a"}},
+		{ID: "requests_total", Type: "counter", Delta: &delta},
+	}, mm)
+
+	// без Commit значения счетчиков не запоминаются.
+	mm, _ = c.Convert(tenant.Default, wr)
+	require.Equal(t, int64(5), *mm[1].Delta)
+
+	c.Commit(totals)
+
+	next := &WriteRequest{
+		Timeseries: []TimeSeries{
+			{
+				Labels:  []Label{{Name: "__name__", Value: "requests_total"}},
+				Samples: []Sample{{Value: 18, Timestamp: 3}, {Value: 4, Timestamp: 4}},
+			},
+		},
+	}
+	mm, _ = c.Convert(tenant.Default, next)
+	// 15 -> 18 дает 3, затем сброс счетчика до 4.
+	require.Equal(t, int64(7), *mm[0].Delta)
+}
+
+func TestConverter_Convert_Tenants(t *testing.T) {
+	c := NewConverter()
+	wr := func(v float64) *WriteRequest {
+		return &WriteRequest{
+			Timeseries: []TimeSeries{
+				{
+					Labels:  []Label{{Name: "__name__", Value: "requests_total"}},
+					Samples: []Sample{{Value: v, Timestamp: 1}},
+				},
+			},
+		}
+	}
+
+	mm, totals := c.Convert("team-a", wr(100))
+	require.Equal(t, int64(0), *mm[0].Delta)
+	c.Commit(totals)
+
+	// одноименный ряд другого тенанта не продолжает счетчик team-a.
+	mm, totals = c.Convert("team-b", wr(10))
+	require.Equal(t, int64(0), *mm[0].Delta)
+	c.Commit(totals)
+
+	mm, _ = c.Convert("team-a", wr(105))
+	require.Equal(t, int64(5), *mm[0].Delta)
+	mm, _ = c.Convert("team-b", wr(12))
+	require.Equal(t, int64(2), *mm[0].Delta)
+}
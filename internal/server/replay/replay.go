@@ -0,0 +1,118 @@
+// Package replay Защита подписанных запросов от повторной отправки.
+package replay
+
+import (
+	"container/list"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrMalformed нет или некорректны timestamp/nonce.
+	ErrMalformed = errors.New("malformed timestamp or nonce")
+	// ErrExpired время подписи вне допустимого окна.
+	ErrExpired = errors.New("timestamp outside of allowed window")
+	// ErrReplayed nonce уже использовался.
+	ErrReplayed = errors.New("nonce already used")
+	// ErrFull кеш заполнен nonce, которые еще могут пройти проверку времени.
+	ErrFull = errors.New("nonce cache is full")
+)
+
+type entry struct {
+	nonce   string
+	expires time.Time
+}
+
+// Guard проверяет время подписи и уникальность nonce.
+// Nonce хранятся, пока подпись с ними может пройти проверку времени, но не более size штук.
+// Действующие nonce не вытесняются: при переполнении новые запросы отклоняются с [ErrFull],
+// иначе вытесненный nonce можно было бы повторить.
+type Guard struct {
+	mu     sync.Mutex
+	window time.Duration
+	size   int
+	nonces map[string]*list.Element
+	order  *list.List
+	now    func() time.Time
+}
+
+// NewGuard конструктор. window - допустимое расхождение часов, size - размер кеша nonce.
+func NewGuard(window time.Duration, size int) *Guard {
+	return &Guard{
+		window: window,
+		size:   size,
+		nonces: make(map[string]*list.Element),
+		order:  list.New(),
+		now:    time.Now,
+	}
+}
+
+// Check проверяет timestamp (unix-секунды) и nonce и запоминает nonce.
+func (g *Guard) Check(timestamp, nonce string) error {
+	if nonce == "" {
+		return ErrMalformed
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMalformed
+	}
+
+	now := g.now()
+	ts := time.Unix(sec, 0)
+	if ts.Before(now.Add(-g.window)) || ts.After(now.Add(g.window)) {
+		return ErrExpired
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.evictExpired(now)
+	if _, ok := g.nonces[nonce]; ok {
+		return ErrReplayed
+	}
+	if g.order.Len() >= g.size {
+		g.sweepExpired(now)
+		if g.order.Len() >= g.size {
+			return ErrFull
+		}
+	}
+	g.nonces[nonce] = g.order.PushBack(&entry{
+		nonce:   nonce,
+		expires: ts.Add(g.window),
+	})
+
+	return nil
+}
+
+// Len количество хранимых nonce.
+func (g *Guard) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.order.Len()
+}
+
+// evictExpired удаляет nonce, подписи с которыми уже не пройдут проверку времени.
+// Записи добавляются почти по порядку времени, поэтому достаточно проверять начало списка.
+func (g *Guard) evictExpired(now time.Time) {
+	for el := g.order.Front(); el != nil && el.Value.(*entry).expires.Before(now); el = g.order.Front() {
+		g.remove(el)
+	}
+}
+
+// sweepExpired удаляет все устаревшие nonce, включая добавленные не по порядку времени.
+func (g *Guard) sweepExpired(now time.Time) {
+	for el := g.order.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*entry).expires.Before(now) {
+			g.remove(el)
+		}
+		el = next
+	}
+}
+
+func (g *Guard) remove(el *list.Element) {
+	delete(g.nonces, el.Value.(*entry).nonce)
+	g.order.Remove(el)
+}
@@ -0,0 +1,71 @@
+package replay
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuard_Check(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ts := func(d time.Duration) string {
+		return strconv.FormatInt(now.Add(d).Unix(), 10)
+	}
+
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		wantErr   error
+	}{
+		{name: "Positive_test_fresh", timestamp: ts(0), nonce: "a"},
+		{name: "Positive_test_clock_skew", timestamp: ts(-59 * time.Second), nonce: "b"},
+		{name: "Negative_test_replayed", timestamp: ts(0), nonce: "a", wantErr: ErrReplayed},
+		{name: "Negative_test_too_old", timestamp: ts(-2 * time.Minute), nonce: "c", wantErr: ErrExpired},
+		{name: "Negative_test_from_future", timestamp: ts(2 * time.Minute), nonce: "d", wantErr: ErrExpired},
+		{name: "Negative_test_no_nonce", timestamp: ts(0), wantErr: ErrMalformed},
+		{name: "Negative_test_bad_timestamp", timestamp: "yesterday", nonce: "e", wantErr: ErrMalformed},
+	}
+
+	g := NewGuard(time.Minute, 10)
+	g.now = func() time.Time { return now }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, g.Check(tt.timestamp, tt.nonce), tt.wantErr)
+		})
+	}
+}
+
+func TestGuard_Eviction(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	g := NewGuard(time.Minute, 2)
+	g.now = func() time.Time { return now }
+
+	ts := strconv.FormatInt(now.Unix(), 10)
+	require.NoError(t, g.Check(ts, "a"))
+	require.NoError(t, g.Check(ts, "b"))
+	require.ErrorIs(t, g.Check(ts, "c"), ErrFull)
+	require.Equal(t, 2, g.Len())
+	// при переполнении действующие nonce не вытесняются.
+	require.ErrorIs(t, g.Check(ts, "a"), ErrReplayed)
+
+	now = now.Add(90 * time.Second)
+	require.NoError(t, g.Check(strconv.FormatInt(now.Unix(), 10), "d"))
+	require.Equal(t, 1, g.Len())
+}
+
+func TestGuard_Full_OutOfOrder(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	g := NewGuard(time.Minute, 2)
+	g.now = func() time.Time { return now }
+
+	// первый nonce подписан с опережением часов и устаревает позже второго.
+	require.NoError(t, g.Check(strconv.FormatInt(now.Add(30*time.Second).Unix(), 10), "a"))
+	require.NoError(t, g.Check(strconv.FormatInt(now.Add(-30*time.Second).Unix(), 10), "b"))
+
+	now = now.Add(45 * time.Second)
+	require.NoError(t, g.Check(strconv.FormatInt(now.Unix(), 10), "c"))
+	require.ErrorIs(t, g.Check(strconv.FormatInt(now.Unix(), 10), "a"), ErrReplayed)
+}
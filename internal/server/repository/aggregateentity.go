@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+// Разрешения агрегатов истории.
+const (
+	ResolutionMinute = time.Minute
+	ResolutionHour   = time.Hour
+)
+
+// RetentionPolicy политика хранения истории.
+// Raw - возраст, после которого отсчеты сворачиваются в минутные агрегаты,
+// Minute - возраст, после которого минутные агрегаты сворачиваются в часовые,
+// Hour - возраст, после которого удаляются часовые агрегаты (0 - хранить всегда).
+type RetentionPolicy struct {
+	Raw    time.Duration
+	Minute time.Duration
+	Hour   time.Duration
+}
+
+// AggregateEntity агрегат отсчетов метрики за интервал [Start, Start+Resolution).
+// Min, Max, Sum и Last считаются по значениям gauge, Delta - сумма дельт counter.
+type AggregateEntity struct {
+	Key        string        `json:"key"`
+	Type       metric.Type   `json:"type"`
+	Name       string        `json:"name"`
+	Labels     metric.Labels `json:"labels,omitempty"`
+	Resolution time.Duration `json:"resolution"`
+	Start      time.Time     `json:"start"`
+	Min        float64       `json:"min"`
+	Max        float64       `json:"max"`
+	Sum        float64       `json:"sum"`
+	Last       float64       `json:"last"`
+	LastTime   time.Time     `json:"last_time"`
+	Count      int64         `json:"count"`
+	Delta      int64         `json:"delta"`
+}
+
+// newAggregateEntity пустой агрегат для отсчета s с разрешением res.
+func newAggregateEntity(s SampleEntity, res time.Duration) AggregateEntity {
+	return AggregateEntity{
+		Key:        s.Key,
+		Type:       s.Type,
+		Name:       s.Name,
+		Labels:     s.Labels,
+		Resolution: res,
+		Start:      s.Time.Truncate(res),
+	}
+}
+
+// Add добавляет отсчет в агрегат.
+func (a *AggregateEntity) Add(s SampleEntity) {
+	a.Merge(AggregateEntity{
+		Min:      s.Value,
+		Max:      s.Value,
+		Sum:      s.Value,
+		Last:     s.Value,
+		LastTime: s.Time,
+		Count:    1,
+		Delta:    s.Delta,
+	})
+}
+
+// Merge объединяет агрегат с агрегатом o.
+func (a *AggregateEntity) Merge(o AggregateEntity) {
+	if o.Count == 0 {
+		return
+	}
+	if a.Count == 0 {
+		a.Min, a.Max = o.Min, o.Max
+	} else {
+		a.Min = min(a.Min, o.Min)
+		a.Max = max(a.Max, o.Max)
+	}
+	if !o.LastTime.Before(a.LastTime) {
+		a.Last, a.LastTime = o.Last, o.LastTime
+	}
+	a.Sum += o.Sum
+	a.Count += o.Count
+	a.Delta += o.Delta
+}
+
+// ToSample мап агрегата в дто.
+func (a *AggregateEntity) ToSample() metric.Sample {
+	sm := metric.Sample{
+		Time:  a.Start,
+		Count: &a.Count,
+	}
+
+	switch a.Type {
+	case metric.TypeCounter:
+		sm.Delta = &a.Delta
+	case metric.TypeGauge, metric.TypeHistogram:
+		avg := a.Sum / float64(a.Count)
+		sm.Value = &a.Last
+		sm.Min = &a.Min
+		sm.Max = &a.Max
+		sm.Avg = &avg
+		if a.Type == metric.TypeHistogram {
+			sm.Delta = &a.Delta
+		}
+	}
+
+	return sm
+}
@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
+)
+
+const compactTimeout = 30 * time.Second
+
+var (
+	insertSampleQuery = `
+	INSERT INTO metric_samples ("type", "name", "labels", "delta", "value", "ts", "tenant")
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	rangeSamplesQuery = `
+	SELECT "type", "name", "labels", "delta", "value", "ts", "tenant"
+		FROM metric_samples
+		WHERE "type" = $1
+		AND "name" = $2
+		AND "labels" = $3
+		AND ($4::TIMESTAMPTZ IS NULL OR "ts" >= $4)
+		AND ($5::TIMESTAMPTZ IS NULL OR "ts" <= $5)
+		AND "tenant" = $6
+		ORDER BY "ts"
+	`
+	rangeAggregatesQuery = `
+	SELECT "type", "name", "labels", "start", "min", "max", "sum", "last", "last_ts", "count", "delta"
+		FROM metric_aggregates
+		WHERE "type" = $1
+		AND "name" = $2
+		AND "labels" = $3
+		AND "resolution" = $4
+		AND ($5::TIMESTAMPTZ IS NULL OR "start" >= $5)
+		AND ($6::TIMESTAMPTZ IS NULL OR "start" <= $6)
+		AND "tenant" = $7
+		ORDER BY "start"
+	`
+	// общая часть свертки: при повторной свертке в существующий интервал агрегаты объединяются.
+	mergeAggregatesClause = `
+	ON CONFLICT ("tenant", "type", "name", "labels", "resolution", "start") DO UPDATE
+		SET "min"     = LEAST(metric_aggregates.min, EXCLUDED.min),
+			"max"     = GREATEST(metric_aggregates.max, EXCLUDED.max),
+			"sum"     = metric_aggregates.sum + EXCLUDED.sum,
+			"last"    = CASE WHEN EXCLUDED.last_ts >= metric_aggregates.last_ts
+				THEN EXCLUDED.last ELSE metric_aggregates.last END,
+			"last_ts" = GREATEST(metric_aggregates.last_ts, EXCLUDED.last_ts),
+			"count"   = metric_aggregates.count + EXCLUDED.count,
+			"delta"   = metric_aggregates.delta + EXCLUDED.delta
+	`
+	rollupSamplesQuery = `
+	INSERT INTO metric_aggregates ("tenant", "type", "name", "labels", "resolution", "start",
+		"min", "max", "sum", "last", "last_ts", "count", "delta")
+	SELECT "tenant", "type", "name", "labels", $2, date_trunc('minute', "ts"),
+		MIN("value"), MAX("value"), SUM("value"), (array_agg("value" ORDER BY "ts" DESC))[1], MAX("ts"),
+		COUNT(*), SUM("delta")
+		FROM metric_samples
+		WHERE "ts" < $1
+		GROUP BY "tenant", "type", "name", "labels", date_trunc('minute', "ts")
+	` + mergeAggregatesClause
+	rollupMinutesQuery = `
+	INSERT INTO metric_aggregates ("tenant", "type", "name", "labels", "resolution", "start",
+		"min", "max", "sum", "last", "last_ts", "count", "delta")
+	SELECT "tenant", "type", "name", "labels", $3, date_trunc('hour', "start"),
+		MIN("min"), MAX("max"), SUM("sum"), (array_agg("last" ORDER BY "last_ts" DESC))[1], MAX("last_ts"),
+		SUM("count"), SUM("delta")
+		FROM metric_aggregates
+		WHERE "resolution" = $2
+		AND "start" < $1
+		GROUP BY "tenant", "type", "name", "labels", date_trunc('hour', "start")
+	` + mergeAggregatesClause
+	deleteSamplesQuery    = `DELETE FROM metric_samples WHERE "ts" < $1`
+	deleteAggregatesQuery = `DELETE FROM metric_aggregates WHERE "resolution" = $2 AND "start" < $1`
+)
+
+type compactStep struct {
+	query string
+	args  []any
+}
+
+// DBHistoryRepository хранилище истории метрик в БД.
+type DBHistoryRepository struct {
+	db     *sql.DB
+	logger *zap.SugaredLogger
+}
+
+// NewDBHistoryRepository конструктор.
+func NewDBHistoryRepository(db *sql.DB, logger *zap.SugaredLogger) *DBHistoryRepository {
+	return &DBHistoryRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Append добавляет отсчеты.
+func (dbh *DBHistoryRepository) Append(ctx context.Context, samples []SampleEntity) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	var (
+		err  error
+		tx   *sql.Tx
+		stmt *sql.Stmt
+	)
+
+	c, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if tx, err = dbh.db.BeginTx(c, nil); err != nil {
+		return err
+	}
+	txOK := false
+	defer func() {
+		if !txOK {
+			if e := tx.Rollback(); e != nil {
+				dbh.logger.Errorf("tx.Rollback error: %v", e)
+			}
+		}
+	}()
+
+	if stmt, err = tx.Prepare(insertSampleQuery); err != nil {
+		return err
+	}
+	for _, s := range samples {
+		if _, err = stmt.Exec(s.Type, s.Name, s.Labels.String(), s.Delta, s.Value, s.Time, s.Tenant); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	txOK = true
+	return nil
+}
+
+// Range возвращает отсчеты метрики тенанта из ctx за интервал [from, to] в порядке времени.
+func (dbh *DBHistoryRepository) Range(ctx context.Context, t, n string, l metric.Labels, from, to time.Time) ([]SampleEntity, error) {
+	c, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	rows, err := dbh.db.QueryContext(c, rangeSamplesQuery, t, n, l.String(), nullTime(from), nullTime(to), tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			dbh.logger.Errorf("rows close error: %v", e)
+		}
+	}()
+
+	samples := make([]SampleEntity, 0)
+	for rows.Next() {
+		var (
+			s      SampleEntity
+			labels string
+		)
+		if err = rows.Scan(&s.Type, &s.Name, &labels, &s.Delta, &s.Value, &s.Time, &s.Tenant); err != nil {
+			return nil, err
+		}
+		if s.Labels, err = metric.ParseLabels(labels); err != nil {
+			return nil, err
+		}
+		s.Key = metric.Key(fmt.Sprint(s.Type), s.Name, s.Labels)
+
+		samples = append(samples, s)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return samples, nil
+}
+
+// Aggregates возвращает агрегаты метрики тенанта из ctx с разрешением res, начавшиеся в интервале [from, to].
+func (dbh *DBHistoryRepository) Aggregates(ctx context.Context, t, n string, l metric.Labels, res time.Duration, from, to time.Time) ([]AggregateEntity, error) {
+	c, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	rows, err := dbh.db.QueryContext(c, rangeAggregatesQuery, t, n, l.String(), int64(res.Seconds()), nullTime(from), nullTime(to), tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			dbh.logger.Errorf("rows close error: %v", e)
+		}
+	}()
+
+	aggregates := make([]AggregateEntity, 0)
+	for rows.Next() {
+		var (
+			a      = AggregateEntity{Resolution: res}
+			labels string
+		)
+		if err = rows.Scan(&a.Type, &a.Name, &labels, &a.Start,
+			&a.Min, &a.Max, &a.Sum, &a.Last, &a.LastTime, &a.Count, &a.Delta); err != nil {
+			return nil, err
+		}
+		if a.Labels, err = metric.ParseLabels(labels); err != nil {
+			return nil, err
+		}
+		a.Key = metric.Key(fmt.Sprint(a.Type), a.Name, a.Labels)
+
+		aggregates = append(aggregates, a)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return aggregates, nil
+}
+
+// Compact сворачивает устаревшие отсчеты в агрегаты и удаляет данные согласно политике p.
+func (dbh *DBHistoryRepository) Compact(ctx context.Context, p RetentionPolicy, now time.Time) error {
+	var (
+		err error
+		tx  *sql.Tx
+	)
+
+	c, cancel := context.WithTimeout(ctx, compactTimeout)
+	defer cancel()
+
+	if tx, err = dbh.db.BeginTx(c, nil); err != nil {
+		return err
+	}
+	txOK := false
+	defer func() {
+		if !txOK {
+			if e := tx.Rollback(); e != nil {
+				dbh.logger.Errorf("tx.Rollback error: %v", e)
+			}
+		}
+	}()
+
+	minute, hour := int64(ResolutionMinute.Seconds()), int64(ResolutionHour.Seconds())
+	rawCutoff, minuteCutoff := now.Add(-p.Raw), now.Add(-p.Minute)
+
+	steps := []compactStep{
+		{rollupSamplesQuery, []any{rawCutoff, minute}},
+		{deleteSamplesQuery, []any{rawCutoff}},
+		{rollupMinutesQuery, []any{minuteCutoff, minute, hour}},
+		{deleteAggregatesQuery, []any{minuteCutoff, minute}},
+	}
+	if p.Hour > 0 {
+		steps = append(steps, compactStep{deleteAggregatesQuery, []any{now.Add(-p.Hour), hour}})
+	}
+
+	for _, st := range steps {
+		if _, err = tx.ExecContext(c, st.query, st.args...); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	txOK = true
+	return nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -8,37 +8,67 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"go.uber.org/zap"
 
 	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
 )
 
 var (
 	upsertQuery = `
-	INSERT INTO metrics ("type", "name", "delta", "value")
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO metrics ("type", "name", "labels", "delta", "value", "hist_bounds", "hist_counts", "hist_sum", "hist_count", "tenant")
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	ON CONFLICT ON CONSTRAINT type_name_uidx DO UPDATE
-		SET "delta"      = metrics.delta + EXCLUDED.delta,
-			"value"      = EXCLUDED.value,
-			"updated_at" = NOW()
+		SET "delta"       = metrics.delta + EXCLUDED.delta,
+			"value"       = EXCLUDED.value,
+			"hist_counts" = CASE WHEN metrics.hist_bounds = EXCLUDED.hist_bounds
+				THEN (
+					SELECT array_agg(COALESCE(o, 0) + COALESCE(n, 0) ORDER BY i)
+					FROM unnest(metrics.hist_counts, EXCLUDED.hist_counts) WITH ORDINALITY AS t(o, n, i)
+				)
+				ELSE EXCLUDED.hist_counts END,
+			"hist_sum"    = CASE WHEN metrics.hist_bounds = EXCLUDED.hist_bounds
+				THEN metrics.hist_sum + EXCLUDED.hist_sum
+				ELSE EXCLUDED.hist_sum END,
+			"hist_count"  = CASE WHEN metrics.hist_bounds = EXCLUDED.hist_bounds
+				THEN metrics.hist_count + EXCLUDED.hist_count
+				ELSE EXCLUDED.hist_count END,
+			"hist_bounds" = EXCLUDED.hist_bounds,
+			"updated_at"  = NOW()
 	`
 	replaceQuery = `
-	INSERT INTO metrics (type, name, delta, value)
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO metrics ("type", "name", "labels", "delta", "value", "hist_bounds", "hist_counts", "hist_sum", "hist_count", "tenant")
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	ON CONFLICT ON CONSTRAINT type_name_uidx DO UPDATE
-		SET "delta"      = metrics.delta,
-			"value"      = EXCLUDED.value,
-			"updated_at" = NOW()
+		SET "delta"       = metrics.delta,
+			"value"       = EXCLUDED.value,
+			"hist_bounds" = EXCLUDED.hist_bounds,
+			"hist_counts" = EXCLUDED.hist_counts,
+			"hist_sum"    = EXCLUDED.hist_sum,
+			"hist_count"  = EXCLUDED.hist_count,
+			"updated_at"  = NOW()
 	`
 	findQuery = `
 	SELECT 
-			"type", "name", "delta", "value" 
+			"type", "name", "labels", "delta", "value", "hist_bounds", "hist_counts", "hist_sum", "hist_count", "tenant"
 		FROM metrics 
 		WHERE "type" = $1
 		AND "name" = $2
+		AND "labels" = $3
+		AND "tenant" = $4
+	`
+	removeQuery       = `DELETE FROM metrics WHERE "type" = $1 AND "name" = $2 AND "labels" = $3 AND "tenant" = $4`
+	selectColumns     = `SELECT "type", "name", "labels", "delta", "value", "hist_bounds", "hist_counts", "hist_sum", "hist_count", "tenant" FROM metrics`
+	selectAllQuery    = selectColumns + ` WHERE "tenant" = $1`
+	selectBackupQuery = selectColumns
+	countQuery        = `SELECT COUNT(*) FROM metrics WHERE "tenant" = $1`
+	existingQuery     = `
+	SELECT "type", "name", "labels"
+		FROM metrics
+		WHERE "tenant" = $1
+		AND ("type"::TEXT, "name", "labels") IN (SELECT * FROM unnest($2::TEXT[], $3::TEXT[], $4::TEXT[]))
 	`
-	removeQuery    = `DELETE FROM metrics WHERE "type" = $1 AND "name" = $2`
-	selectAllQuery = `SELECT "type", "name", "delta", "value" FROM metrics`
 )
 
 const (
@@ -66,46 +96,97 @@ func (dbm *DBMetricRepository) Upsert(ctx context.Context, m MetricEntity) error
 	c, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	_, err := dbm.db.ExecContext(c, upsertQuery,
-		m.Type, m.Name, m.Delta, m.Value)
+	_, err := dbm.db.ExecContext(c, upsertQuery, entityArgs(m)...)
 	return err
 }
 
-// Find поиск по ключу.
-func (dbm *DBMetricRepository) Find(ctx context.Context, t, n string) (*MetricEntity, error) {
+// Find поиск по ключу среди метрик тенанта из ctx.
+func (dbm *DBMetricRepository) Find(ctx context.Context, t, n string, l metric.Labels) (*MetricEntity, error) {
 	c, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	m := MetricEntity{}
-	r := dbm.db.QueryRowContext(c, findQuery, t, n)
-	if err := r.Scan(&m.Type, &m.Name, &m.Delta, &m.Value); err != nil {
+	r := dbm.db.QueryRowContext(c, findQuery, t, n, l.String(), tenant.FromContext(ctx))
+	m, err := scanEntity(r, pgtype.NewMap())
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	m.Key = metric.Key(fmt.Sprint(m.Type), n)
 
 	return &m, nil
 }
 
-// Remove удаляет по типу и наименованию.
-func (dbm *DBMetricRepository) Remove(ctx context.Context, t, n string) error {
+// Remove удаляет по типу, наименованию и меткам метрику тенанта из ctx.
+func (dbm *DBMetricRepository) Remove(ctx context.Context, t, n string, l metric.Labels) error {
 	c, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	if _, err := dbm.db.ExecContext(c, removeQuery, t, n); err != nil {
+	if _, err := dbm.db.ExecContext(c, removeQuery, t, n, l.String(), tenant.FromContext(ctx)); err != nil {
 		return err
 	}
 	return nil
 }
 
-// All вернуть все метрики.
+// All вернуть все метрики тенанта из ctx.
 func (dbm *DBMetricRepository) All(ctx context.Context) ([]MetricEntity, error) {
+	return dbm.selectAll(ctx, selectAllQuery, tenant.FromContext(ctx))
+}
+
+// Count кол-во метрик тенанта из ctx.
+func (dbm *DBMetricRepository) Count(ctx context.Context) (int, error) {
+	c, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var count int
+	if err := dbm.db.QueryRowContext(c, countQuery, tenant.FromContext(ctx)).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Existing ключи метрик тенанта из ctx, которые уже есть в хранилище, одним запросом.
+func (dbm *DBMetricRepository) Existing(ctx context.Context, entities []MetricEntity) (map[string]struct{}, error) {
+	c, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	types, names, labels := make([]string, 0, len(entities)), make([]string, 0, len(entities)), make([]string, 0, len(entities))
+	for _, en := range entities {
+		types = append(types, en.Type.String())
+		names = append(names, en.Name)
+		labels = append(labels, en.Labels.String())
+	}
+
+	rows, err := dbm.db.QueryContext(c, existingQuery, tenant.FromContext(ctx), types, names, labels)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil {
+			dbm.logger.Errorf("rows close error: %v", e)
+		}
+	}()
+
+	existing := make(map[string]struct{})
+	for rows.Next() {
+		var t, n, l string
+		if err = rows.Scan(&t, &n, &l); err != nil {
+			return nil, err
+		}
+		ll, err := metric.ParseLabels(l)
+		if err != nil {
+			return nil, err
+		}
+		existing[metric.Key(t, n, ll)] = struct{}{}
+	}
+	return existing, rows.Err()
+}
+
+func (dbm *DBMetricRepository) selectAll(ctx context.Context, query string, args ...any) ([]MetricEntity, error) {
 	c, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	rows, err := dbm.db.QueryContext(c, selectAllQuery)
+	rows, err := dbm.db.QueryContext(c, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -115,13 +196,13 @@ func (dbm *DBMetricRepository) All(ctx context.Context) ([]MetricEntity, error)
 		}
 	}()
 
+	typeMap := pgtype.NewMap()
 	entities := make([]MetricEntity, 0)
 	for rows.Next() {
-		m := MetricEntity{}
-		if err = rows.Scan(&m.Type, &m.Name, &m.Delta, &m.Value); err != nil {
+		var m MetricEntity
+		if m, err = scanEntity(rows, typeMap); err != nil {
 			return nil, err
 		}
-		m.Key = metric.Key(fmt.Sprint(m.Type), m.Name)
 
 		entities = append(entities, m)
 	}
@@ -142,7 +223,7 @@ func (dbm *DBMetricRepository) Backup(ctx context.Context) error {
 		entities []MetricEntity
 		err      error
 	)
-	if entities, err = dbm.All(ctx); err != nil {
+	if entities, err = dbm.selectAll(ctx, selectBackupQuery); err != nil {
 		return err
 	}
 
@@ -199,7 +280,7 @@ func (dbm *DBMetricRepository) batch(ctx context.Context, query string, mt []Met
 		return err
 	}
 	for _, m := range mt {
-		if _, err = stmt.Exec(m.Type, m.Name, m.Delta, m.Value); err != nil {
+		if _, err = stmt.Exec(entityArgs(m)...); err != nil {
 			return err
 		}
 	}
@@ -210,3 +291,60 @@ func (dbm *DBMetricRepository) batch(ctx context.Context, query string, mt []Met
 	txOK = true
 	return nil
 }
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEntity читает строку выборки в сущность.
+// typeMap нужен для чтения массивов и не потокобезопасен.
+func scanEntity(r rowScanner, typeMap *pgtype.Map) (MetricEntity, error) {
+	var (
+		m      MetricEntity
+		labels string
+		bounds []float64
+		counts []int64
+		sum    float64
+		count  int64
+		err    error
+	)
+
+	if err = r.Scan(
+		&m.Type, &m.Name, &labels, &m.Delta, &m.Value,
+		typeMap.SQLScanner(&bounds), typeMap.SQLScanner(&counts), &sum, &count, &m.Tenant,
+	); err != nil {
+		return m, err
+	}
+	if m.Labels, err = metric.ParseLabels(labels); err != nil {
+		return m, err
+	}
+	m.Key = metric.Key(fmt.Sprint(m.Type), m.Name, m.Labels)
+
+	if m.Type == metric.TypeHistogram && counts != nil {
+		m.Histogram = &metric.Histogram{
+			Bounds: bounds,
+			Counts: counts,
+			Sum:    sum,
+			Count:  count,
+		}
+	}
+
+	return m, nil
+}
+
+// entityArgs аргументы для upsertQuery и replaceQuery.
+// Метки хранятся в каноничной форме, которая входит в уникальный индекс.
+func entityArgs(m MetricEntity) []any {
+	var (
+		bounds []float64
+		counts []int64
+		sum    float64
+		count  int64
+	)
+	if m.Histogram != nil {
+		bounds, counts = m.Histogram.Bounds, m.Histogram.Counts
+		sum, count = m.Histogram.Sum, m.Histogram.Count
+	}
+
+	return []any{m.Type, m.Name, m.Labels.String(), m.Delta, m.Value, bounds, counts, sum, count, m.Tenant}
+}
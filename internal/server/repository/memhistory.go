@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
+)
+
+// minRingSize начальный размер буфера ряда: буфер растет по мере поступления отсчетов до capacity.
+const minRingSize = 8
+
+// samplePoint отсчет ряда без общих для ряда полей.
+type samplePoint struct {
+	time  time.Time
+	delta int64
+	value float64
+}
+
+// sampleRing кольцевой буфер отсчетов одной метрики. Ключ, метки и тенант хранятся один раз на ряд.
+type sampleRing struct {
+	key    string
+	mType  metric.Type
+	name   string
+	labels metric.Labels
+	tenant string
+
+	capacity int
+	buf      []samplePoint
+	start    int
+	size     int
+}
+
+func newSampleRing(s SampleEntity, capacity int) *sampleRing {
+	return &sampleRing{
+		key:      s.Key,
+		mType:    s.Type,
+		name:     s.Name,
+		labels:   s.Labels.Clone(),
+		tenant:   s.Tenant,
+		capacity: capacity,
+	}
+}
+
+func (r *sampleRing) push(s SampleEntity) {
+	if r.size == len(r.buf) && len(r.buf) < r.capacity {
+		r.grow()
+	}
+	idx := (r.start + r.size) % len(r.buf)
+	r.buf[idx] = samplePoint{time: s.Time, delta: s.Delta, value: s.Value}
+	if r.size < len(r.buf) {
+		r.size++
+		return
+	}
+	// буфер заполнен, самый старый отсчет перезаписан.
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+// grow увеличивает буфер вдвое, но не больше capacity, сохраняя порядок отсчетов.
+func (r *sampleRing) grow() {
+	buf := make([]samplePoint, min(max(2*len(r.buf), minRingSize), r.capacity))
+	for i := 0; i < r.size; i++ {
+		buf[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+	r.buf, r.start = buf, 0
+}
+
+// shiftWhile удаляет с начала буфера отсчеты, пока fn возвращает true.
+func (r *sampleRing) shiftWhile(fn func(s SampleEntity) bool) {
+	for r.size > 0 && fn(r.sample(r.buf[r.start])) {
+		r.buf[r.start] = samplePoint{}
+		r.start = (r.start + 1) % len(r.buf)
+		r.size--
+	}
+}
+
+// each обходит отсчеты от старых к новым.
+func (r *sampleRing) each(fn func(s SampleEntity)) {
+	for i := 0; i < r.size; i++ {
+		fn(r.sample(r.buf[(r.start+i)%len(r.buf)]))
+	}
+}
+
+// sample отсчет с общими полями ряда. Метки общие для всех отсчетов ряда.
+func (r *sampleRing) sample(p samplePoint) SampleEntity {
+	return SampleEntity{
+		Key:    r.key,
+		Type:   r.mType,
+		Name:   r.name,
+		Labels: r.labels,
+		Time:   p.time,
+		Delta:  p.delta,
+		Value:  p.value,
+		Tenant: r.tenant,
+	}
+}
+
+// MemHistoryRepository in-memory хранилище истории метрик.
+type MemHistoryRepository struct {
+	sm       sync.Mutex
+	capacity int
+	// отсчеты по ключу тенанта и метрики.
+	series map[string]*sampleRing
+	// агрегаты по разрешению и ключу тенанта и метрики, упорядочены по Start.
+	rollups map[time.Duration]map[string][]AggregateEntity
+}
+
+// NewMemHistoryRepository конструктор. capacity - кол-во хранимых отсчетов на метрику.
+func NewMemHistoryRepository(capacity int) *MemHistoryRepository {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MemHistoryRepository{
+		capacity: capacity,
+		series:   make(map[string]*sampleRing),
+		rollups: map[time.Duration]map[string][]AggregateEntity{
+			ResolutionMinute: make(map[string][]AggregateEntity),
+			ResolutionHour:   make(map[string][]AggregateEntity),
+		},
+	}
+}
+
+// Append добавляет отсчеты.
+func (h *MemHistoryRepository) Append(_ context.Context, samples []SampleEntity) error {
+	h.sm.Lock()
+	defer h.sm.Unlock()
+
+	for _, s := range samples {
+		key := seriesKey(s.Tenant, s.Key)
+		r, ok := h.series[key]
+		if !ok {
+			r = newSampleRing(s, h.capacity)
+			h.series[key] = r
+		}
+		r.push(s)
+	}
+	return nil
+}
+
+// Range возвращает отсчеты метрики тенанта из ctx за интервал [from, to] в порядке поступления.
+func (h *MemHistoryRepository) Range(ctx context.Context, t, n string, l metric.Labels, from, to time.Time) ([]SampleEntity, error) {
+	h.sm.Lock()
+	defer h.sm.Unlock()
+
+	samples := make([]SampleEntity, 0)
+	r, ok := h.series[seriesKey(tenant.FromContext(ctx), metric.Key(t, n, l))]
+	if !ok {
+		return samples, nil
+	}
+
+	r.each(func(s SampleEntity) {
+		if s.inRange(from, to) {
+			samples = append(samples, s)
+		}
+	})
+	return samples, nil
+}
+
+// Aggregates возвращает агрегаты метрики тенанта из ctx с разрешением res, начавшиеся в интервале [from, to].
+func (h *MemHistoryRepository) Aggregates(ctx context.Context, t, n string, l metric.Labels, res time.Duration, from, to time.Time) ([]AggregateEntity, error) {
+	h.sm.Lock()
+	defer h.sm.Unlock()
+
+	aggregates := make([]AggregateEntity, 0)
+	byKey, ok := h.rollups[res]
+	if !ok {
+		return aggregates, nil
+	}
+
+	for _, a := range byKey[seriesKey(tenant.FromContext(ctx), metric.Key(t, n, l))] {
+		if (from.IsZero() || !a.Start.Before(from)) && (to.IsZero() || !a.Start.After(to)) {
+			aggregates = append(aggregates, a)
+		}
+	}
+	return aggregates, nil
+}
+
+// Compact сворачивает устаревшие отсчеты в агрегаты и удаляет данные согласно политике p.
+func (h *MemHistoryRepository) Compact(_ context.Context, p RetentionPolicy, now time.Time) error {
+	h.sm.Lock()
+	defer h.sm.Unlock()
+
+	minutes := h.rollups[ResolutionMinute]
+	hours := h.rollups[ResolutionHour]
+
+	rawCutoff := now.Add(-p.Raw)
+	for key, r := range h.series {
+		r.shiftWhile(func(s SampleEntity) bool {
+			if !s.Time.Before(rawCutoff) {
+				return false
+			}
+			a := newAggregateEntity(s, ResolutionMinute)
+			a.Add(s)
+			minutes[key] = mergeAggregate(minutes[key], a)
+			return true
+		})
+		if r.size == 0 {
+			delete(h.series, key)
+		}
+	}
+
+	minuteCutoff := now.Add(-p.Minute)
+	for key, aggs := range minutes {
+		i := 0
+		for ; i < len(aggs) && aggs[i].Start.Before(minuteCutoff); i++ {
+			a := aggs[i]
+			a.Resolution = ResolutionHour
+			a.Start = a.Start.Truncate(ResolutionHour)
+			hours[key] = mergeAggregate(hours[key], a)
+		}
+		if i == len(aggs) {
+			delete(minutes, key)
+			continue
+		}
+		minutes[key] = aggs[i:]
+	}
+
+	if p.Hour <= 0 {
+		return nil
+	}
+	hourCutoff := now.Add(-p.Hour)
+	for key, aggs := range hours {
+		i := 0
+		for i < len(aggs) && aggs[i].Start.Before(hourCutoff) {
+			i++
+		}
+		if i == len(aggs) {
+			delete(hours, key)
+			continue
+		}
+		hours[key] = aggs[i:]
+	}
+
+	return nil
+}
+
+// seriesKey ключ ряда: метрики разных тенантов хранятся раздельно.
+func seriesKey(t, key string) string {
+	return t + "/" + key
+}
+
+// mergeAggregate добавляет агрегат a в упорядоченный по Start список.
+func mergeAggregate(aggs []AggregateEntity, a AggregateEntity) []AggregateEntity {
+	i, found := slices.BinarySearchFunc(aggs, a.Start, func(e AggregateEntity, t time.Time) int {
+		return e.Start.Compare(t)
+	})
+	if found {
+		aggs[i].Merge(a)
+		return aggs
+	}
+	return slices.Insert(aggs, i, a)
+}
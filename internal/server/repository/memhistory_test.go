@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+func TestMemHistoryRepository_Range(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(sec int, v float64) SampleEntity {
+		return SampleEntity{
+			Key:   "gauge:Alloc",
+			Type:  metric.TypeGauge,
+			Name:  "Alloc",
+			Time:  base.Add(time.Duration(sec) * time.Second),
+			Value: v,
+		}
+	}
+
+	type args struct {
+		from time.Time
+		to   time.Time
+	}
+	tests := []struct {
+		name     string
+		capacity int
+		samples  []SampleEntity
+		args     args
+		want     []float64
+	}{
+		{
+			name:     "Positive_test_all",
+			capacity: 10,
+			samples:  []SampleEntity{sample(1, 1), sample(2, 2), sample(3, 3)},
+			want:     []float64{1, 2, 3},
+		},
+		{
+			name:     "Positive_test_ring_overwrites_oldest",
+			capacity: 2,
+			samples:  []SampleEntity{sample(1, 1), sample(2, 2), sample(3, 3)},
+			want:     []float64{2, 3},
+		},
+		{
+			name:     "Positive_test_interval",
+			capacity: 10,
+			samples:  []SampleEntity{sample(1, 1), sample(2, 2), sample(3, 3), sample(4, 4)},
+			args: args{
+				from: base.Add(2 * time.Second),
+				to:   base.Add(3 * time.Second),
+			},
+			want: []float64{2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewMemHistoryRepository(tt.capacity)
+			ctx := context.Background()
+			if err := h.Append(ctx, tt.samples); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := h.Range(ctx, "gauge", "Alloc", nil, tt.args.from, tt.args.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			values := make([]float64, 0, len(got))
+			for _, s := range got {
+				values = append(values, s.Value)
+			}
+			assert.Equal(t, tt.want, values)
+		})
+	}
+}
+
+func TestMemHistoryRepository_Compact(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	gauge := func(at time.Duration, v float64) SampleEntity {
+		return SampleEntity{
+			Key:   "gauge:Alloc",
+			Type:  metric.TypeGauge,
+			Name:  "Alloc",
+			Time:  base.Add(at),
+			Value: v,
+		}
+	}
+	counter := func(at time.Duration, d int64) SampleEntity {
+		return SampleEntity{
+			Key:   "counter:PollCount",
+			Type:  metric.TypeCounter,
+			Name:  "PollCount",
+			Time:  base.Add(at),
+			Delta: d,
+		}
+	}
+
+	h := NewMemHistoryRepository(100)
+	ctx := context.Background()
+	err := h.Append(ctx, []SampleEntity{
+		gauge(10*time.Second, 5),
+		gauge(20*time.Second, 1),
+		gauge(30*time.Second, 3),
+		gauge(70*time.Second, 7),
+		gauge(2*time.Hour, 9),
+		counter(10*time.Second, 2),
+		counter(50*time.Second, 3),
+		counter(65*time.Minute, 4),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := RetentionPolicy{Raw: time.Hour, Minute: 90 * time.Minute, Hour: 24 * time.Hour}
+	now := base.Add(2*time.Hour + 30*time.Minute)
+	if err = h.Compact(ctx, policy, now); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := h.Range(ctx, "gauge", "Alloc", nil, time.Time{}, time.Time{})
+	assert.Len(t, raw, 1, "samples newer than raw retention are kept")
+
+	minutes, _ := h.Aggregates(ctx, "counter", "PollCount", nil, ResolutionMinute, time.Time{}, time.Time{})
+	if assert.Len(t, minutes, 1) {
+		assert.Equal(t, base.Add(65*time.Minute), minutes[0].Start)
+		assert.Equal(t, int64(4), minutes[0].Delta)
+	}
+
+	hours, _ := h.Aggregates(ctx, "gauge", "Alloc", nil, ResolutionHour, time.Time{}, time.Time{})
+	if assert.Len(t, hours, 1) {
+		assert.Equal(t, base, hours[0].Start)
+		assert.Equal(t, 1.0, hours[0].Min)
+		assert.Equal(t, 7.0, hours[0].Max)
+		assert.Equal(t, 7.0, hours[0].Last)
+		assert.Equal(t, 16.0, hours[0].Sum)
+		assert.Equal(t, int64(4), hours[0].Count)
+	}
+
+	counterHours, _ := h.Aggregates(ctx, "counter", "PollCount", nil, ResolutionHour, time.Time{}, time.Time{})
+	if assert.Len(t, counterHours, 1) {
+		assert.Equal(t, int64(5), counterHours[0].Delta)
+	}
+
+	if err = h.Compact(ctx, policy, now.Add(48*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	hours, _ = h.Aggregates(ctx, "gauge", "Alloc", nil, ResolutionHour, time.Time{}, time.Time{})
+	assert.Empty(t, hours, "aggregates older than hour retention are removed")
+	raw, _ = h.Range(ctx, "gauge", "Alloc", nil, time.Time{}, time.Time{})
+	assert.Empty(t, raw)
+}
+
+func TestSampleRing_Grow(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(v int) SampleEntity {
+		return SampleEntity{
+			Key:    `gauge:Alloc{host="a"}`,
+			Type:   metric.TypeGauge,
+			Name:   "Alloc",
+			Labels: metric.Labels{"host": "a"},
+			Time:   base.Add(time.Duration(v) * time.Second),
+			Value:  float64(v),
+			Tenant: "t1",
+		}
+	}
+	values := func(r *sampleRing) []float64 {
+		var got []float64
+		r.each(func(s SampleEntity) {
+			assert.Equal(t, "t1", s.Tenant)
+			assert.Equal(t, metric.Labels{"host": "a"}, s.Labels)
+			got = append(got, s.Value)
+		})
+		return got
+	}
+
+	r := newSampleRing(sample(0), 20)
+	assert.Empty(t, r.buf)
+
+	r.push(sample(1))
+	assert.Len(t, r.buf, minRingSize)
+
+	// сдвиг начала и рост буфера при переносе через границу.
+	for v := 2; v <= minRingSize; v++ {
+		r.push(sample(v))
+	}
+	r.shiftWhile(func(s SampleEntity) bool { return s.Value <= 3 })
+	for v := minRingSize + 1; v <= 12; v++ {
+		r.push(sample(v))
+	}
+	assert.Len(t, r.buf, 2*minRingSize)
+	assert.Equal(t, []float64{4, 5, 6, 7, 8, 9, 10, 11, 12}, values(r))
+
+	// рост ограничен capacity, дальше перезаписываются старые отсчеты.
+	for v := 13; v <= 30; v++ {
+		r.push(sample(v))
+	}
+	assert.Len(t, r.buf, 20)
+	got := values(r)
+	assert.Len(t, got, 20)
+	assert.Equal(t, 11.0, got[0])
+	assert.Equal(t, 30.0, got[19])
+}
@@ -9,6 +9,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
 )
 
 // MetricSnapshot интерфейс для чтения/сохранения снимка данных.
@@ -19,8 +20,9 @@ type MetricSnapshot interface {
 
 // MemMetricRepository in-memory хранилище.
 type MemMetricRepository struct {
-	sm       sync.Mutex
-	Metrics  map[string]MetricEntity
+	sm sync.Mutex
+	// Metrics метрики по тенантам и ключам.
+	Metrics  map[string]map[string]MetricEntity
 	snapshot MetricSnapshot
 	logger   *zap.SugaredLogger
 }
@@ -29,36 +31,29 @@ type MemMetricRepository struct {
 func NewMemRepository(snapshot MetricSnapshot, logger *zap.SugaredLogger) (*MemMetricRepository, error) {
 	repo := MemMetricRepository{
 		snapshot: snapshot,
-		Metrics:  make(map[string]MetricEntity),
+		Metrics:  make(map[string]map[string]MetricEntity),
 		logger:   logger,
 	}
 
 	return &repo, nil
 }
 
-// Upsert сохраняет или обновляет существующую метрику.
+// Upsert сохраняет или обновляет существующую метрику тенанта m.Tenant.
 func (s *MemMetricRepository) Upsert(_ context.Context, m MetricEntity) error {
 	s.sm.Lock()
 	defer s.sm.Unlock()
 
-	old, exists := s.Metrics[m.Key]
-	if exists {
-		old.Value = m.Value
-		old.Delta += m.Delta
-		s.Metrics[m.Key] = old
-	} else {
-		s.Metrics[m.Key] = m
-	}
+	s.upsert(m)
 	return nil
 }
 
-// Find поиск по ключу.
-func (s *MemMetricRepository) Find(_ context.Context, t, n string) (*MetricEntity, error) {
+// Find поиск по ключу среди метрик тенанта из ctx.
+func (s *MemMetricRepository) Find(ctx context.Context, t, n string, l metric.Labels) (*MetricEntity, error) {
 	s.sm.Lock()
 	defer s.sm.Unlock()
 
-	key := metric.Key(t, n)
-	entity, ok := s.Metrics[key]
+	key := metric.Key(t, n, l)
+	entity, ok := s.Metrics[tenant.FromContext(ctx)][key]
 	if !ok {
 		return nil, nil
 	}
@@ -66,25 +61,49 @@ func (s *MemMetricRepository) Find(_ context.Context, t, n string) (*MetricEntit
 	return &entity, nil
 }
 
-// All вернуть все метрики.
-func (s *MemMetricRepository) All(_ context.Context) ([]MetricEntity, error) {
+// All вернуть все метрики тенанта из ctx.
+func (s *MemMetricRepository) All(ctx context.Context) ([]MetricEntity, error) {
 	s.sm.Lock()
 	defer s.sm.Unlock()
 
-	all := make([]MetricEntity, 0, len(s.Metrics))
-	for _, v := range s.Metrics {
+	metrics := s.Metrics[tenant.FromContext(ctx)]
+	all := make([]MetricEntity, 0, len(metrics))
+	for _, v := range metrics {
 		all = append(all, v)
 	}
 	return all, nil
 }
 
-// Remove удаляет по типу и наименованию.
-func (s *MemMetricRepository) Remove(_ context.Context, t, n string) error {
+// Count кол-во метрик тенанта из ctx.
+func (s *MemMetricRepository) Count(ctx context.Context) (int, error) {
 	s.sm.Lock()
 	defer s.sm.Unlock()
 
-	key := metric.Key(t, n)
-	delete(s.Metrics, key)
+	return len(s.Metrics[tenant.FromContext(ctx)]), nil
+}
+
+// Existing ключи метрик тенанта из ctx, которые уже есть в хранилище.
+func (s *MemMetricRepository) Existing(ctx context.Context, entities []MetricEntity) (map[string]struct{}, error) {
+	s.sm.Lock()
+	defer s.sm.Unlock()
+
+	metrics := s.Metrics[tenant.FromContext(ctx)]
+	existing := make(map[string]struct{})
+	for _, en := range entities {
+		if _, ok := metrics[en.Key]; ok {
+			existing[en.Key] = struct{}{}
+		}
+	}
+	return existing, nil
+}
+
+// Remove удаляет по типу, наименованию и меткам метрику тенанта из ctx.
+func (s *MemMetricRepository) Remove(ctx context.Context, t, n string, l metric.Labels) error {
+	s.sm.Lock()
+	defer s.sm.Unlock()
+
+	key := metric.Key(t, n, l)
+	delete(s.Metrics[tenant.FromContext(ctx)], key)
 	return nil
 }
 
@@ -97,9 +116,11 @@ func (s *MemMetricRepository) Backup(_ context.Context) error {
 	s.sm.Lock()
 	defer s.sm.Unlock()
 
-	return s.snapshot.Write(
-		slices.Collect(maps.Values(s.Metrics)),
-	)
+	var all []MetricEntity
+	for _, metrics := range s.Metrics {
+		all = slices.AppendSeq(all, maps.Values(metrics))
+	}
+	return s.snapshot.Write(all)
 }
 
 // Restore восстановление данных из снапшота.
@@ -112,10 +133,37 @@ func (s *MemMetricRepository) Restore(_ context.Context) error {
 	if err != nil {
 		return err
 	}
+	s.sm.Lock()
 	for _, m := range data {
-		s.Metrics[m.Key] = m
+		s.tenantMetrics(m.Tenant)[m.Key] = m
 	}
+	s.sm.Unlock()
 
 	s.logger.Debugf("repository.restore restored len=%d", len(data))
 	return nil
 }
+
+func (s *MemMetricRepository) upsert(m MetricEntity) {
+	metrics := s.tenantMetrics(m.Tenant)
+	old, exists := metrics[m.Key]
+	if exists {
+		old.Merge(m)
+		metrics[m.Key] = old
+	} else {
+		m.Histogram = m.Histogram.Clone()
+		m.Labels = m.Labels.Clone()
+		metrics[m.Key] = m
+	}
+}
+
+func (s *MemMetricRepository) tenantMetrics(t string) map[string]MetricEntity {
+	if s.Metrics == nil {
+		s.Metrics = make(map[string]map[string]MetricEntity)
+	}
+	metrics := s.Metrics[t]
+	if metrics == nil {
+		metrics = make(map[string]MetricEntity)
+		s.Metrics[t] = metrics
+	}
+	return metrics
+}
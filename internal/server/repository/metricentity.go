@@ -6,11 +6,14 @@ import (
 
 // MetricEntity сущность для сохранения в repository.
 type MetricEntity struct {
-	Key   string      `json:"key"`
-	Type  metric.Type `json:"type"`
-	Name  string      `json:"name"`
-	Delta int64       `json:"delta"`
-	Value float64     `json:"value"`
+	Key       string            `json:"key"`
+	Type      metric.Type       `json:"type"`
+	Name      string            `json:"name"`
+	Delta     int64             `json:"delta"`
+	Value     float64           `json:"value"`
+	Histogram *metric.Histogram `json:"histogram,omitempty"`
+	Labels    metric.Labels     `json:"labels,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
 }
 
 // ValueByType возвращает значение в зависимости от типа.
@@ -20,15 +23,37 @@ func (e *MetricEntity) ValueByType() any {
 		return e.Delta
 	case metric.TypeGauge:
 		return e.Value
+	case metric.TypeHistogram:
+		return e.Histogram
 	}
 	return nil
 }
 
+// Merge применяет обновление m к сущности.
+// Гистограмма не изменяется на месте, а заменяется копией,
+// поэтому ранее отданные наружу сущности остаются неизменными.
+func (e *MetricEntity) Merge(m MetricEntity) {
+	e.Value = m.Value
+	e.Delta += m.Delta
+
+	if m.Histogram == nil {
+		return
+	}
+	h := e.Histogram.Clone()
+	if h == nil {
+		h = m.Histogram.Clone()
+	} else {
+		h.Merge(m.Histogram)
+	}
+	e.Histogram = h
+}
+
 // ToMetrics мап сущности в дто.
 func (e *MetricEntity) ToMetrics() metric.Metrics {
 	m := metric.Metrics{
-		ID:   e.Name,
-		Type: string(e.Type),
+		ID:     e.Name,
+		Type:   string(e.Type),
+		Labels: e.Labels.Clone(),
 	}
 
 	switch e.Type {
@@ -36,6 +61,8 @@ func (e *MetricEntity) ToMetrics() metric.Metrics {
 		m.Delta = &e.Delta
 	case metric.TypeGauge:
 		m.Value = &e.Value
+	case metric.TypeHistogram:
+		m.Histogram = e.Histogram.Clone()
 	}
 
 	return m
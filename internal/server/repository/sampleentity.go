@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+// SampleEntity сущность отсчета метрики во времени.
+// Для counter хранится пришедшая дельта, для gauge - значение,
+// для histogram в Value пишется сумма наблюдений, в Delta - их кол-во.
+type SampleEntity struct {
+	Key    string        `json:"key"`
+	Type   metric.Type   `json:"type"`
+	Name   string        `json:"name"`
+	Labels metric.Labels `json:"labels,omitempty"`
+	Time   time.Time     `json:"time"`
+	Delta  int64         `json:"delta"`
+	Value  float64       `json:"value"`
+	Tenant string        `json:"tenant,omitempty"`
+}
+
+// NewSampleEntity отсчет для метрики в момент времени ts.
+func NewSampleEntity(m MetricEntity, ts time.Time) SampleEntity {
+	s := SampleEntity{
+		Key:    m.Key,
+		Type:   m.Type,
+		Name:   m.Name,
+		Labels: m.Labels,
+		Time:   ts,
+		Delta:  m.Delta,
+		Value:  m.Value,
+		Tenant: m.Tenant,
+	}
+	if m.Type == metric.TypeHistogram && m.Histogram != nil {
+		s.Delta = m.Histogram.Count
+		s.Value = m.Histogram.Sum
+	}
+	return s
+}
+
+// ToSample мап сущности в дто.
+func (s *SampleEntity) ToSample() metric.Sample {
+	sm := metric.Sample{
+		Time: s.Time,
+	}
+
+	switch s.Type {
+	case metric.TypeCounter:
+		sm.Delta = &s.Delta
+	case metric.TypeGauge:
+		sm.Value = &s.Value
+	case metric.TypeHistogram:
+		sm.Delta = &s.Delta
+		sm.Value = &s.Value
+	}
+
+	return sm
+}
+
+// inRange попадает ли отсчет в интервал. Нулевые границы не ограничивают интервал.
+func (s *SampleEntity) inRange(from, to time.Time) bool {
+	if !from.IsZero() && s.Time.Before(from) {
+		return false
+	}
+	if !to.IsZero() && s.Time.After(to) {
+		return false
+	}
+	return true
+}
@@ -9,15 +9,17 @@ import (
 
 	"github.com/ktigay/metrics-collector/internal/metric"
 	"github.com/ktigay/metrics-collector/internal/retry"
+	"github.com/ktigay/metrics-collector/internal/server/auth"
 	e "github.com/ktigay/metrics-collector/internal/server/errors"
 	"github.com/ktigay/metrics-collector/internal/server/repository"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
 )
 
 // MetricRepository интерфейс хранилища.
 type MetricRepository interface {
 	Upsert(ctx context.Context, m repository.MetricEntity) error
-	Find(ctx context.Context, t, n string) (*repository.MetricEntity, error)
-	Remove(ctx context.Context, t, n string) error
+	Find(ctx context.Context, t, n string, l metric.Labels) (*repository.MetricEntity, error)
+	Remove(ctx context.Context, t, n string, l metric.Labels) error
 	All(ctx context.Context) ([]repository.MetricEntity, error)
 }
 
@@ -32,41 +34,94 @@ type BatchMetricRepository interface {
 	UpsertAll(ctx context.Context, mt []repository.MetricEntity) error
 }
 
+// CountMetricRepository интерфейс для подсчета метрик тенанта.
+type CountMetricRepository interface {
+	Count(ctx context.Context) (int, error)
+}
+
+// ExistingMetricRepository интерфейс проверки существования метрик батчем.
+type ExistingMetricRepository interface {
+	Existing(ctx context.Context, entities []repository.MetricEntity) (map[string]struct{}, error)
+}
+
+// HistoryRepository интерфейс хранилища истории метрик.
+type HistoryRepository interface {
+	Append(ctx context.Context, samples []repository.SampleEntity) error
+	Range(ctx context.Context, t, n string, l metric.Labels, from, to time.Time) ([]repository.SampleEntity, error)
+	Aggregates(ctx context.Context, t, n string, l metric.Labels, res time.Duration, from, to time.Time) ([]repository.AggregateEntity, error)
+}
+
+// CompactRepository интерфейс для свертки и очистки истории.
+type CompactRepository interface {
+	Compact(ctx context.Context, p repository.RetentionPolicy, now time.Time) error
+}
+
 // MetricCollector сборщик статистики.
 type MetricCollector struct {
-	repo   MetricRepository
-	logger *zap.SugaredLogger
+	repo    MetricRepository
+	history HistoryRepository
+	logger  *zap.SugaredLogger
+	// quota максимальное кол-во метрик тенанта, 0 - без ограничений.
+	quota int
+	// quotaLocks сериализуют проверку квоты и сохранение в пределах тенанта.
+	quotaLocks tenant.Locks
+}
+
+// Option опция сборщика статистики.
+type Option func(*MetricCollector)
+
+// WithHistory сохранять историю метрик в репозиторий h.
+func WithHistory(h HistoryRepository) Option {
+	return func(c *MetricCollector) {
+		c.history = h
+	}
+}
+
+// WithTenantQuota ограничить кол-во метрик одного тенанта.
+func WithTenantQuota(quota int) Option {
+	return func(c *MetricCollector) {
+		c.quota = quota
+	}
 }
 
 // NewMetricCollector конструктор.
-func NewMetricCollector(repo MetricRepository, logger *zap.SugaredLogger) *MetricCollector {
-	return &MetricCollector{
+func NewMetricCollector(repo MetricRepository, logger *zap.SugaredLogger, opts ...Option) *MetricCollector {
+	c := &MetricCollector{
 		repo:   repo,
 		logger: logger,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-// Save собирает статистику.
+// Save собирает статистику в хранилище тенанта из ctx.
+// Если в контексте есть агент, метрика должна входить в разрешенные ему префиксы.
 func (c *MetricCollector) Save(ctx context.Context, mt metric.Metrics) error {
-	var (
-		t       metric.Type
-		memItem repository.MetricEntity
-		err     error
-	)
+	if err := checkAllowed(ctx, mt); err != nil {
+		return err
+	}
 
-	if t, err = metric.ResolveType(mt.Type); err != nil {
-		return e.ErrWrongType
+	memItem, err := newEntity(ctx, mt)
+	if err != nil {
+		return err
 	}
 
-	memItem = repository.MetricEntity{
-		Key:   mt.Key(),
-		Name:  mt.ID,
-		Type:  t,
-		Delta: mt.GetDelta(),
-		Value: mt.GetValue(),
+	if c.quota > 0 {
+		unlock := c.quotaLocks.Lock(tenant.FromContext(ctx))
+		defer unlock()
+		if err = c.checkQuota(ctx, []repository.MetricEntity{memItem}); err != nil {
+			return err
+		}
+	}
+
+	if err = c.repo.Upsert(ctx, memItem); err != nil {
+		return err
 	}
 
-	return c.repo.Upsert(ctx, memItem)
+	c.appendHistory(ctx, []repository.MetricEntity{memItem})
+	return nil
 }
 
 // All возвращает все записи.
@@ -75,7 +130,7 @@ func (c *MetricCollector) All(ctx context.Context) ([]repository.MetricEntity, e
 }
 
 // Find находит запись по ключу.
-func (c *MetricCollector) Find(ctx context.Context, t, n string) (*metric.Metrics, error) {
+func (c *MetricCollector) Find(ctx context.Context, t, n string, l metric.Labels) (*metric.Metrics, error) {
 	var (
 		entity *repository.MetricEntity
 		err    error
@@ -84,7 +139,7 @@ func (c *MetricCollector) Find(ctx context.Context, t, n string) (*metric.Metric
 		return nil, e.ErrWrongType
 	}
 
-	if entity, err = c.repo.Find(ctx, t, n); err != nil {
+	if entity, err = c.repo.Find(ctx, t, n, l); err != nil {
 		return nil, err
 	}
 	if entity == nil {
@@ -96,11 +151,11 @@ func (c *MetricCollector) Find(ctx context.Context, t, n string) (*metric.Metric
 }
 
 // Remove удаление записи по ключу.
-func (c *MetricCollector) Remove(ctx context.Context, t, n string) error {
+func (c *MetricCollector) Remove(ctx context.Context, t, n string, l metric.Labels) error {
 	if _, err := metric.ResolveType(t); err != nil {
 		return e.ErrWrongType
 	}
-	return c.repo.Remove(ctx, t, n)
+	return c.repo.Remove(ctx, t, n, l)
 }
 
 // Backup бэкап данных.
@@ -154,36 +209,226 @@ func (c *MetricCollector) Restore(ctx context.Context) error {
 	return nil
 }
 
-// SaveAll сохраняет батч.
+// SaveAll сохраняет батч. Батч отклоняется целиком, если хотя бы одна метрика не разрешена агенту
+// или новые метрики не помещаются в квоту тенанта.
 func (c *MetricCollector) SaveAll(ctx context.Context, mt []metric.Metrics) error {
 	var err error
 	entities := make([]repository.MetricEntity, 0, len(mt))
 
 	for _, m := range mt {
-		var t metric.Type
-		if t, err = metric.ResolveType(m.Type); err != nil {
-			return e.ErrWrongType
+		if err = checkAllowed(ctx, m); err != nil {
+			return err
 		}
 
-		en := repository.MetricEntity{
-			Key:   metric.Key(m.Type, m.ID),
-			Name:  m.ID,
-			Type:  t,
-			Delta: m.GetDelta(),
-			Value: m.GetValue(),
+		var en repository.MetricEntity
+		if en, err = newEntity(ctx, m); err != nil {
+			return err
 		}
 		entities = append(entities, en)
 	}
 
+	if c.quota > 0 {
+		unlock := c.quotaLocks.Lock(tenant.FromContext(ctx))
+		defer unlock()
+		if err = c.checkQuota(ctx, entities); err != nil {
+			return err
+		}
+	}
+
 	switch t := c.repo.(type) {
 	case BatchMetricRepository:
-		return t.UpsertAll(ctx, entities)
+		err = t.UpsertAll(ctx, entities)
 	default:
 		for _, en := range entities {
 			if err = c.repo.Upsert(ctx, en); err != nil {
-				return err
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	c.appendHistory(ctx, entities)
+	return nil
+}
+
+// History возвращает историю метрики за интервал [from, to].
+// При res = 0 возвращаются исходные отсчеты, иначе агрегаты с разрешением res.
+func (c *MetricCollector) History(ctx context.Context, t, n string, l metric.Labels, res time.Duration, from, to time.Time) ([]metric.Sample, error) {
+	if c.history == nil {
+		return nil, e.ErrHistoryDisabled
+	}
+	if _, err := metric.ResolveType(t); err != nil {
+		return nil, e.ErrWrongType
+	}
+
+	switch res {
+	case 0:
+		entities, err := c.history.Range(ctx, t, n, l, from, to)
+		if err != nil {
+			return nil, err
+		}
+		samples := make([]metric.Sample, 0, len(entities))
+		for _, en := range entities {
+			samples = append(samples, en.ToSample())
+		}
+		return samples, nil
+	case repository.ResolutionMinute, repository.ResolutionHour:
+		entities, err := c.history.Aggregates(ctx, t, n, l, res, from, to)
+		if err != nil {
+			return nil, err
+		}
+		samples := make([]metric.Sample, 0, len(entities))
+		for _, en := range entities {
+			samples = append(samples, en.ToSample())
+		}
+		return samples, nil
+	default:
+		return nil, e.ErrWrongValue
+	}
+}
+
+// Compact периодическая свертка и очистка истории.
+func (c *MetricCollector) Compact(mainCtx, exitCtx context.Context, interval time.Duration, p repository.RetentionPolicy) error {
+	var repo CompactRepository
+	switch t := c.history.(type) {
+	case CompactRepository:
+		repo = t
+	}
+
+	if repo == nil {
+		c.logger.Debug("history repo not supported compaction")
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.logger.Debug("compact history started")
+			// ошибка свертки не останавливает цикл: данные будут свернуты на следующем тике.
+			if err := repo.Compact(mainCtx, p, time.Now()); err != nil {
+				c.logger.Errorf("compact history error: %v", err)
+				continue
 			}
+			c.logger.Debug("compact history finished")
+		case <-exitCtx.Done():
+			c.logger.Debug("compact history shutting down")
+			return nil
 		}
+	}
+}
+
+// appendHistory добавляет отсчеты в историю. Вызывается после сохранения метрик:
+// ошибка истории только логируется, иначе клиент повторит уже примененные приращения счетчиков.
+func (c *MetricCollector) appendHistory(ctx context.Context, entities []repository.MetricEntity) {
+	if c.history == nil {
+		return
+	}
+
+	now := time.Now()
+	samples := make([]repository.SampleEntity, 0, len(entities))
+	for _, en := range entities {
+		samples = append(samples, repository.NewSampleEntity(en, now))
+	}
+	if err := c.history.Append(ctx, samples); err != nil {
+		c.logger.Errorf("append history error: %v", err)
+	}
+}
+
+// checkQuota проверяет, что новые метрики помещаются в квоту тенанта.
+func (c *MetricCollector) checkQuota(ctx context.Context, entities []repository.MetricEntity) error {
+	existing, err := c.existing(ctx, entities)
+	if err != nil {
+		return err
+	}
+	added := make(map[string]struct{})
+	for _, en := range entities {
+		if _, ok := existing[en.Key]; !ok {
+			added[en.Key] = struct{}{}
+		}
+	}
+	if len(added) == 0 {
 		return nil
 	}
+
+	count, err := c.count(ctx)
+	if err != nil {
+		return err
+	}
+	if count+len(added) > c.quota {
+		return e.ErrQuotaExceeded
+	}
+	return nil
+}
+
+// existing ключи уже сохраненных метрик из entities.
+func (c *MetricCollector) existing(ctx context.Context, entities []repository.MetricEntity) (map[string]struct{}, error) {
+	if t, ok := c.repo.(ExistingMetricRepository); ok {
+		return t.Existing(ctx, entities)
+	}
+
+	existing := make(map[string]struct{})
+	for _, en := range entities {
+		found, err := c.repo.Find(ctx, en.Type.String(), en.Name, en.Labels)
+		if err != nil {
+			return nil, err
+		}
+		if found != nil {
+			existing[en.Key] = struct{}{}
+		}
+	}
+	return existing, nil
+}
+
+func (c *MetricCollector) count(ctx context.Context) (int, error) {
+	switch t := c.repo.(type) {
+	case CountMetricRepository:
+		return t.Count(ctx)
+	}
+
+	all, err := c.repo.All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return len(all), nil
+}
+
+func checkAllowed(ctx context.Context, m metric.Metrics) error {
+	if a, ok := auth.AgentFromContext(ctx); ok && !a.Allowed(m.ID) {
+		return e.ErrForbidden
+	}
+	return nil
+}
+
+func newEntity(ctx context.Context, m metric.Metrics) (repository.MetricEntity, error) {
+	t, err := metric.ResolveType(m.Type)
+	if err != nil {
+		return repository.MetricEntity{}, e.ErrWrongType
+	}
+
+	if m.Labels.Validate() != nil {
+		return repository.MetricEntity{}, e.ErrWrongValue
+	}
+
+	en := repository.MetricEntity{
+		Key:    m.Key(),
+		Name:   m.ID,
+		Type:   t,
+		Delta:  m.GetDelta(),
+		Value:  m.GetValue(),
+		Labels: m.Labels,
+		Tenant: tenant.FromContext(ctx),
+	}
+
+	if t == metric.TypeHistogram {
+		if m.Histogram == nil || m.Histogram.Validate() != nil {
+			return repository.MetricEntity{}, e.ErrWrongValue
+		}
+		en.Histogram = m.Histogram
+	}
+
+	return en, nil
 }
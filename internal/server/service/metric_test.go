@@ -2,13 +2,17 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 
 	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/auth"
+	e "github.com/ktigay/metrics-collector/internal/server/errors"
 	"github.com/ktigay/metrics-collector/internal/server/repository"
+	"github.com/ktigay/metrics-collector/internal/server/tenant"
 )
 
 func TestMetricCollector_Save(t *testing.T) {
@@ -91,12 +95,101 @@ func TestMetricCollector_Save(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Positive_test_histogram_merge",
+			fields: fields{
+				metrics: map[string]repository.MetricEntity{
+					"histogram:Latency": {
+						Key:  "histogram:Latency",
+						Type: metric.TypeHistogram,
+						Name: "Latency",
+						Histogram: &metric.Histogram{
+							Bounds: []float64{0.1, 1},
+							Counts: []int64{1, 2, 0},
+							Sum:    1.3,
+							Count:  3,
+						},
+					},
+				},
+			},
+			args: args{
+				m: []metric.Metrics{
+					{
+						Type: "histogram",
+						ID:   "Latency",
+						Histogram: &metric.Histogram{
+							Bounds: []float64{0.1, 1},
+							Counts: []int64{0, 1, 1},
+							Sum:    2.5,
+							Count:  2,
+						},
+					},
+				},
+			},
+			want: []repository.MetricEntity{
+				{
+					Key:  "histogram:Latency",
+					Type: metric.TypeHistogram,
+					Name: "Latency",
+					Histogram: &metric.Histogram{
+						Bounds: []float64{0.1, 1},
+						Counts: []int64{1, 3, 1},
+						Sum:    3.8,
+						Count:  5,
+					},
+				},
+			},
+		},
+		{
+			name: "Positive_test_labels_separate_keys",
+			fields: fields{
+				metrics: map[string]repository.MetricEntity{},
+			},
+			args: args{
+				m: []metric.Metrics{
+					{
+						Type:   "gauge",
+						ID:     "Alloc",
+						Labels: metric.Labels{"host": "a"},
+						Value: func() *float64 {
+							x := 1.0
+							return &x
+						}(),
+					},
+					{
+						Type:   "gauge",
+						ID:     "Alloc",
+						Labels: metric.Labels{"host": "b"},
+						Value: func() *float64 {
+							x := 2.0
+							return &x
+						}(),
+					},
+				},
+			},
+			want: []repository.MetricEntity{
+				{
+					Key:    `gauge:Alloc{host="a"}`,
+					Type:   metric.TypeGauge,
+					Name:   "Alloc",
+					Value:  1.0,
+					Labels: metric.Labels{"host": "a"},
+				},
+				{
+					Key:    `gauge:Alloc{host="b"}`,
+					Type:   metric.TypeGauge,
+					Name:   "Alloc",
+					Value:  2.0,
+					Labels: metric.Labels{"host": "b"},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := NewMetricCollector(
 				&repository.MemMetricRepository{
-					Metrics: tt.fields.metrics,
+					Metrics: map[string]map[string]repository.MetricEntity{tenant.Default: tt.fields.metrics},
 				},
 				zap.NewNop().Sugar(),
 			)
@@ -118,3 +211,145 @@ func TestMetricCollector_Save(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricCollector_Save_AgentPrefixes(t *testing.T) {
+	v := 1.0
+	agent := &auth.Agent{Name: "billing", Prefixes: []string{"billing_"}}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		m       []metric.Metrics
+		wantErr error
+		wantLen int
+	}{
+		{
+			name:    "Positive_test_allowed_prefix",
+			ctx:     auth.WithAgent(context.Background(), agent),
+			m:       []metric.Metrics{{Type: "gauge", ID: "billing_requests", Value: &v}},
+			wantLen: 1,
+		},
+		{
+			name:    "Positive_test_no_agent",
+			ctx:     context.Background(),
+			m:       []metric.Metrics{{Type: "gauge", ID: "Alloc", Value: &v}},
+			wantLen: 1,
+		},
+		{
+			name: "Negative_test_batch_with_forbidden_metric",
+			ctx:  auth.WithAgent(context.Background(), agent),
+			m: []metric.Metrics{
+				{Type: "gauge", ID: "billing_requests", Value: &v},
+				{Type: "gauge", ID: "Alloc", Value: &v},
+			},
+			wantErr: e.ErrForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMetricCollector(
+				&repository.MemMetricRepository{
+					Metrics: map[string]map[string]repository.MetricEntity{tenant.Default: {}},
+				},
+				zap.NewNop().Sugar(),
+			)
+
+			assert.ErrorIs(t, c.SaveAll(tt.ctx, tt.m), tt.wantErr)
+			if len(tt.m) == 1 {
+				assert.ErrorIs(t, c.Save(tt.ctx, tt.m[0]), tt.wantErr)
+			}
+
+			all, err := c.All(context.Background())
+			assert.NoError(t, err)
+			assert.Len(t, all, tt.wantLen)
+		})
+	}
+}
+
+func TestMetricCollector_Tenants(t *testing.T) {
+	v := 1.0
+	teamA := tenant.WithTenant(context.Background(), "team-a")
+	teamB := tenant.WithTenant(context.Background(), "team-b")
+
+	repo, err := repository.NewMemRepository(nil, zap.NewNop().Sugar())
+	assert.NoError(t, err)
+	c := NewMetricCollector(
+		repo,
+		zap.NewNop().Sugar(),
+		WithTenantQuota(2),
+	)
+
+	assert.NoError(t, c.SaveAll(teamA, []metric.Metrics{
+		{Type: "gauge", ID: "Alloc", Value: &v},
+		{Type: "gauge", ID: "Frees", Value: &v},
+	}))
+	// обновление существующей метрики не расходует квоту.
+	assert.NoError(t, c.Save(teamA, metric.Metrics{Type: "gauge", ID: "Alloc", Value: &v}))
+	assert.ErrorIs(t, c.Save(teamA, metric.Metrics{Type: "gauge", ID: "Mallocs", Value: &v}), e.ErrQuotaExceeded)
+	assert.ErrorIs(t, c.SaveAll(teamA, []metric.Metrics{
+		{Type: "gauge", ID: "Alloc", Value: &v},
+		{Type: "gauge", ID: "Mallocs", Value: &v},
+	}), e.ErrQuotaExceeded)
+
+	// квота и данные у каждого тенанта свои.
+	assert.NoError(t, c.Save(teamB, metric.Metrics{Type: "gauge", ID: "Mallocs", Value: &v}))
+
+	all, err := c.All(teamA)
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	_, err = c.Find(teamB, "gauge", "Alloc", nil)
+	assert.ErrorIs(t, err, e.ErrValueNotFound)
+	found, err := c.Find(teamB, "gauge", "Mallocs", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Mallocs", found.ID)
+
+	all, err = c.All(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+// failingHistory история, запись в которую всегда завершается ошибкой.
+type failingHistory struct {
+	HistoryRepository
+}
+
+func (failingHistory) Append(context.Context, []repository.SampleEntity) error {
+	return errors.New("history unavailable")
+}
+
+func TestMetricCollector_Save_HistoryError(t *testing.T) {
+	d := int64(1)
+	repo, err := repository.NewMemRepository(nil, zap.NewNop().Sugar())
+	assert.NoError(t, err)
+	c := NewMetricCollector(repo, zap.NewNop().Sugar(), WithHistory(failingHistory{}))
+
+	// ошибка истории не должна приводить к повторной отправке уже сохраненного приращения.
+	assert.NoError(t, c.Save(context.Background(), metric.Metrics{Type: "counter", ID: "PollCount", Delta: &d}))
+	assert.NoError(t, c.SaveAll(context.Background(), []metric.Metrics{{Type: "counter", ID: "PollCount", Delta: &d}}))
+
+	found, err := c.Find(context.Background(), "counter", "PollCount", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), *found.Delta)
+}
+
+func TestMetricCollector_Quota_WithoutBatchCheck(t *testing.T) {
+	v := 1.0
+	ctx := tenant.WithTenant(context.Background(), "team-a")
+
+	repo, err := repository.NewMemRepository(nil, zap.NewNop().Sugar())
+	assert.NoError(t, err)
+	// хранилище без Existing и Count: существование проверяется по одной метрике.
+	c := NewMetricCollector(struct{ MetricRepository }{repo}, zap.NewNop().Sugar(), WithTenantQuota(2))
+
+	assert.NoError(t, c.SaveAll(ctx, []metric.Metrics{
+		{Type: "gauge", ID: "Alloc", Value: &v},
+		{Type: "gauge", ID: "Alloc", Value: &v},
+		{Type: "gauge", ID: "Frees", Value: &v},
+	}))
+	assert.NoError(t, c.Save(ctx, metric.Metrics{Type: "gauge", ID: "Frees", Value: &v}))
+	assert.ErrorIs(t, c.SaveAll(ctx, []metric.Metrics{
+		{Type: "gauge", ID: "Alloc", Value: &v},
+		{Type: "gauge", ID: "Mallocs", Value: &v},
+	}), e.ErrQuotaExceeded)
+}
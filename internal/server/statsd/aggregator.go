@@ -0,0 +1,99 @@
+package statsd
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+)
+
+type timerStat struct {
+	sum     float64
+	samples int
+	// count кол-во событий с поправкой на частоту семплирования.
+	count float64
+}
+
+// Aggregator накапливает строки StatsD между сбросами.
+// Значения gauge сохраняются между сбросами, чтобы приращения +/- применялись к последнему значению.
+type Aggregator struct {
+	mx       sync.Mutex
+	counters map[string]float64
+	timers   map[string]*timerStat
+	gauges   map[string]float64
+	dirty    map[string]struct{}
+}
+
+// NewAggregator конструктор.
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		counters: make(map[string]float64),
+		timers:   make(map[string]*timerStat),
+		gauges:   make(map[string]float64),
+		dirty:    make(map[string]struct{}),
+	}
+}
+
+// Add добавляет строку.
+func (a *Aggregator) Add(l Line) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	switch l.Type {
+	case TypeCounter:
+		a.counters[l.Name] += l.Value / l.Rate
+	case TypeGauge:
+		if l.Relative {
+			a.gauges[l.Name] += l.Value
+		} else {
+			a.gauges[l.Name] = l.Value
+		}
+		a.dirty[l.Name] = struct{}{}
+	case TypeTimer:
+		t, ok := a.timers[l.Name]
+		if !ok {
+			t = &timerStat{}
+			a.timers[l.Name] = t
+		}
+		t.sum += l.Value
+		t.samples++
+		t.count += 1 / l.Rate
+	}
+}
+
+// Flush возвращает накопленные метрики и очищает счетчики и таймеры.
+// Таймер превращается в gauge со средним значением и счетчик <name>_count.
+func (a *Aggregator) Flush() []metric.Metrics {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	mm := make([]metric.Metrics, 0, len(a.counters)+len(a.dirty)+2*len(a.timers))
+	for name, v := range a.counters {
+		d := int64(math.Round(v))
+		mm = append(mm, metric.Metrics{ID: name, Type: string(metric.TypeCounter), Delta: &d})
+	}
+	for name := range a.dirty {
+		v := a.gauges[name]
+		mm = append(mm, metric.Metrics{ID: name, Type: string(metric.TypeGauge), Value: &v})
+	}
+	for name, t := range a.timers {
+		mean := t.sum / float64(t.samples)
+		c := int64(math.Round(t.count))
+		mm = append(mm,
+			metric.Metrics{ID: name, Type: string(metric.TypeGauge), Value: &mean},
+			metric.Metrics{ID: name + "_count", Type: string(metric.TypeCounter), Delta: &c},
+		)
+	}
+
+	clear(a.counters)
+	clear(a.timers)
+	clear(a.dirty)
+
+	slices.SortFunc(mm, func(x, y metric.Metrics) int {
+		return strings.Compare(x.Key(), y.Key())
+	})
+
+	return mm
+}
@@ -0,0 +1,79 @@
+// Package statsd Прием метрик по протоколу StatsD (UDP).
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Type тип StatsD-метрики.
+type Type string
+
+const (
+	// TypeCounter счетчик.
+	TypeCounter Type = "c"
+	// TypeGauge gauge.
+	TypeGauge Type = "g"
+	// TypeTimer таймер в миллисекундах.
+	TypeTimer Type = "ms"
+)
+
+// ErrInvalidLine некорректная строка StatsD.
+var ErrInvalidLine = errors.New("invalid statsd line")
+
+// Line разобранная строка StatsD вида name:value|type[|@rate].
+type Line struct {
+	Name  string
+	Value float64
+	Type  Type
+	// Rate частота семплирования (0, 1].
+	Rate float64
+	// Relative значение gauge со знаком +/- является приращением.
+	Relative bool
+}
+
+// ParseLine разбирает строку StatsD.
+func ParseLine(s string) (Line, error) {
+	s = strings.TrimSpace(s)
+
+	name, rest, ok := strings.Cut(s, ":")
+	if !ok || name == "" {
+		return Line{}, fmt.Errorf("%w: %q", ErrInvalidLine, s)
+	}
+
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 {
+		return Line{}, fmt.Errorf("%w: missing type in %q", ErrInvalidLine, s)
+	}
+
+	l := Line{Name: name, Type: Type(parts[1]), Rate: 1}
+	switch l.Type {
+	case TypeCounter, TypeGauge, TypeTimer:
+	default:
+		return Line{}, fmt.Errorf("%w: unsupported type %q", ErrInvalidLine, parts[1])
+	}
+
+	raw := parts[0]
+	if l.Type == TypeGauge && (strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-")) {
+		l.Relative = true
+	}
+
+	var err error
+	if l.Value, err = strconv.ParseFloat(raw, 64); err != nil {
+		return Line{}, fmt.Errorf("%w: bad value %q", ErrInvalidLine, raw)
+	}
+
+	for _, p := range parts[2:] {
+		if !strings.HasPrefix(p, "@") {
+			// теги и прочие расширения протокола игнорируются.
+			continue
+		}
+		if l.Rate, err = strconv.ParseFloat(p[1:], 64); err != nil || l.Rate <= 0 || l.Rate > 1 {
+			return Line{}, fmt.Errorf("%w: bad sample rate %q", ErrInvalidLine, p)
+		}
+	}
+
+	return l, nil
+}
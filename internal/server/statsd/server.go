@@ -0,0 +1,136 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/subnet"
+)
+
+const maxPacketSize = 64 * 1024
+
+// Collector интерфейс сохранения метрик.
+//
+//go:generate mockgen -destination=./mocks/mock_collector.go -package=mocks github.com/ktigay/metrics-collector/internal/server/statsd Collector
+type Collector interface {
+	SaveAll(ctx context.Context, mt []metric.Metrics) error
+}
+
+// Server UDP-сервер StatsD.
+type Server struct {
+	addr          string
+	flushInterval time.Duration
+	collector     Collector
+	aggregator    *Aggregator
+	logger        *zap.SugaredLogger
+	// subnets доверенные подсети, пусто - пакеты принимаются с любых адресов.
+	subnets []*net.IPNet
+}
+
+// Option опция сервера.
+type Option func(*Server)
+
+// WithTrustedSubnets принимать пакеты только с адресов из подсетей subnets.
+func WithTrustedSubnets(subnets []*net.IPNet) Option {
+	return func(s *Server) {
+		s.subnets = subnets
+	}
+}
+
+// NewServer конструктор.
+func NewServer(addr string, flushInterval time.Duration, collector Collector, logger *zap.SugaredLogger, opts ...Option) *Server {
+	s := &Server{
+		addr:          addr,
+		flushInterval: flushInterval,
+		collector:     collector,
+		aggregator:    NewAggregator(),
+		logger:        logger,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ListenAndServe слушает UDP-адрес и обслуживает его до завершения exitCtx.
+func (s *Server) ListenAndServe(mainCtx, exitCtx context.Context) error {
+	conn, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(mainCtx, exitCtx, conn)
+}
+
+// Serve читает пакеты из conn и периодически сбрасывает метрики в коллектор.
+// При завершении exitCtx закрывает conn и выполняет последний сброс.
+func (s *Server) Serve(mainCtx, exitCtx context.Context, conn net.PacketConn) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.read(exitCtx, conn)
+	}()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(mainCtx)
+		case <-exitCtx.Done():
+			ticker.Stop()
+			err := conn.Close()
+			<-done
+			s.flush(mainCtx)
+			s.logger.Debug("statsd server shutting down")
+			return err
+		}
+	}
+}
+
+// read читает пакеты до закрытия conn или завершения ctx.
+// Прочие ошибки чтения (например, ICMP port unreachable) логируются, чтение продолжается.
+func (s *Server) read(ctx context.Context, conn net.PacketConn) {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || ctx.Err() != nil {
+				return
+			}
+			s.logger.Errorf("statsd read error: %v", err)
+			continue
+		}
+		if len(s.subnets) > 0 && !subnet.Contains(s.subnets, subnet.AddrIP(addr)) {
+			s.logger.Warnf("statsd untrusted ip %v", addr)
+			continue
+		}
+
+		for _, raw := range strings.Split(string(buf[:n]), "\n") {
+			if strings.TrimSpace(raw) == "" {
+				continue
+			}
+			l, err := ParseLine(raw)
+			if err != nil {
+				s.logger.Debugf("statsd skip line: %v", err)
+				continue
+			}
+			s.aggregator.Add(l)
+		}
+	}
+}
+
+func (s *Server) flush(ctx context.Context) {
+	mm := s.aggregator.Flush()
+	if len(mm) == 0 {
+		return
+	}
+	if err := s.collector.SaveAll(ctx, mm); err != nil {
+		s.logger.Errorf("statsd flush error: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ktigay/metrics-collector/internal/metric"
+	"github.com/ktigay/metrics-collector/internal/server/statsd/mocks"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    Line
+		wantErr bool
+	}{
+		{
+			name: "counter",
+			line: "api.requests:3|c",
+			want: Line{Name: "api.requests", Value: 3, Type: TypeCounter, Rate: 1},
+		},
+		{
+			name: "counter_with_rate",
+			line: "api.requests:1|c|@0.1",
+			want: Line{Name: "api.requests", Value: 1, Type: TypeCounter, Rate: 0.1},
+		},
+		{
+			name: "gauge",
+			line: "queue.size:42.5|g",
+			want: Line{Name: "queue.size", Value: 42.5, Type: TypeGauge, Rate: 1},
+		},
+		{
+			name: "gauge_delta",
+			line: "queue.size:-5|g",
+			want: Line{Name: "queue.size", Value: -5, Type: TypeGauge, Rate: 1, Relative: true},
+		},
+		{
+			name: "timer_with_tags",
+			line: "db.query:12|ms|#host:a",
+			want: Line{Name: "db.query", Value: 12, Type: TypeTimer, Rate: 1},
+		},
+		{
+			name:    "missing_type",
+			line:    "api.requests:3",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported_type",
+			line:    "api.users:3|s",
+			wantErr: true,
+		},
+		{
+			name:    "bad_value",
+			line:    "api.requests:x|c",
+			wantErr: true,
+		},
+		{
+			name:    "bad_rate",
+			line:    "api.requests:1|c|@2",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.wantErr {
+				require.True(t, errors.Is(err, ErrInvalidLine))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAggregator_Flush(t *testing.T) {
+	a := NewAggregator()
+	for _, l := range []Line{
+		{Name: "hits", Value: 1, Type: TypeCounter, Rate: 0.5},
+		{Name: "hits", Value: 3, Type: TypeCounter, Rate: 1},
+		{Name: "temp", Value: 10, Type: TypeGauge, Rate: 1},
+		{Name: "temp", Value: -4, Type: TypeGauge, Rate: 1, Relative: true},
+		{Name: "query", Value: 10, Type: TypeTimer, Rate: 1},
+		{Name: "query", Value: 20, Type: TypeTimer, Rate: 0.5},
+	} {
+		a.Add(l)
+	}
+
+	hits, temp, mean, count := int64(5), 6.0, 15.0, int64(3)
+	require.Equal(t, []metric.Metrics{
+		{ID: "hits", Type: "counter", Delta: &hits},
+		{ID: "query_count", Type: "counter", Delta: &count},
+		{ID: "query", Type: "gauge", Value: &mean},
+		{ID: "temp", Type: "gauge", Value: &temp},
+	}, a.Flush())
+
+	// после сброса приращение gauge применяется к сохраненному значению.
+	a.Add(Line{Name: "temp", Value: 1, Type: TypeGauge, Rate: 1, Relative: true})
+	temp = 7
+	require.Equal(t, []metric.Metrics{
+		{ID: "temp", Type: "gauge", Value: &temp},
+	}, a.Flush())
+}
+
+func TestServer_Serve(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	hits, temp := int64(2), 1.5
+	col := mocks.NewMockCollector(mockCtrl)
+	col.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+		{ID: "hits", Type: "counter", Delta: &hits},
+		{ID: "temp", Type: "gauge", Value: &temp},
+	}).Return(nil).Times(1)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	exitCtx, cancel := context.WithCancel(context.Background())
+	s := NewServer("", time.Hour, col, zap.NewNop().Sugar())
+
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(context.Background(), exitCtx, conn)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		_ = client.Close()
+	}()
+	_, err = client.Write([]byte("hits:1|c\nhits:1|c\nbroken\ntemp:1.5|g"))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		s.aggregator.mx.Lock()
+		defer s.aggregator.mx.Unlock()
+		return len(s.aggregator.dirty) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+// flakyConn PacketConn, первое чтение которого завершается ошибкой.
+type flakyConn struct {
+	net.PacketConn
+	failed bool
+}
+
+func (c *flakyConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	if !c.failed {
+		c.failed = true
+		return 0, nil, errors.New("connection refused")
+	}
+	return c.PacketConn.ReadFrom(p)
+}
+
+func TestServer_Serve_ReadError(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	hits := int64(1)
+	col := mocks.NewMockCollector(mockCtrl)
+	col.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+		{ID: "hits", Type: "counter", Delta: &hits},
+	}).Return(nil).Times(1)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	conn := &flakyConn{PacketConn: pc}
+
+	exitCtx, cancel := context.WithCancel(context.Background())
+	s := NewServer("", time.Hour, col, zap.NewNop().Sugar())
+
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(context.Background(), exitCtx, conn)
+	}()
+
+	client, err := net.Dial("udp", pc.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		_ = client.Close()
+	}()
+	_, err = client.Write([]byte("hits:1|c"))
+	require.NoError(t, err)
+
+	// после ошибки чтения сервер продолжает принимать пакеты.
+	require.Eventually(t, func() bool {
+		s.aggregator.mx.Lock()
+		defer s.aggregator.mx.Unlock()
+		return len(s.aggregator.counters) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+// packet пакет от адреса addr.
+type packet struct {
+	addr net.Addr
+	data string
+}
+
+// scriptedConn PacketConn, отдающий заданные пакеты и блокирующийся до закрытия.
+type scriptedConn struct {
+	net.PacketConn
+	packets chan packet
+	closed  chan struct{}
+}
+
+func (c *scriptedConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	select {
+	case pk := <-c.packets:
+		return copy(p, pk.data), pk.addr, nil
+	case <-c.closed:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (c *scriptedConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestServer_Serve_TrustedSubnets(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	hits := int64(1)
+	col := mocks.NewMockCollector(mockCtrl)
+	col.EXPECT().SaveAll(gomock.Any(), []metric.Metrics{
+		{ID: "trusted", Type: "counter", Delta: &hits},
+	}).Return(nil).Times(1)
+
+	conn := &scriptedConn{packets: make(chan packet, 2), closed: make(chan struct{})}
+	conn.packets <- packet{addr: &net.UDPAddr{IP: net.ParseIP("192.168.1.1"), Port: 5000}, data: "untrusted:1|c"}
+	conn.packets <- packet{addr: &net.UDPAddr{IP: net.ParseIP("10.1.2.3"), Port: 5000}, data: "trusted:1|c"}
+
+	exitCtx, cancel := context.WithCancel(context.Background())
+	s := NewServer("", time.Hour, col, zap.NewNop().Sugar(), WithTrustedSubnets([]*net.IPNet{trusted}))
+
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(context.Background(), exitCtx, conn)
+	}()
+
+	// пакеты обрабатываются по порядку: появление второго значит, что первый уже отброшен.
+	require.Eventually(t, func() bool {
+		s.aggregator.mx.Lock()
+		defer s.aggregator.mx.Unlock()
+		return len(s.aggregator.counters) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
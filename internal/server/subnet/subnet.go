@@ -0,0 +1,58 @@
+// Package subnet проверка адресов клиентов по списку подсетей.
+package subnet
+
+import (
+	"net"
+	"strings"
+)
+
+// Parse разбирает список подсетей в CIDR через запятую. Пустая строка - пустой список.
+func Parse(s string) ([]*net.IPNet, error) {
+	var subnets []*net.IPNet
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
+
+// Contains входит ли ip в одну из подсетей.
+func Contains(subnets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, s := range subnets {
+		if s.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddrIP IP из сетевого адреса соединения, nil - адрес не IP.
+func AddrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	case nil:
+		return nil
+	}
+	return HostIP(addr.String())
+}
+
+// HostIP IP из адреса вида host:port, nil - адрес не IP.
+func HostIP(hostport string) net.IP {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,63 @@
+package subnet
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse(" 10.0.0.0/8, ,fd00::/8")
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "10.0.0.0/8", got[0].String())
+	require.Equal(t, "fd00::/8", got[1].String())
+
+	got, err = Parse("")
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = Parse("10.0.0.1")
+	require.Error(t, err)
+}
+
+func TestContains(t *testing.T) {
+	_, local, _ := net.ParseCIDR("10.0.0.0/8")
+	_, v6, _ := net.ParseCIDR("fd00::/8")
+	subnets := []*net.IPNet{local, v6}
+
+	tests := []struct {
+		name string
+		ip   net.IP
+		want bool
+	}{
+		{name: "Positive_test_v4", ip: net.ParseIP("10.1.2.3"), want: true},
+		{name: "Positive_test_v6", ip: net.ParseIP("fd00::1"), want: true},
+		{name: "Negative_test_outside", ip: net.ParseIP("192.168.1.1")},
+		{name: "Negative_test_nil", ip: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Contains(subnets, tt.ip))
+		})
+	}
+}
+
+func TestAddrIP(t *testing.T) {
+	tests := []struct {
+		name string
+		addr net.Addr
+		want net.IP
+	}{
+		{name: "Positive_test_tcp", addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1}, want: net.ParseIP("10.0.0.1")},
+		{name: "Positive_test_udp", addr: &net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1}, want: net.ParseIP("10.0.0.2")},
+		{name: "Negative_test_unix", addr: &net.UnixAddr{Name: "/tmp/sock", Net: "unix"}},
+		{name: "Negative_test_nil", addr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, AddrIP(tt.addr))
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package tenant
+
+import (
+	"sync"
+)
+
+// Locks блокировки по тенантам: операции одного тенанта выполняются последовательно,
+// разных тенантов - параллельно.
+type Locks struct {
+	mx    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// Lock захватывает блокировку тенанта t и возвращает функцию ее освобождения.
+func (l *Locks) Lock(t string) func() {
+	l.mx.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*sync.Mutex)
+	}
+	m, ok := l.locks[t]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[t] = m
+	}
+	l.mx.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
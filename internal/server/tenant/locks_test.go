@@ -0,0 +1,41 @@
+package tenant
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocks_Lock(t *testing.T) {
+	var l Locks
+
+	unlockA := l.Lock("team-a")
+
+	// блокировка другого тенанта не ждет team-a.
+	unlockB := l.Lock("team-b")
+	unlockB()
+
+	locked := make(chan struct{})
+	go func() {
+		unlock := l.Lock("team-a")
+		close(locked)
+		unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("team-a lock acquired twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlockA()
+	require.Eventually(t, func() bool {
+		select {
+		case <-locked:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,50 @@
+// Package tenant Тенанты: пространства имен метрик разных команд.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/ktigay/metrics-collector/internal/server/auth"
+)
+
+// Default тенант запросов без тенанта.
+const Default = ""
+
+var (
+	// ErrInvalid некорректное имя тенанта.
+	ErrInvalid = errors.New("invalid tenant")
+	// ErrMismatch запрошенный тенант не совпадает с тенантом агента.
+	ErrMismatch = errors.New("tenant does not match agent")
+)
+
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type tenantKey struct{}
+
+// WithTenant контекст с тенантом.
+func WithTenant(ctx context.Context, t string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+// FromContext тенант из контекста, [Default] если не задан.
+func FromContext(ctx context.Context) string {
+	t, _ := ctx.Value(tenantKey{}).(string)
+	return t
+}
+
+// Resolve тенант запроса: тенант аутентифицированного агента из ctx,
+// иначе запрошенный requested (например, из хедера X-Tenant).
+func Resolve(ctx context.Context, requested string) (string, error) {
+	if requested != "" && !nameRe.MatchString(requested) {
+		return "", ErrInvalid
+	}
+	if a, ok := auth.AgentFromContext(ctx); ok && a.Tenant != "" {
+		if requested != "" && requested != a.Tenant {
+			return "", ErrMismatch
+		}
+		return a.Tenant, nil
+	}
+	return requested, nil
+}
@@ -0,0 +1,50 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ktigay/metrics-collector/internal/server/auth"
+)
+
+func TestResolve(t *testing.T) {
+	withAgent := func(tenant string) context.Context {
+		return auth.WithAgent(context.Background(), &auth.Agent{Name: "web-1", Tenant: tenant})
+	}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		requested string
+		want      string
+		wantErr   error
+	}{
+		{name: "Positive_test_default", ctx: context.Background(), want: Default},
+		{name: "Positive_test_header", ctx: context.Background(), requested: "team-a", want: "team-a"},
+		{name: "Positive_test_agent", ctx: withAgent("team-b"), want: "team-b"},
+		{name: "Positive_test_agent_same_header", ctx: withAgent("team-b"), requested: "team-b", want: "team-b"},
+		{name: "Positive_test_agent_without_tenant", ctx: withAgent(""), requested: "team-a", want: "team-a"},
+		{name: "Negative_test_agent_mismatch", ctx: withAgent("team-b"), requested: "team-a", wantErr: ErrMismatch},
+		{name: "Negative_test_invalid_chars", ctx: context.Background(), requested: "team/a", wantErr: ErrInvalid},
+		{name: "Negative_test_too_long", ctx: context.Background(), requested: strings.Repeat("a", 65), wantErr: ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.ctx, tt.requested)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	require.Equal(t, Default, FromContext(context.Background()))
+	require.Equal(t, "team-a", FromContext(WithTenant(context.Background(), "team-a")))
+}
@@ -0,0 +1,164 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ktigay/metrics-collector/internal/compress"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certPath string
+	keyPath  string
+}
+
+func newTestCert(t *testing.T, dir, name string, tmpl *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.Subject = pkix.Name{CommonName: name}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	tc := &testCert{
+		cert:     cert,
+		key:      key,
+		certPath: filepath.Join(dir, name+".crt"),
+		keyPath:  filepath.Join(dir, name+".key"),
+	}
+	require.NoError(t, os.WriteFile(tc.certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(tc.keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return tc
+}
+
+func newTestCA(t *testing.T, dir, name string) *testCert {
+	return newTestCert(t, dir, name, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	otherCA := newTestCA(t, dir, "other-ca")
+	srvCert := newTestCert(t, dir, "server", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}, ca)
+	clientCert := newTestCert(t, dir, "client", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	foreignClientCert := newTestCert(t, dir, "foreign-client", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, otherCA)
+
+	tests := []struct {
+		name       string
+		serverCA   string
+		clientCA   string
+		clientCert *testCert
+		wantErr    bool
+	}{
+		{
+			name:     "Positive_test_tls",
+			clientCA: ca.certPath,
+		},
+		{
+			name:       "Positive_test_mtls",
+			serverCA:   ca.certPath,
+			clientCA:   ca.certPath,
+			clientCert: clientCert,
+		},
+		{
+			name:     "Negative_test_mtls_no_client_cert",
+			serverCA: ca.certPath,
+			clientCA: ca.certPath,
+			wantErr:  true,
+		},
+		{
+			name:       "Negative_test_mtls_foreign_client_cert",
+			serverCA:   ca.certPath,
+			clientCA:   ca.certPath,
+			clientCert: foreignClientCert,
+			wantErr:    true,
+		},
+		{
+			name:     "Negative_test_untrusted_server",
+			clientCA: otherCA.certPath,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverCfg, err := NewServerConfig(srvCert.certPath, srvCert.keyPath, tt.serverCA)
+			require.NoError(t, err)
+
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			srv.TLS = serverCfg
+			srv.StartTLS()
+			defer srv.Close()
+
+			var certPath, keyPath string
+			if tt.clientCert != nil {
+				certPath, keyPath = tt.clientCert.certPath, tt.clientCert.keyPath
+			}
+			clientCfg, err := NewClientConfig(tt.clientCA, certPath, keyPath)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			require.NoError(t, err)
+			resp, err := compress.NewClient(clientCfg).Do(req)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		})
+	}
+}
+
+func TestNewClientConfig_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "garbage.pem")
+	require.NoError(t, os.WriteFile(path, []byte("not a pem"), 0o600))
+
+	_, err := NewClientConfig(path, "", "")
+	require.Error(t, err)
+
+	_, err = NewServerConfig(path, path, "")
+	require.Error(t, err)
+}